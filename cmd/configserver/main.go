@@ -0,0 +1,79 @@
+// Command configserver serves configuration packages to the config.ServerDownloader.
+//
+// Packages are read from the directory layout '<root>/<stage>/<environment>/<component>/<version>.zip', and the
+// tenants' packages from '<root>/.tenants/<tenant>/<stage>/<environment>/<component>/<version>.zip'. When a JWKS
+// url is specified, requests require a bearer token issued for the audience which contains the permission.
+package main
+
+import (
+	"flag"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/authorization"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/configserver"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/middleware"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	address := flag.String("address", ":8080", "Address to listen on.")
+	root := flag.String("root", "packages", "Directory containing the configuration packages.")
+	jwksUrl := flag.String("jwks-url", "", "Url of the JWKS used to validate bearer tokens. Authorization is disabled when empty.")
+	audience := flag.String("audience", "", "Expected audience of the bearer tokens.")
+	issuer := flag.String("issuer", "", "Expected issuer of the bearer tokens.")
+	permission := flag.String("permission", configserver.ReadPermission, "Permission required to download configuration packages.")
+	signingKeyPath := flag.String("signing-key", "", "PEM encoded Ed25519 private key used to sign the packages.")
+	eventPollInterval := flag.Duration("event-poll-interval", configserver.DefaultEventPollInterval, "How often the event streams look for new versions copied into the root.")
+	flag.Parse()
+
+	logger, err := zap.NewProduction()
+
+	if err != nil {
+		panic(err)
+	}
+
+	server := configserver.NewServer(logger, configserver.NewPackageStore(*root))
+	server.SetEventPollInterval(*eventPollInterval)
+
+	if len(*jwksUrl) > 0 {
+		jwksResult := authorization.GetJwks(*jwksUrl)
+
+		if jwksResult.IsErr() {
+			logger.Fatal("Failed to get JWKS.", zap.String("err", jwksResult.UnwrapErr().Message))
+		}
+
+		server.SetAuthorization(middleware.NewAuthorization(logger, jwksResult.Unwrap(), *audience, *issuer), *permission)
+	} else {
+		logger.Warn("Authorization is disabled, every request is allowed.")
+	}
+
+	if len(*signingKeyPath) > 0 {
+		pemData, err := os.ReadFile(*signingKeyPath)
+
+		if err != nil {
+			logger.Fatal("Failed to read signing key.", zap.String("err", err.Error()))
+		}
+
+		keyResult := config.ParseEd25519PrivateKey(pemData)
+
+		if keyResult.IsErr() {
+			logger.Fatal("Failed to parse signing key.", zap.String("err", keyResult.UnwrapErr().Message))
+		}
+
+		server.SetSigningKey(keyResult.Unwrap())
+	}
+
+	httpServer := &http.Server{
+		Addr:              *address,
+		Handler:           server.Router(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	logger.Info("Serving configuration packages.", zap.String("address", *address), zap.String("root", *root))
+
+	if err := httpServer.ListenAndServe(); err != nil {
+		logger.Fatal("Configuration server stopped.", zap.String("err", err.Error()))
+	}
+}
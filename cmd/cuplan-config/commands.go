@@ -0,0 +1,383 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// packageSummary describes a configuration package written by fetch or pack.
+type packageSummary struct {
+	Path    string `json:"path" yaml:"path"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	Digest  string `json:"digest" yaml:"digest"`
+	Size    int    `json:"size" yaml:"size"`
+}
+
+// validationReport is the outcome of validate.
+type validationReport struct {
+	Valid      bool               `json:"valid" yaml:"valid"`
+	Violations []violationSummary `json:"violations,omitempty" yaml:"violations,omitempty"`
+}
+
+type violationSummary struct {
+	File    string `json:"file" yaml:"file"`
+	Key     string `json:"key,omitempty" yaml:"key,omitempty"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// diffChange is a config.Change with YAML field names.
+type diffChange struct {
+	Type     config.ChangeType `json:"type" yaml:"type"`
+	File     string            `json:"file" yaml:"file"`
+	Key      string            `json:"key,omitempty" yaml:"key,omitempty"`
+	OldValue any               `json:"old,omitempty" yaml:"old,omitempty"`
+	NewValue any               `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+func runFetch(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, common, source := newFlagSet("fetch", stderr)
+	output := flags.String("output", "", "File the package is written to; '-' writes it to the standard output. Defaults to '<component>.zip'.")
+
+	if !parseFlags(flags, args, common, "fetch [flags]", 0, 0) {
+		return exitUsage
+	}
+
+	if len(source.packagePath) > 0 {
+		return fail(stderr, *core.NewError(core.InvalidInput, "fetch does not support '-package'"))
+	}
+
+	if result := source.validate(); result.IsErr() {
+		return fail(stderr, result.UnwrapErr())
+	}
+
+	downloadResult := source.download(common.newLogger())
+
+	if downloadResult.IsErr() {
+		return fail(stderr, downloadResult.UnwrapErr())
+	}
+
+	versionedPackage := downloadResult.Unwrap()
+
+	if *output == "-" {
+		if _, err := stdout.Write(versionedPackage.Data); err != nil {
+			return fail(stderr, *core.NewError(core.IOFailure, fmt.Sprintf("failed to write package: %s", err)))
+		}
+
+		return exitSuccess
+	}
+
+	if len(*output) == 0 {
+		*output = source.component + ".zip"
+	}
+
+	if err := os.WriteFile(*output, versionedPackage.Data, 0644); err != nil {
+		return fail(stderr, *core.NewError(core.IOFailure, fmt.Sprintf("failed to write package '%s': %s", *output, err)))
+	}
+
+	summary := packageSummary{Path: *output, Version: versionedPackage.Version, Digest: config.ComputePackageDigest(versionedPackage.Data), Size: len(versionedPackage.Data)}
+
+	return finish(stdout, stderr, common.format, summary)
+}
+
+func runGet(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, common, source := newFlagSet("get", stderr)
+
+	if !parseFlags(flags, args, common, "get [flags] <file> <key>", 2, 2) {
+		return exitUsage
+	}
+
+	if result := source.validate(); result.IsErr() {
+		return fail(stderr, result.UnwrapErr())
+	}
+
+	loadResult := source.load(common.newLogger())
+
+	if loadResult.IsErr() {
+		return fail(stderr, loadResult.UnwrapErr())
+	}
+
+	loaded := loadResult.Unwrap()
+	defer loaded.close()
+
+	provider := config.NewFileProvider(loaded.path, core.NewCache(time.Minute), time.Minute)
+	valueResult := provider.Get(flags.Arg(0), flags.Arg(1))
+
+	if valueResult.IsErr() {
+		return fail(stderr, valueResult.UnwrapErr())
+	}
+
+	return finish(stdout, stderr, common.format, valueResult.Unwrap())
+}
+
+func runDump(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, common, source := newFlagSet("dump", stderr)
+
+	if !parseFlags(flags, args, common, "dump [flags]", 0, 0) {
+		return exitUsage
+	}
+
+	if result := source.validate(); result.IsErr() {
+		return fail(stderr, result.UnwrapErr())
+	}
+
+	loadResult := source.load(common.newLogger())
+
+	if loadResult.IsErr() {
+		return fail(stderr, loadResult.UnwrapErr())
+	}
+
+	loaded := loadResult.Unwrap()
+	defer loaded.close()
+
+	filesResult := readPackageFiles(loaded.path)
+
+	if filesResult.IsErr() {
+		return fail(stderr, filesResult.UnwrapErr())
+	}
+
+	return finish(stdout, stderr, common.format, filesResult.Unwrap())
+}
+
+func runDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	common := new(commonFlags)
+	common.register(flags)
+	redact := flags.Bool("redact", true, "Redact the values of secret keys, such as passwords or tokens.")
+
+	if !parseFlags(flags, args, common, "diff [flags] <package> <package>", 2, 2) {
+		return exitUsage
+	}
+
+	logger := common.newLogger()
+	previousResult := loadPackage(logger, flags.Arg(0))
+
+	if previousResult.IsErr() {
+		return fail(stderr, previousResult.UnwrapErr())
+	}
+
+	previous := previousResult.Unwrap()
+	defer previous.close()
+
+	currentResult := loadPackage(logger, flags.Arg(1))
+
+	if currentResult.IsErr() {
+		return fail(stderr, currentResult.UnwrapErr())
+	}
+
+	current := currentResult.Unwrap()
+	defer current.close()
+
+	secretKeyPattern := config.DefaultSecretKeyPattern
+
+	if !*redact {
+		secretKeyPattern = nil
+	}
+
+	changesResult := config.DiffPackages(previous.path, current.path, secretKeyPattern, nil)
+
+	if changesResult.IsErr() {
+		return fail(stderr, changesResult.UnwrapErr())
+	}
+
+	changes := make([]diffChange, 0, len(changesResult.Unwrap()))
+
+	for _, change := range changesResult.Unwrap() {
+		changes = append(changes, diffChange{Type: change.Type, File: change.FilePath, Key: change.KeyPath, OldValue: change.OldValue, NewValue: change.NewValue})
+	}
+
+	return finish(stdout, stderr, common.format, changes)
+}
+
+func runValidate(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, common, source := newFlagSet("validate", stderr)
+	schemaPath := flags.String("schema", "", "Schema file, mapping each file of the package to its schema.")
+	overlays := flags.String("overlays", "", "Comma separated overlays merged on top of each file before validating it.")
+
+	if !parseFlags(flags, args, common, "validate -schema <file> [flags] [package]", 0, 1) {
+		return exitUsage
+	}
+
+	if len(*schemaPath) == 0 {
+		return fail(stderr, *core.NewError(core.InvalidInput, "'-schema' is required"))
+	}
+
+	if flags.NArg() == 1 {
+		source.packagePath = flags.Arg(0)
+	}
+
+	if result := source.validate(); result.IsErr() {
+		return fail(stderr, result.UnwrapErr())
+	}
+
+	validatorResult := config.LoadSchemaValidator(*schemaPath)
+
+	if validatorResult.IsErr() {
+		return fail(stderr, validatorResult.UnwrapErr())
+	}
+
+	validator := validatorResult.Unwrap()
+
+	if len(*overlays) > 0 {
+		validator.SetOverlays(strings.Split(*overlays, ","), config.ReplaceLists)
+	}
+
+	loadResult := source.load(common.newLogger())
+
+	if loadResult.IsErr() {
+		return fail(stderr, loadResult.UnwrapErr())
+	}
+
+	loaded := loadResult.Unwrap()
+	defer loaded.close()
+
+	report := validationReport{Valid: true}
+
+	for _, violation := range validator.Violations(loaded.path) {
+		report.Valid = false
+		report.Violations = append(report.Violations, violationSummary{File: violation.FilePath, Key: violation.KeyPath, Message: violation.Message})
+	}
+
+	exitCode := finish(stdout, stderr, common.format, report)
+
+	if exitCode == exitSuccess && !report.Valid {
+		return exitFailure
+	}
+
+	return exitCode
+}
+
+func runPack(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("pack", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	common := new(commonFlags)
+	common.register(flags)
+	output := flags.String("output", "", "File the package is written to. Defaults to '<directory>.zip'.")
+
+	if !parseFlags(flags, args, common, "pack [flags] <directory>", 1, 1) {
+		return exitUsage
+	}
+
+	directory := flags.Arg(0)
+	packResult := config.PackDirectory(directory)
+
+	if packResult.IsErr() {
+		return fail(stderr, packResult.UnwrapErr())
+	}
+
+	if len(*output) == 0 {
+		*output = filepath.Clean(directory) + ".zip"
+	}
+
+	data := packResult.Unwrap()
+
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return fail(stderr, *core.NewError(core.IOFailure, fmt.Sprintf("failed to write package '%s': %s", *output, err)))
+	}
+
+	return finish(stdout, stderr, common.format, packageSummary{Path: *output, Digest: config.ComputePackageDigest(data), Size: len(data)})
+}
+
+func newFlagSet(name string, stderr io.Writer) (*flag.FlagSet, *commonFlags, *sourceFlags) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	common := new(commonFlags)
+	common.register(flags)
+	source := new(sourceFlags)
+	source.register(flags)
+
+	return flags, common, source
+}
+
+// parseFlags parses the arguments and checks the number of positional arguments and the output format,
+// reporting any problem along with the command's usage, i.e. 'get [flags] <file> <key>'.
+func parseFlags(flags *flag.FlagSet, args []string, common *commonFlags, usage string, minArgs int, maxArgs int) bool {
+	if err := flags.Parse(args); err != nil {
+		return false
+	}
+
+	if flags.NArg() < minArgs || flags.NArg() > maxArgs {
+		_, _ = fmt.Fprintf(flags.Output(), "usage: cuplan-config %s\n", usage)
+
+		return false
+	}
+
+	if result := validateFormat(common.format); result.IsErr() {
+		_, _ = fmt.Fprintf(flags.Output(), "error: %s\n", result.UnwrapErr())
+
+		return false
+	}
+
+	return true
+}
+
+func finish(stdout io.Writer, stderr io.Writer, format string, value any) int {
+	if result := writeOutput(stdout, format, value); result.IsErr() {
+		return fail(stderr, result.UnwrapErr())
+	}
+
+	return exitSuccess
+}
+
+// readPackageFiles reads every file of the package, keyed by its relative path. YAML files are decoded, so they
+// are written in the requested format; any other file is kept as text.
+func readPackageFiles(packagePath string) core.Result[map[string]any, core.Error] {
+	files := make(map[string]any)
+	paths := make([]string, 0)
+
+	// The package path may be a link, i.e. a config.Client's working path, which WalkDir would not follow.
+	packagePath, err := filepath.EvalSymlinks(packagePath)
+
+	if err != nil {
+		return core.Err[map[string]any, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read package: %s", err)))
+	}
+
+	err = filepath.WalkDir(packagePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return core.Err[map[string]any, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read package: %s", err)))
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		relativePath, _ := filepath.Rel(packagePath, path)
+		content, err := os.ReadFile(path)
+
+		if err != nil {
+			return core.Err[map[string]any, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read file '%s': %s", relativePath, err)))
+		}
+
+		extension := strings.ToLower(filepath.Ext(path))
+
+		var document any
+
+		if (extension == ".yaml" || extension == ".yml") && yaml.Unmarshal(content, &document) == nil {
+			files[filepath.ToSlash(relativePath)] = document
+		} else {
+			files[filepath.ToSlash(relativePath)] = string(content)
+		}
+	}
+
+	return core.Ok[map[string]any, core.Error](files)
+}
@@ -0,0 +1,88 @@
+// Command cuplan-config inspects and validates configuration packages.
+//
+// Usage:
+//
+//	cuplan-config <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	fetch     downloads a configuration package
+//	get       prints the value located at a key within a file of a configuration package
+//	dump      prints every file of a configuration package
+//	diff      prints the changes between two configuration packages
+//	validate  validates a configuration package against a schema file
+//	pack      packs a directory into a configuration package
+//
+// Packages are downloaded from a configuration server when the host is an 'http' or 'https' url, or read from the
+// directory layout '<host>/<stage>/<environment>/<component>' otherwise. The access token is read from the
+// CUPLAN_CONFIG_TOKEN environment variable unless the '-token' flag is set.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = []command{
+	{name: "fetch", description: "Downloads a configuration package.", run: runFetch},
+	{name: "get", description: "Prints the value located at the key within the file.", run: runGet},
+	{name: "dump", description: "Prints every file of a configuration package.", run: runDump},
+	{name: "diff", description: "Prints the changes between two configuration packages.", run: runDiff},
+	{name: "validate", description: "Validates a configuration package against a schema file.", run: runValidate},
+	{name: "pack", description: "Packs a directory into a configuration package.", run: runPack},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+
+		return exitUsage
+	}
+
+	for _, command := range commands {
+		if command.name == args[0] {
+			return command.run(args[1:], stdout, stderr)
+		}
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+
+		return exitSuccess
+	}
+
+	_, _ = fmt.Fprintf(stderr, "unknown command '%s'\n\n", args[0])
+	printUsage(stderr)
+
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: cuplan-config <command> [flags] [arguments]")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Commands:")
+
+	for _, command := range commands {
+		_, _ = fmt.Fprintf(w, "  %-10s%s\n", command.name, command.description)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Run 'cuplan-config <command> -h' for the command's flags.")
+}
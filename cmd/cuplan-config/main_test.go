@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const stage = "dummy"
+const environment = "development"
+const component = "dummy"
+
+type CommandsTestSuite struct {
+	suite.Suite
+	Root        string
+	PackagePath string
+	Stdout      *bytes.Buffer
+	Stderr      *bytes.Buffer
+}
+
+func TestCommandsTestSuite(t *testing.T) {
+	suite.Run(t, new(CommandsTestSuite))
+}
+
+func (c *CommandsTestSuite) SetupTest() {
+	c.Root = uuid.New().String()
+	c.PackagePath = filepath.Join(c.Root, stage, environment, component)
+	_ = os.MkdirAll(c.PackagePath, os.ModePerm)
+	_ = os.WriteFile(filepath.Join(c.PackagePath, "application.yaml"), []byte("Server:\n  Host: localhost\n  Port: 8080\n"), 0644)
+	c.Stdout = new(bytes.Buffer)
+	c.Stderr = new(bytes.Buffer)
+}
+
+func (c *CommandsTestSuite) TearDownTest() {
+	_ = os.RemoveAll(c.Root)
+}
+
+func (c *CommandsTestSuite) TestGet_DirectorySource_PrintsValue() {
+	exitCode := c.Run("get", "-host", c.Root, "-stage", stage, "-environment", environment, "-component", component, "-format", "json", "application.yaml", "Server:Port")
+
+	assert.Equal(c.T(), exitSuccess, exitCode, c.Stderr.String())
+	assert.Equal(c.T(), "8080\n", c.Stdout.String())
+}
+
+func (c *CommandsTestSuite) TestGet_MissingSource_UsageError() {
+	exitCode := c.Run("get", "application.yaml", "Server:Port")
+
+	assert.Equal(c.T(), exitUsage, exitCode)
+	assert.Contains(c.T(), c.Stderr.String(), "-package")
+}
+
+func (c *CommandsTestSuite) TestPack_ThenDump_PrintsFiles() {
+	packageFile := filepath.Join(c.Root, "package.zip")
+
+	packExitCode := c.Run("pack", "-output", packageFile, c.PackagePath)
+	c.Stdout.Reset()
+	dumpExitCode := c.Run("dump", "-package", packageFile, "-format", "json")
+
+	var files map[string]any
+	_ = json.Unmarshal(c.Stdout.Bytes(), &files)
+	assert.Equal(c.T(), exitSuccess, packExitCode, c.Stderr.String())
+	assert.Equal(c.T(), exitSuccess, dumpExitCode, c.Stderr.String())
+	assert.Equal(c.T(), map[string]any{"application.yaml": map[string]any{"Server": map[string]any{"Host": "localhost", "Port": float64(8080)}}}, files)
+}
+
+func (c *CommandsTestSuite) TestFetch_WritesPackage() {
+	output := filepath.Join(c.Root, "fetched.zip")
+
+	exitCode := c.Run("fetch", "-host", c.Root, "-stage", stage, "-environment", environment, "-component", component, "-output", output)
+
+	_, err := os.Stat(output)
+	assert.Equal(c.T(), exitSuccess, exitCode, c.Stderr.String())
+	assert.NoError(c.T(), err)
+	assert.Contains(c.T(), c.Stdout.String(), "digest:")
+}
+
+func (c *CommandsTestSuite) TestDiff_PrintsRedactedChanges() {
+	otherPath := filepath.Join(c.Root, "other")
+	_ = os.MkdirAll(otherPath, os.ModePerm)
+	_ = os.WriteFile(filepath.Join(otherPath, "application.yaml"), []byte("Server:\n  Host: remote\n  Port: 8080\n  Password: secret\n"), 0644)
+
+	exitCode := c.Run("diff", "-format", "json", c.PackagePath, otherPath)
+
+	var changes []diffChange
+	_ = json.Unmarshal(c.Stdout.Bytes(), &changes)
+	assert.Equal(c.T(), exitSuccess, exitCode, c.Stderr.String())
+	assert.Equal(c.T(), []diffChange{
+		{Type: "changed", File: "application.yaml", Key: "Server:Host", OldValue: "localhost", NewValue: "remote"},
+		{Type: "added", File: "application.yaml", Key: "Server:Password", NewValue: "[REDACTED]"},
+	}, changes)
+}
+
+func (c *CommandsTestSuite) TestValidate_InvalidPackage_ReportsViolations() {
+	schemaPath := filepath.Join(c.Root, "schema.yaml")
+	_ = os.WriteFile(schemaPath, []byte("application.yaml:\n  type: object\n  required: [Database]\n"), 0644)
+
+	exitCode := c.Run("validate", "-schema", schemaPath, c.PackagePath)
+
+	assert.Equal(c.T(), exitFailure, exitCode)
+	assert.Contains(c.T(), c.Stdout.String(), "valid: false")
+	assert.Contains(c.T(), c.Stdout.String(), "Database")
+}
+
+func (c *CommandsTestSuite) TestRun_UnknownCommand_UsageError() {
+	exitCode := c.Run("unknown")
+
+	assert.Equal(c.T(), exitUsage, exitCode)
+	assert.Contains(c.T(), c.Stderr.String(), "unknown command")
+}
+
+func (c *CommandsTestSuite) Run(args ...string) int {
+	return run(args, c.Stdout, c.Stderr)
+}
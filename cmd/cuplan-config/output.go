@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"gopkg.in/yaml.v3"
+	"io"
+)
+
+const (
+	yamlFormat = "yaml"
+	jsonFormat = "json"
+)
+
+func validateFormat(format string) core.Result[core.Empty, core.Error] {
+	if format != yamlFormat && format != jsonFormat {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("unknown format '%s', expected '%s' or '%s'", format, yamlFormat, jsonFormat)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// writeOutput writes the value to the writer encoded in the format.
+func writeOutput(w io.Writer, format string, value any) core.Result[core.Empty, core.Error] {
+	var output []byte
+	var err error
+
+	if format == jsonFormat {
+		output, err = json.MarshalIndent(value, "", "  ")
+		output = append(output, '\n')
+	} else {
+		output, err = yaml.Marshal(value)
+	}
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to encode output as %s: %s", format, err)))
+	}
+
+	if _, err := w.Write(output); err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to write output: %s", err)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// fail writes the error to the writer and returns the exit code which reports it.
+func fail(w io.Writer, err core.Error) int {
+	_, _ = fmt.Fprintf(w, "error: %s\n", err)
+
+	if err.ErrorKind == core.InvalidInput {
+		return exitUsage
+	}
+
+	return exitFailure
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const tokenEnvironmentVariable = "CUPLAN_CONFIG_TOKEN"
+
+// commonFlags are the flags shared by every command.
+type commonFlags struct {
+	format  string
+	verbose bool
+}
+
+func (c *commonFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&c.format, "format", yamlFormat, "Output format, either 'yaml' or 'json'.")
+	flags.BoolVar(&c.verbose, "verbose", false, "Log what is being done to the standard error.")
+}
+
+func (c *commonFlags) newLogger() *zap.Logger {
+	if !c.verbose {
+		return zap.NewNop()
+	}
+
+	logger, err := zap.NewDevelopment()
+
+	if err != nil {
+		return zap.NewNop()
+	}
+
+	return logger
+}
+
+// sourceFlags locate a configuration package, either remote or local.
+type sourceFlags struct {
+	host        string
+	stage       string
+	environment string
+	component   string
+	version     string
+	token       string
+	timeout     time.Duration
+	packagePath string
+}
+
+func (s *sourceFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&s.host, "host", "", "Configuration server's url, or root directory of the configuration packages.")
+	flags.StringVar(&s.stage, "stage", "", "Stage of the configuration package.")
+	flags.StringVar(&s.environment, "environment", "", "Environment of the configuration package.")
+	flags.StringVar(&s.component, "component", "", "Component of the configuration package.")
+	flags.StringVar(&s.version, "version", "", "Version of the configuration package; the latest one when empty.")
+	flags.StringVar(&s.token, "token", "", "Access token for the configuration server; defaults to $"+tokenEnvironmentVariable+".")
+	flags.DurationVar(&s.timeout, "timeout", 30*time.Second, "Timeout of the download.")
+	flags.StringVar(&s.packagePath, "package", "", "Local package file or directory to read instead of downloading one.")
+}
+
+func (s *sourceFlags) validate() core.Result[core.Empty, core.Error] {
+	if len(s.packagePath) > 0 {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	if len(s.host) == 0 || len(s.stage) == 0 || len(s.environment) == 0 || len(s.component) == 0 {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, "either '-package' or '-host', '-stage', '-environment' and '-component' are required"))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+func (s *sourceFlags) isServer() bool {
+	return strings.HasPrefix(s.host, "http://") || strings.HasPrefix(s.host, "https://")
+}
+
+func (s *sourceFlags) newDownloader(logger *zap.Logger) config.Downloader {
+	if !s.isServer() {
+		return config.NewDirectoryDownloader()
+	}
+
+	token := s.token
+
+	if len(token) == 0 {
+		token = os.Getenv(tokenEnvironmentVariable)
+	}
+
+	return config.NewServerDownloader(logger, token, s.timeout)
+}
+
+// download downloads the package's data and returns it along with its version, which may be unknown.
+func (s *sourceFlags) download(logger *zap.Logger) core.Result[config.VersionedPackage, core.Error] {
+	downloader := s.newDownloader(logger)
+
+	if versionedDownloader, ok := downloader.(config.VersionedDownloader); ok {
+		return versionedDownloader.DownloadVersion(s.host, s.stage, s.environment, s.component, s.version)
+	}
+
+	if len(s.version) > 0 {
+		return core.Err[config.VersionedPackage, core.Error](*core.NewError(core.InvalidInput, "'-version' requires a configuration server"))
+	}
+
+	downloadResult := downloader.Download(s.host, s.stage, s.environment, s.component)
+
+	if downloadResult.IsErr() {
+		return core.Err[config.VersionedPackage, core.Error](downloadResult.UnwrapErr())
+	}
+
+	return core.Ok[config.VersionedPackage, core.Error](config.VersionedPackage{Data: downloadResult.Unwrap()})
+}
+
+// loadedPackage is a configuration package extracted within a directory, which is deleted by close.
+type loadedPackage struct {
+	path  string
+	close func()
+}
+
+// load makes the package available within a directory: a local directory is used as is, a local package file is
+// extracted and a remote package is loaded through a config.Client.
+func (s *sourceFlags) load(logger *zap.Logger) core.Result[loadedPackage, core.Error] {
+	if len(s.packagePath) > 0 {
+		return loadPackage(logger, s.packagePath)
+	}
+
+	workingPath := filepath.Join(os.TempDir(), "cuplan-config-"+uuid.New().String())
+	provider := config.NewFileProvider(workingPath, core.NewCache(time.Minute), time.Minute)
+	client := config.NewClient(logger, s.host, s.stage, s.environment, s.component, workingPath, s.newDownloader(logger), config.NewAutoExtractor(logger), provider)
+
+	var loadResult core.Result[core.Empty, core.Error]
+
+	if len(s.version) > 0 {
+		loadResult = client.Pin(s.version)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+
+		loadResult = client.Start(ctx)
+	}
+
+	if loadResult.IsErr() {
+		client.Close()
+
+		return core.Err[loadedPackage, core.Error](loadResult.UnwrapErr())
+	}
+
+	return core.Ok[loadedPackage, core.Error](loadedPackage{path: workingPath, close: client.Close})
+}
+
+// loadPackage makes a local package file or directory available within a directory.
+func loadPackage(logger *zap.Logger, packagePath string) core.Result[loadedPackage, core.Error] {
+	info, err := os.Stat(packagePath)
+
+	if err != nil {
+		return core.Err[loadedPackage, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find package '%s': %s", packagePath, err)))
+	}
+
+	if info.IsDir() {
+		return core.Ok[loadedPackage, core.Error](loadedPackage{path: packagePath, close: func() {}})
+	}
+
+	data, err := os.ReadFile(packagePath)
+
+	if err != nil {
+		return core.Err[loadedPackage, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read package '%s': %s", packagePath, err)))
+	}
+
+	extractionPath := filepath.Join(os.TempDir(), "cuplan-config-"+uuid.New().String())
+	extractResult := config.NewAutoExtractor(logger).Extract(data, extractionPath)
+
+	if extractResult.IsErr() {
+		_ = config.RemoveExtraction(extractionPath)
+
+		return core.Err[loadedPackage, core.Error](extractResult.UnwrapErr())
+	}
+
+	return core.Ok[loadedPackage, core.Error](loadedPackage{path: extractionPath, close: func() {
+		_ = config.RemoveExtraction(extractionPath)
+	}})
+}
@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/go-chi/chi/v5 v5.0.11
 	github.com/google/uuid v1.5.0
+	github.com/klauspost/compress v1.17.11
 	github.com/lestrrat-go/jwx v1.2.28
 	github.com/stretchr/testify v1.8.4
 	go.uber.org/zap v1.26.0
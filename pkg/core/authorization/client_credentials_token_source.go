@@ -0,0 +1,247 @@
+package authorization
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/secret"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRefreshMargin = 30 * time.Second
+
+// maxErrorBodySize limits how much of the token endpoint's error response is included within the error.
+const maxErrorBodySize = 1024
+
+// maxTokenResponseSize limits how much of the token endpoint's response is read.
+const maxTokenResponseSize = 64 * 1024
+
+// defaultTokenLifetime is the lifetime assumed for access tokens whose response has no 'expires_in'.
+const defaultTokenLifetime = 5 * time.Minute
+
+type clientCredentialsResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// ClientCredentialsTokenSource obtains access tokens through the OAuth2 client credentials grant.
+// The client id and secret are retrieved from a secret.Provider and the access token is cached until shortly
+// before it expires. Concurrent callers share a single in-flight request to the token endpoint.
+type ClientCredentialsTokenSource struct {
+	logger               *zap.Logger
+	tokenEndpoint        string
+	secretProvider       secret.Provider
+	clientIdSecretId     string
+	clientSecretSecretId string
+	audience             string
+	scopes               []string
+	refreshMargin        time.Duration
+	httpClient           *http.Client
+	mutex                sync.Mutex
+	token                string
+	// renewAt is when the cached access token is renewed: the refresh margin before it expires, but never
+	// before half of its lifetime.
+	renewAt time.Time
+	// inFlight is the request to the token endpoint shared by the concurrent callers of Token and Refresh.
+	inFlight *tokenRequest
+}
+
+// issuedToken is an access token issued by the token endpoint.
+type issuedToken struct {
+	accessToken string
+	lifetime    time.Duration
+}
+
+// tokenRequest is the outcome of a request to the token endpoint, available once done is closed.
+type tokenRequest struct {
+	done   chan struct{}
+	result core.Result[string, core.Error]
+}
+
+// NewClientCredentialsTokenSource creates an instance of ClientCredentialsTokenSource.
+//
+// * tokenEndpoint - OAuth2 token endpoint, i.e. 'https://tenant.auth0.com/oauth/token'.
+//
+// * clientIdSecretId, clientSecretSecretId - Ids of the secrets which contain the client's id and secret.
+//
+// * audience - Audience of the access token, it's not sent if empty.
+//
+// * scopes - Scopes of the access token, they're not sent if empty.
+func NewClientCredentialsTokenSource(logger *zap.Logger,
+	tokenEndpoint string,
+	secretProvider secret.Provider,
+	clientIdSecretId string,
+	clientSecretSecretId string,
+	audience string,
+	scopes []string,
+	timeout time.Duration) *ClientCredentialsTokenSource {
+	c := new(ClientCredentialsTokenSource)
+
+	c.logger = logger
+	c.tokenEndpoint = tokenEndpoint
+	c.secretProvider = secretProvider
+	c.clientIdSecretId = clientIdSecretId
+	c.clientSecretSecretId = clientSecretSecretId
+	c.audience = audience
+	c.scopes = scopes
+	c.refreshMargin = defaultRefreshMargin
+	c.httpClient = &http.Client{Timeout: timeout}
+
+	return c
+}
+
+// SetRefreshMargin sets how long before its expiration an access token is renewed. The margin is clamped to half
+// of the access token's lifetime, so short-lived access tokens are still reused.
+func (c *ClientCredentialsTokenSource) SetRefreshMargin(refreshMargin time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.refreshMargin = refreshMargin
+}
+
+func (c *ClientCredentialsTokenSource) Token() core.Result[string, core.Error] {
+	c.mutex.Lock()
+
+	if len(c.token) > 0 && time.Now().Before(c.renewAt) {
+		token := c.token
+		c.mutex.Unlock()
+
+		return core.Ok[string, core.Error](token)
+	}
+
+	return c.shareRequest()
+}
+
+// Refresh requests a new access token, unless a request is already in flight, whose access token is returned instead.
+func (c *ClientCredentialsTokenSource) Refresh() core.Result[string, core.Error] {
+	c.mutex.Lock()
+
+	return c.shareRequest()
+}
+
+// shareRequest joins the in-flight request to the token endpoint or starts one; the caller must hold the mutex,
+// which is released.
+func (c *ClientCredentialsTokenSource) shareRequest() core.Result[string, core.Error] {
+	if c.inFlight != nil {
+		inFlight := c.inFlight
+		c.mutex.Unlock()
+		<-inFlight.done
+
+		return inFlight.result
+	}
+
+	inFlight := &tokenRequest{done: make(chan struct{})}
+	c.inFlight = inFlight
+	c.token = ""
+	c.mutex.Unlock()
+
+	tokenResult := c.requestToken()
+
+	c.mutex.Lock()
+	c.inFlight = nil
+
+	if tokenResult.IsOk() {
+		c.token = tokenResult.Unwrap().accessToken
+		c.renewAt = c.renewalOf(tokenResult.Unwrap().lifetime)
+		inFlight.result = core.Ok[string, core.Error](c.token)
+	} else {
+		inFlight.result = core.Err[string, core.Error](tokenResult.UnwrapErr())
+	}
+
+	c.mutex.Unlock()
+	close(inFlight.done)
+
+	return inFlight.result
+}
+
+func (c *ClientCredentialsTokenSource) requestToken() core.Result[issuedToken, core.Error] {
+	clientIdResult := c.secretProvider.Get(c.clientIdSecretId)
+
+	if clientIdResult.IsErr() {
+		return core.Err[issuedToken, core.Error](*core.NewError(core.TokenRetrievalFailure, fmt.Sprintf("failed to get client id: %s", clientIdResult.UnwrapErr().Message)))
+	}
+
+	clientSecretResult := c.secretProvider.Get(c.clientSecretSecretId)
+
+	if clientSecretResult.IsErr() {
+		return core.Err[issuedToken, core.Error](*core.NewError(core.TokenRetrievalFailure, fmt.Sprintf("failed to get client secret: %s", clientSecretResult.UnwrapErr().Message)))
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientIdResult.Unwrap())
+	form.Set("client_secret", clientSecretResult.Unwrap())
+
+	if len(c.audience) > 0 {
+		form.Set("audience", c.audience)
+	}
+
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+
+	response, err := c.httpClient.PostForm(c.tokenEndpoint, form)
+
+	if err != nil {
+		return core.Err[issuedToken, core.Error](*core.NewError(core.TokenRetrievalFailure, fmt.Sprintf("failed to request access token: %v", err)))
+	}
+
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			c.logger.Warn("Failed to close response's body.", zap.String("err", err.Error()))
+		}
+	}(response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+
+		return core.Err[issuedToken, core.Error](*core.NewError(core.TokenRetrievalFailure, fmt.Sprintf("token endpoint returned an unexpected status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxTokenResponseSize+1))
+
+	if err != nil {
+		return core.Err[issuedToken, core.Error](*core.NewError(core.TokenRetrievalFailure, fmt.Sprintf("failed to read body of response: %v", err)))
+	}
+
+	if len(body) > maxTokenResponseSize {
+		return core.Err[issuedToken, core.Error](*core.NewError(core.TokenRetrievalFailure, fmt.Sprintf("token response exceeds %d bytes", maxTokenResponseSize)))
+	}
+
+	var tokenResponse clientCredentialsResponse
+
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return core.Err[issuedToken, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to parse token response: %v", err)))
+	}
+
+	if len(tokenResponse.AccessToken) == 0 {
+		return core.Err[issuedToken, core.Error](*core.NewError(core.TokenRetrievalFailure, "token response does not contain an access token"))
+	}
+
+	lifetime := defaultTokenLifetime
+
+	if tokenResponse.ExpiresIn > 0 {
+		lifetime = time.Duration(tokenResponse.ExpiresIn) * time.Second
+	}
+
+	return core.Ok[issuedToken, core.Error](issuedToken{accessToken: tokenResponse.AccessToken, lifetime: lifetime})
+}
+
+// renewalOf returns when an access token issued now with the lifetime is renewed; the caller must hold the mutex.
+func (c *ClientCredentialsTokenSource) renewalOf(lifetime time.Duration) time.Time {
+	refreshMargin := c.refreshMargin
+
+	if refreshMargin > lifetime/2 {
+		refreshMargin = lifetime / 2
+	}
+
+	return time.Now().Add(lifetime - refreshMargin)
+}
@@ -0,0 +1,191 @@
+package authorization
+
+import (
+	"encoding/json"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const clientIdSecretId = "client-id"
+const clientSecretSecretId = "client-secret"
+
+type mapSecretProvider map[string]string
+
+func (m mapSecretProvider) Get(secretId string) core.Result[string, core.Error] {
+	value, exists := m[secretId]
+
+	if !exists {
+		return core.Err[string, core.Error](*core.NewError(core.NotFound, "secret not found"))
+	}
+
+	return core.Ok[string, core.Error](value)
+}
+
+type ClientCredentialsTokenSourceTestSuite struct {
+	suite.Suite
+	Requests  atomic.Int32
+	ExpiresIn int
+	Delay     time.Duration
+	Server    *httptest.Server
+	Secrets   mapSecretProvider
+}
+
+func TestClientCredentialsTokenSourceTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientCredentialsTokenSourceTestSuite))
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) SetupTest() {
+	c.Requests.Store(0)
+	c.ExpiresIn = 3600
+	c.Delay = 0
+	c.Secrets = mapSecretProvider{clientIdSecretId: "id", clientSecretSecretId: "secret"}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests := c.Requests.Add(1)
+		time.Sleep(c.Delay)
+		_ = r.ParseForm()
+
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "id" || r.Form.Get("client_secret") != "secret" || r.Form.Get("audience") != "cp-config" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(clientCredentialsResponse{AccessToken: "token-" + string(rune('0'+requests)), ExpiresIn: c.ExpiresIn, TokenType: "Bearer"})
+	}))
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TearDownTest() {
+	c.Server.Close()
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestToken_ValidCredentials_ReturnsToken() {
+	tokenSource := c.newTokenSource()
+
+	result := tokenSource.Token()
+
+	assert.True(c.T(), result.IsOk())
+	assert.Equal(c.T(), "token-1", result.Unwrap())
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestToken_CachedToken_ReturnsSameToken() {
+	tokenSource := c.newTokenSource()
+
+	_ = tokenSource.Token()
+	result := tokenSource.Token()
+
+	assert.Equal(c.T(), "token-1", result.Unwrap())
+	assert.Equal(c.T(), int32(1), c.Requests.Load())
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestToken_TokenAboutToExpire_RenewsToken() {
+	c.ExpiresIn = 1
+	tokenSource := c.newTokenSource()
+
+	_ = tokenSource.Token()
+	time.Sleep(600 * time.Millisecond)
+	result := tokenSource.Token()
+
+	assert.Equal(c.T(), "token-2", result.Unwrap())
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestToken_LifetimeShorterThanRefreshMargin_ReturnsSameToken() {
+	c.ExpiresIn = 10
+	tokenSource := c.newTokenSource()
+
+	_ = tokenSource.Token()
+	result := tokenSource.Token()
+
+	assert.Equal(c.T(), "token-1", result.Unwrap())
+	assert.Equal(c.T(), int32(1), c.Requests.Load())
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestToken_NoExpiration_ReturnsSameToken() {
+	c.ExpiresIn = 0
+	tokenSource := c.newTokenSource()
+
+	_ = tokenSource.Token()
+	result := tokenSource.Token()
+
+	assert.Equal(c.T(), "token-1", result.Unwrap())
+	assert.Equal(c.T(), int32(1), c.Requests.Load())
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestRefresh_CachedToken_RenewsToken() {
+	tokenSource := c.newTokenSource()
+
+	_ = tokenSource.Token()
+	result := tokenSource.Refresh()
+
+	assert.Equal(c.T(), "token-2", result.Unwrap())
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestRefresh_ConcurrentCalls_ShareRequest() {
+	c.Delay = 100 * time.Millisecond
+	tokenSource := c.newTokenSource()
+	results := make([]string, 5)
+	waitGroup := sync.WaitGroup{}
+
+	for i := range results {
+		waitGroup.Add(1)
+
+		go func(i int) {
+			defer waitGroup.Done()
+			results[i] = tokenSource.Refresh().Unwrap()
+		}(i)
+	}
+
+	waitGroup.Wait()
+
+	assert.Equal(c.T(), int32(1), c.Requests.Load())
+	assert.Equal(c.T(), []string{"token-1", "token-1", "token-1", "token-1", "token-1"}, results)
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestToken_OversizedResponse_Error() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"access_token":"` + strings.Repeat("a", maxTokenResponseSize) + `"}`))
+	}))
+	defer server.Close()
+	logger, _ := zap.NewDevelopment()
+	tokenSource := NewClientCredentialsTokenSource(logger, server.URL, c.Secrets, clientIdSecretId, clientSecretSecretId, "", nil, time.Second)
+
+	result := tokenSource.Token()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.TokenRetrievalFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestToken_MissingSecret_Error() {
+	delete(c.Secrets, clientSecretSecretId)
+	tokenSource := c.newTokenSource()
+
+	result := tokenSource.Token()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.TokenRetrievalFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) TestToken_RejectedCredentials_Error() {
+	c.Secrets[clientSecretSecretId] = "wrong"
+	tokenSource := c.newTokenSource()
+
+	result := tokenSource.Token()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.TokenRetrievalFailure, result.UnwrapErr().ErrorKind)
+	assert.Contains(c.T(), result.UnwrapErr().Message, "invalid_client")
+}
+
+func (c *ClientCredentialsTokenSourceTestSuite) newTokenSource() *ClientCredentialsTokenSource {
+	logger, _ := zap.NewDevelopment()
+
+	return NewClientCredentialsTokenSource(logger, c.Server.URL, c.Secrets, clientIdSecretId, clientSecretSecretId, "cp-config", nil, time.Second)
+}
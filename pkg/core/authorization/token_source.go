@@ -0,0 +1,32 @@
+package authorization
+
+import "github.com/simpleg-eu/cuplan_core/pkg/core"
+
+// TokenSource provides access tokens.
+type TokenSource interface {
+	// Token returns a valid access token, which may have been cached.
+	Token() core.Result[string, core.Error]
+
+	// Refresh discards any cached access token and returns a new one.
+	Refresh() core.Result[string, core.Error]
+}
+
+// StaticTokenSource always provides the same access token.
+type StaticTokenSource struct {
+	token string
+}
+
+func NewStaticTokenSource(token string) *StaticTokenSource {
+	s := new(StaticTokenSource)
+	s.token = token
+
+	return s
+}
+
+func (s *StaticTokenSource) Token() core.Result[string, core.Error] {
+	return core.Ok[string, core.Error](s.token)
+}
+
+func (s *StaticTokenSource) Refresh() core.Result[string, core.Error] {
+	return core.Ok[string, core.Error](s.token)
+}
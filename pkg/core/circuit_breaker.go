@@ -0,0 +1,112 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState string
+
+const (
+	// CircuitClosed lets every operation through.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpened short-circuits every operation until the open duration elapses.
+	CircuitOpened CircuitState = "open"
+	// CircuitHalfOpen lets a single operation through to check whether the failures are over.
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreaker short-circuits operations after they have failed repeatedly, giving the failing dependency
+// time to recover.
+type CircuitBreaker struct {
+	mutex               sync.Mutex
+	failureThreshold    int
+	openDuration        time.Duration
+	state               CircuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	halfOpenInFlight    bool
+}
+
+// NewCircuitBreaker creates an instance of CircuitBreaker which opens after the specified number of consecutive
+// failures and stays open for the specified duration.
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	breaker := new(CircuitBreaker)
+
+	breaker.failureThreshold = failureThreshold
+	breaker.openDuration = openDuration
+	breaker.state = CircuitClosed
+
+	return breaker
+}
+
+// Allow returns whether an operation can be executed. Every allowed operation must be followed by a call to
+// either RecordSuccess, RecordFailure or Release.
+func (c *CircuitBreaker) Allow() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch c.state {
+	case CircuitOpened:
+		if time.Since(c.openedAt) < c.openDuration {
+			return false
+		}
+
+		c.state = CircuitHalfOpen
+		c.halfOpenInFlight = true
+
+		return true
+	case CircuitHalfOpen:
+		if c.halfOpenInFlight {
+			return false
+		}
+
+		c.halfOpenInFlight = true
+
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess closes the circuit.
+func (c *CircuitBreaker) RecordSuccess() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.state = CircuitClosed
+	c.consecutiveFailures = 0
+	c.halfOpenInFlight = false
+}
+
+// Release lets another operation through a half open circuit without recording an outcome, for operations whose
+// outcome is only known later, if ever.
+func (c *CircuitBreaker) Release() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.halfOpenInFlight = false
+}
+
+// RecordFailure opens the circuit if the failure threshold has been reached or if the circuit was half open.
+func (c *CircuitBreaker) RecordFailure() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.consecutiveFailures++
+	c.halfOpenInFlight = false
+
+	if c.state == CircuitHalfOpen || c.consecutiveFailures >= c.failureThreshold {
+		c.state = CircuitOpened
+		c.openedAt = time.Now()
+	}
+}
+
+// State returns the current state of the circuit.
+func (c *CircuitBreaker) State() CircuitState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.state
+}
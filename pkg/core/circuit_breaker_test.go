@@ -0,0 +1,63 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_ConsecutiveFailures_Opens(t *testing.T) {
+	breaker := NewCircuitBreaker(2, time.Hour)
+
+	breaker.RecordFailure()
+	assert.True(t, breaker.Allow())
+	breaker.RecordFailure()
+
+	assert.Equal(t, CircuitOpened, breaker.State())
+	assert.False(t, breaker.Allow())
+}
+
+func TestCircuitBreaker_Success_ResetsFailures(t *testing.T) {
+	breaker := NewCircuitBreaker(2, time.Hour)
+
+	breaker.RecordFailure()
+	breaker.RecordSuccess()
+	breaker.RecordFailure()
+
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+func TestCircuitBreaker_OpenDurationElapsed_AllowsSingleTrial(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Millisecond)
+	breaker.RecordFailure()
+	time.Sleep(5 * time.Millisecond)
+
+	assert.True(t, breaker.Allow())
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	assert.False(t, breaker.Allow())
+}
+
+func TestCircuitBreaker_HalfOpenRelease_AllowsAnotherTrial(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Millisecond)
+	breaker.RecordFailure()
+	time.Sleep(5 * time.Millisecond)
+	breaker.Allow()
+
+	breaker.Release()
+
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	assert.True(t, breaker.Allow())
+}
+
+func TestCircuitBreaker_HalfOpenFailure_Reopens(t *testing.T) {
+	breaker := NewCircuitBreaker(3, time.Millisecond)
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	time.Sleep(5 * time.Millisecond)
+	breaker.Allow()
+
+	breaker.RecordFailure()
+
+	assert.Equal(t, CircuitOpened, breaker.State())
+}
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const versionsDirectorySuffix = ".versions"
+const previousDirectorySuffix = ".previous"
+const linkInfix = ".link-"
+
+// extractAtomically runs the extraction within a new version directory of the targetPath, which replaces the
+// targetPath's content only once the extraction has succeeded. A targetPath which is already a version directory,
+// i.e. the staging path of a Client, is extracted in place, since its owner swaps it in.
+//
+// The extracted content of a targetPath lives within a version directory, '<targetPath>.versions/<uuid>', and the
+// targetPath itself is a symbolic link to the current version. Replacing the content renames a new link over the
+// targetPath, which is atomic, so the targetPath never goes missing nor shows a half-populated configuration.
+// The replaced version is kept behind the '<targetPath>.previous' link, so it can be rolled back.
+func extractAtomically(targetPath string, extract func(stagingPath string) core.Result[core.Empty, core.Error]) core.Result[core.Empty, core.Error] {
+	targetPath = filepath.Clean(targetPath)
+
+	if isStagingDirectoryPath(targetPath) {
+		extractResult := extract(targetPath)
+
+		if extractResult.IsErr() {
+			_ = os.RemoveAll(targetPath)
+		}
+
+		return extractResult
+	}
+
+	cleanResult := CleanStaleStagingDirectories(targetPath)
+
+	if cleanResult.IsErr() {
+		return cleanResult
+	}
+
+	stagingPath := getStagingDirectoryPath(targetPath)
+	extractResult := extract(stagingPath)
+
+	if extractResult.IsErr() {
+		_ = os.RemoveAll(stagingPath)
+
+		return extractResult
+	}
+
+	replaceResult := replaceDirectory(stagingPath, targetPath)
+
+	if replaceResult.IsErr() {
+		_ = os.RemoveAll(stagingPath)
+	}
+
+	return replaceResult
+}
+
+// replaceDirectory makes the sourcePath the targetPath's content, keeping the replaced content behind
+// '<targetPath>.previous'. The content replaced before, if any, is removed.
+func replaceDirectory(sourcePath string, targetPath string) core.Result[core.Empty, core.Error] {
+	targetPath = filepath.Clean(targetPath)
+	previousPath := getPreviousDirectoryPath(targetPath)
+
+	for _, path := range []string{targetPath, previousPath} {
+		migrateResult := migrateDirectory(targetPath, path)
+
+		if migrateResult.IsErr() {
+			return migrateResult
+		}
+	}
+
+	versionPath := filepath.Clean(sourcePath)
+
+	if filepath.Dir(versionPath) != getVersionsDirectoryPath(targetPath) {
+		versionPath = getStagingDirectoryPath(targetPath)
+
+		if err := os.Rename(sourcePath, versionPath); err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to move '%s' into '%s': %s", sourcePath, versionPath, err)))
+		}
+	}
+
+	currentVersionPath, hasCurrent := readVersionLink(targetPath)
+	previousVersionPath, hasPrevious := readVersionLink(previousPath)
+
+	linkResult := linkVersion(targetPath, versionPath)
+
+	if linkResult.IsErr() {
+		return linkResult
+	}
+
+	if !hasCurrent {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	linkResult = linkVersion(previousPath, currentVersionPath)
+
+	if linkResult.IsErr() {
+		return linkResult
+	}
+
+	if hasPrevious && previousVersionPath != currentVersionPath && previousVersionPath != versionPath {
+		if err := os.RemoveAll(previousVersionPath); err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to remove previous directory '%s': %s", previousVersionPath, err)))
+		}
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// RollbackExtraction swaps the targetPath's content with the one it replaced, which is kept behind
+// '<targetPath>.previous'. Rolling back twice restores the original content.
+func RollbackExtraction(targetPath string) core.Result[core.Empty, core.Error] {
+	targetPath = filepath.Clean(targetPath)
+	previousPath := getPreviousDirectoryPath(targetPath)
+
+	for _, path := range []string{targetPath, previousPath} {
+		migrateResult := migrateDirectory(targetPath, path)
+
+		if migrateResult.IsErr() {
+			return migrateResult
+		}
+	}
+
+	currentVersionPath, hasCurrent := readVersionLink(targetPath)
+	previousVersionPath, hasPrevious := readVersionLink(previousPath)
+
+	if !hasPrevious || !_doesDirectoryExist(previousVersionPath) {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("there is no previous content for '%s'", targetPath)))
+	}
+
+	linkResult := linkVersion(targetPath, previousVersionPath)
+
+	if linkResult.IsErr() {
+		return linkResult
+	}
+
+	if !hasCurrent {
+		if err := os.Remove(previousPath); err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to remove '%s': %s", previousPath, err)))
+		}
+
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	return linkVersion(previousPath, currentVersionPath)
+}
+
+// CleanStaleStagingDirectories removes the version directories of the targetPath which are neither current nor
+// previous, along with the temporary links, which are left behind by interrupted extractions.
+func CleanStaleStagingDirectories(targetPath string) core.Result[core.Empty, core.Error] {
+	targetPath = filepath.Clean(targetPath)
+	parentPath := filepath.Dir(targetPath)
+	linkPrefixes := []string{filepath.Base(targetPath) + linkInfix, filepath.Base(getPreviousDirectoryPath(targetPath)) + linkInfix}
+
+	entries, err := os.ReadDir(parentPath)
+
+	if os.IsNotExist(err) {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read directory '%s': %s", parentPath, err)))
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), linkPrefixes[0]) && !strings.HasPrefix(entry.Name(), linkPrefixes[1]) {
+			continue
+		}
+
+		stalePath := filepath.Join(parentPath, entry.Name())
+
+		if err := os.Remove(stalePath); err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to remove stale link '%s': %s", stalePath, err)))
+		}
+	}
+
+	versionsPath := getVersionsDirectoryPath(targetPath)
+	versions, err := os.ReadDir(versionsPath)
+
+	if os.IsNotExist(err) {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read directory '%s': %s", versionsPath, err)))
+	}
+
+	currentVersionPath, _ := readVersionLink(targetPath)
+	previousVersionPath, _ := readVersionLink(getPreviousDirectoryPath(targetPath))
+
+	for _, version := range versions {
+		versionPath := filepath.Join(versionsPath, version.Name())
+
+		if versionPath == currentVersionPath || versionPath == previousVersionPath {
+			continue
+		}
+
+		if err := os.RemoveAll(versionPath); err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to remove stale staging directory '%s': %s", versionPath, err)))
+		}
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// RemoveExtraction deletes the targetPath along with its previous content and every version directory.
+func RemoveExtraction(targetPath string) core.Result[core.Empty, core.Error] {
+	targetPath = filepath.Clean(targetPath)
+	cleanResult := CleanStaleStagingDirectories(targetPath)
+
+	if cleanResult.IsErr() {
+		return cleanResult
+	}
+
+	for _, path := range []string{targetPath, getPreviousDirectoryPath(targetPath), getVersionsDirectoryPath(targetPath)} {
+		if err := os.RemoveAll(path); err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to remove '%s': %s", path, err)))
+		}
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// migrateDirectory moves the path, when it's a directory instead of a link, into a version directory of the
+// targetPath and links it. Only the content extracted by older releases needs it, and the path is briefly missing.
+func migrateDirectory(targetPath string, path string) core.Result[core.Empty, core.Error] {
+	info, err := os.Lstat(path)
+
+	if err != nil || !info.IsDir() {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	versionPath := getStagingDirectoryPath(targetPath)
+
+	if err := os.MkdirAll(filepath.Dir(versionPath), os.ModePerm); err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to create versions directory: %s", err)))
+	}
+
+	if err := os.Rename(path, versionPath); err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to move '%s' into '%s': %s", path, versionPath, err)))
+	}
+
+	return linkVersion(path, versionPath)
+}
+
+// linkVersion atomically points the link at the version directory, replacing the link if it already exists.
+func linkVersion(linkPath string, versionPath string) core.Result[core.Empty, core.Error] {
+	linkTarget, err := filepath.Rel(filepath.Dir(linkPath), versionPath)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to resolve version directory '%s': %s", versionPath, err)))
+	}
+
+	temporaryPath := linkPath + linkInfix + uuid.New().String()
+
+	if err := os.Symlink(linkTarget, temporaryPath); err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to create link to '%s': %s", versionPath, err)))
+	}
+
+	if err := os.Rename(temporaryPath, linkPath); err != nil {
+		_ = os.Remove(temporaryPath)
+
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to move '%s' into '%s': %s", temporaryPath, linkPath, err)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// readVersionLink returns the version directory which the link points at, if the link exists.
+func readVersionLink(linkPath string) (string, bool) {
+	linkTarget, err := os.Readlink(linkPath)
+
+	if err != nil {
+		return "", false
+	}
+
+	if !filepath.IsAbs(linkTarget) {
+		linkTarget = filepath.Join(filepath.Dir(linkPath), linkTarget)
+	}
+
+	return filepath.Clean(linkTarget), true
+}
+
+func isStagingDirectoryPath(path string) bool {
+	return strings.HasSuffix(filepath.Dir(filepath.Clean(path)), versionsDirectorySuffix)
+}
+
+func getStagingDirectoryPath(targetPath string) string {
+	return filepath.Join(getVersionsDirectoryPath(targetPath), uuid.New().String())
+}
+
+func getVersionsDirectoryPath(targetPath string) string {
+	return filepath.Clean(targetPath) + versionsDirectorySuffix
+}
+
+func getPreviousDirectoryPath(targetPath string) string {
+	return filepath.Clean(targetPath) + previousDirectorySuffix
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type AtomicExtractionTestSuite struct {
+	suite.Suite
+	TargetPath string
+	Extractor  *ZipExtractor
+}
+
+func TestAtomicExtractionTestSuite(t *testing.T) {
+	suite.Run(t, new(AtomicExtractionTestSuite))
+}
+
+func (a *AtomicExtractionTestSuite) SetupTest() {
+	logger, _ := zap.NewDevelopment()
+	a.TargetPath = uuid.New().String()
+	a.Extractor = NewZipExtractor(logger)
+}
+
+func (a *AtomicExtractionTestSuite) TearDownTest() {
+	_ = RemoveExtraction(a.TargetPath)
+}
+
+func (a *AtomicExtractionTestSuite) TestExtract_FailsMidway_KeepsCurrentContent() {
+	a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 1"}), a.TargetPath)
+	invalidPackage := createZipWithHeaders([]zipEntry{
+		{Name: "application.yaml", Content: "Value: 2", Mode: 0644},
+		{Name: "../escaped.yaml", Content: "Value: 2", Mode: 0644},
+	})
+
+	result := a.Extractor.Extract(invalidPackage, a.TargetPath)
+
+	content, err := os.ReadFile(fmt.Sprintf("%s/application.yaml", a.TargetPath))
+	assert.True(a.T(), result.IsErr())
+	assert.Nil(a.T(), err)
+	assert.Equal(a.T(), "Value: 1", string(content))
+	assert.Len(a.T(), a.findVersionDirectories(), 1)
+}
+
+func (a *AtomicExtractionTestSuite) TestExtract_ReplacesContent_KeepsPreviousContent() {
+	a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 1", "removed.yaml": "Value: 1"}), a.TargetPath)
+
+	result := a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 2"}), a.TargetPath)
+
+	content, _ := os.ReadFile(fmt.Sprintf("%s/application.yaml", a.TargetPath))
+	previousContent, _ := os.ReadFile(fmt.Sprintf("%s/application.yaml", getPreviousDirectoryPath(a.TargetPath)))
+	_, removedFileErr := os.Stat(fmt.Sprintf("%s/removed.yaml", a.TargetPath))
+	assert.True(a.T(), result.IsOk())
+	assert.Equal(a.T(), "Value: 2", string(content))
+	assert.Equal(a.T(), "Value: 1", string(previousContent))
+	assert.True(a.T(), os.IsNotExist(removedFileErr))
+}
+
+func (a *AtomicExtractionTestSuite) TestRollback_RestoresPreviousContent() {
+	a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 1"}), a.TargetPath)
+	a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 2"}), a.TargetPath)
+
+	result := a.Extractor.Rollback(a.TargetPath)
+
+	content, _ := os.ReadFile(fmt.Sprintf("%s/application.yaml", a.TargetPath))
+	previousContent, _ := os.ReadFile(fmt.Sprintf("%s/application.yaml", getPreviousDirectoryPath(a.TargetPath)))
+	assert.True(a.T(), result.IsOk())
+	assert.Equal(a.T(), "Value: 1", string(content))
+	assert.Equal(a.T(), "Value: 2", string(previousContent))
+}
+
+func (a *AtomicExtractionTestSuite) TestRollback_NoPreviousContent_ReturnsNotFound() {
+	a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 1"}), a.TargetPath)
+
+	result := a.Extractor.Rollback(a.TargetPath)
+
+	assert.True(a.T(), result.IsErr())
+	assert.Equal(a.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (a *AtomicExtractionTestSuite) TestExtract_StaleStagingDirectory_RemovesIt() {
+	stalePath := getStagingDirectoryPath(a.TargetPath)
+	_ = os.MkdirAll(stalePath, os.ModePerm)
+
+	result := a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 1"}), a.TargetPath)
+
+	assert.True(a.T(), result.IsOk())
+	assert.False(a.T(), doesDirectoryExist(stalePath))
+}
+
+func (a *AtomicExtractionTestSuite) TestExtract_ReplacesContent_TargetPathNeverMissing() {
+	a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 1"}), a.TargetPath)
+	targetPath := a.TargetPath
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	missing := make(chan struct{}, 1)
+
+	go func() {
+		defer close(stopped)
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if _, err := os.ReadFile(fmt.Sprintf("%s/application.yaml", targetPath)); err != nil {
+				missing <- struct{}{}
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		a.Extractor.Extract(createZip(map[string]string{"application.yaml": fmt.Sprintf("Value: %d", i)}), a.TargetPath)
+	}
+
+	close(done)
+	<-stopped
+	assert.Empty(a.T(), missing)
+	assert.Len(a.T(), a.findVersionDirectories(), 2)
+}
+
+func (a *AtomicExtractionTestSuite) TestExtract_DirectoryFromOlderRelease_KeepsItAsPreviousContent() {
+	_ = os.MkdirAll(a.TargetPath, os.ModePerm)
+	_ = os.WriteFile(fmt.Sprintf("%s/application.yaml", a.TargetPath), []byte("Value: 1"), 0644)
+
+	result := a.Extractor.Extract(createZip(map[string]string{"application.yaml": "Value: 2"}), a.TargetPath)
+
+	content, _ := os.ReadFile(fmt.Sprintf("%s/application.yaml", a.TargetPath))
+	previousContent, _ := os.ReadFile(fmt.Sprintf("%s/application.yaml", getPreviousDirectoryPath(a.TargetPath)))
+	assert.True(a.T(), result.IsOk())
+	assert.Equal(a.T(), "Value: 2", string(content))
+	assert.Equal(a.T(), "Value: 1", string(previousContent))
+}
+
+func (a *AtomicExtractionTestSuite) findVersionDirectories() []string {
+	matches, _ := filepath.Glob(filepath.Join(getVersionsDirectoryPath(a.TargetPath), "*"))
+
+	return matches
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"go.uber.org/zap"
+	"io"
+)
+
+// PackageFormat is the archive format of a configuration package.
+type PackageFormat string
+
+const (
+	UnknownFormat PackageFormat = "unknown"
+	ZipFormat     PackageFormat = "zip"
+	TarFormat     PackageFormat = "tar"
+	TarGzipFormat PackageFormat = "tar.gz"
+	TarZstdFormat PackageFormat = "tar.zst"
+)
+
+const tarMagicOffset = 257
+
+// packageHeaderSize is the amount of bytes needed to detect every supported format.
+const packageHeaderSize = 512
+
+var zipMagic = []byte("PK\x03\x04")
+var emptyZipMagic = []byte("PK\x05\x06")
+var gzipMagic = []byte{0x1f, 0x8b}
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+var tarMagic = []byte("ustar")
+
+// DetectPackageFormat sniffs the magic bytes of the package data to find out its format.
+func DetectPackageFormat(packageData []byte) PackageFormat {
+	switch {
+	case bytes.HasPrefix(packageData, zipMagic) || bytes.HasPrefix(packageData, emptyZipMagic):
+		return ZipFormat
+	case bytes.HasPrefix(packageData, gzipMagic):
+		return TarGzipFormat
+	case bytes.HasPrefix(packageData, zstdMagic):
+		return TarZstdFormat
+	case len(packageData) >= tarMagicOffset+len(tarMagic) && bytes.Equal(packageData[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return TarFormat
+	default:
+		return UnknownFormat
+	}
+}
+
+// AutoExtractor detects the format of each configuration package and extracts it with the matching Extractor.
+// The supported formats are zip, tar, tar.gz and tar.zst.
+type AutoExtractor struct {
+	extractors map[PackageFormat]Extractor
+}
+
+// NewAutoExtractor creates an AutoExtractor which applies the DefaultExtractionLimits.
+func NewAutoExtractor(logger *zap.Logger) *AutoExtractor {
+	return NewAutoExtractorWithLimits(logger, DefaultExtractionLimits())
+}
+
+// NewAutoExtractorWithLimits creates an AutoExtractor which applies the specified limits.
+func NewAutoExtractorWithLimits(logger *zap.Logger, limits ExtractionLimits) *AutoExtractor {
+	extractor := new(AutoExtractor)
+	extractor.extractors = map[PackageFormat]Extractor{
+		ZipFormat:     NewZipExtractorWithLimits(logger, limits),
+		TarFormat:     NewTarExtractorWithLimits(logger, NoCompression, limits),
+		TarGzipFormat: NewTarExtractorWithLimits(logger, GzipCompression, limits),
+		TarZstdFormat: NewTarExtractorWithLimits(logger, ZstdCompression, limits),
+	}
+
+	return extractor
+}
+
+func (a AutoExtractor) Extract(packageData []byte, targetPath string) core.Result[core.Empty, core.Error] {
+	format := DetectPackageFormat(packageData)
+	extractor, exists := a.extractors[format]
+
+	if !exists {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, "package data has an unsupported format"))
+	}
+
+	return extractor.Extract(packageData, targetPath)
+}
+
+// ExtractStream works like Extract, but reads the package from the reader instead of memory.
+func (a AutoExtractor) ExtractStream(reader io.ReaderAt, size int64, targetPath string) core.Result[core.Empty, core.Error] {
+	header := make([]byte, min(size, packageHeaderSize))
+	_, err := reader.ReadAt(header, 0)
+
+	if err != nil && err != io.EOF {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to read package data's header: %s", err)))
+	}
+
+	extractor, exists := a.extractors[DetectPackageFormat(header)]
+
+	if !exists {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, "package data has an unsupported format"))
+	}
+
+	streamExtractor, ok := extractor.(StreamExtractor)
+
+	if !ok {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, "package data's format cannot be streamed"))
+	}
+
+	return streamExtractor.ExtractStream(reader, size, targetPath)
+}
+
+// Rollback restores the content which was replaced by the last extraction into the targetPath.
+func (a AutoExtractor) Rollback(targetPath string) core.Result[core.Empty, core.Error] {
+	return RollbackExtraction(targetPath)
+}
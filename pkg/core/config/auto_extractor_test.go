@@ -0,0 +1,101 @@
+package config
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"os"
+	"testing"
+)
+
+type AutoExtractorTestSuite struct {
+	suite.Suite
+	TargetPath string
+	Extractor  *AutoExtractor
+}
+
+func TestAutoExtractorTestSuite(t *testing.T) {
+	suite.Run(t, new(AutoExtractorTestSuite))
+}
+
+func (a *AutoExtractorTestSuite) SetupTest() {
+	logger, _ := zap.NewDevelopment()
+	a.TargetPath = uuid.New().String()
+	a.Extractor = NewAutoExtractor(logger)
+}
+
+func (a *AutoExtractorTestSuite) TearDownTest() {
+	_ = RemoveExtraction(a.TargetPath)
+}
+
+func (a *AutoExtractorTestSuite) TestDetectPackageFormat_EveryFormat_ReturnsExpectedFormat() {
+	entries := []tarEntry{{Name: "application.yaml", Content: "Value: 1", Mode: 0644, Type: tar.TypeReg}}
+
+	assert.Equal(a.T(), ZipFormat, DetectPackageFormat(createZip(map[string]string{"application.yaml": "Value: 1"})))
+	assert.Equal(a.T(), TarFormat, DetectPackageFormat(createTar(entries, NoCompression)))
+	assert.Equal(a.T(), TarGzipFormat, DetectPackageFormat(createTar(entries, GzipCompression)))
+	assert.Equal(a.T(), TarZstdFormat, DetectPackageFormat(createTar(entries, ZstdCompression)))
+	assert.Equal(a.T(), UnknownFormat, DetectPackageFormat([]byte("Value: 1")))
+}
+
+func (a *AutoExtractorTestSuite) TestAutoExtractor_Extract_EveryFormat_ExtractsExpectedFiles() {
+	entries := []tarEntry{{Name: "application.yaml", Content: "Value: 1", Mode: 0644, Type: tar.TypeReg}}
+	packages := [][]byte{
+		createZip(map[string]string{"application.yaml": "Value: 1"}),
+		createTar(entries, NoCompression),
+		createTar(entries, GzipCompression),
+		createTar(entries, ZstdCompression),
+	}
+
+	for _, packageData := range packages {
+		result := a.Extractor.Extract(packageData, a.TargetPath)
+
+		content, err := os.ReadFile(fmt.Sprintf("%s/application.yaml", a.TargetPath))
+		assert.True(a.T(), result.IsOk())
+		assert.Nil(a.T(), err)
+		assert.Equal(a.T(), "Value: 1", string(content))
+	}
+}
+
+func (a *AutoExtractorTestSuite) TestAutoExtractor_Extract_UnknownFormat_ReturnsExtractionFailure() {
+	result := a.Extractor.Extract([]byte("Value: 1"), a.TargetPath)
+
+	assert.True(a.T(), result.IsErr())
+	assert.Equal(a.T(), core.ExtractionFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (a *AutoExtractorTestSuite) TestAutoExtractor_Extract_UnsafeTarEntry_ReturnsUnsafePackageEntry() {
+	packageData := createTar([]tarEntry{{Name: "/etc/escaped.yaml", Content: "Value: 1", Mode: 0644, Type: tar.TypeReg}}, ZstdCompression)
+
+	result := a.Extractor.Extract(packageData, a.TargetPath)
+
+	assert.True(a.T(), result.IsErr())
+	assert.Equal(a.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+}
+
+func (a *AutoExtractorTestSuite) TestAutoExtractor_ExtractStream_EveryFormat_ExtractsExpectedFiles() {
+	entries := []tarEntry{{Name: "application.yaml", Content: "Value: 1", Mode: 0644, Type: tar.TypeReg}}
+	packages := [][]byte{
+		createZip(map[string]string{"application.yaml": "Value: 1"}),
+		createTar(entries, NoCompression),
+		createTar(entries, GzipCompression),
+		createTar(entries, ZstdCompression),
+	}
+
+	for _, packageData := range packages {
+		spooledPackage := SpoolPackage(bytes.NewReader(packageData), "").Unwrap()
+
+		result := a.Extractor.ExtractStream(spooledPackage, spooledPackage.Size(), a.TargetPath)
+
+		spooledPackage.Close()
+		content, err := os.ReadFile(fmt.Sprintf("%s/application.yaml", a.TargetPath))
+		assert.True(a.T(), result.IsOk())
+		assert.Nil(a.T(), err)
+		assert.Equal(a.T(), "Value: 1", string(content))
+	}
+}
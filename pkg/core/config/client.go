@@ -1,10 +1,28 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"github.com/simpleg-eu/cuplan_core/pkg/core"
 	"go.uber.org/zap"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClientStatus describes the origin of the configuration package which is currently used by a Client.
+type ClientStatus string
+
+const (
+	// Uninitialized means no configuration package has been used yet.
+	Uninitialized ClientStatus = "uninitialized"
+	// Healthy means the configuration package has been downloaded from the Downloader.
+	Healthy ClientStatus = "healthy"
+	// Degraded means the Downloader failed and the last known good configuration package is used instead.
+	Degraded ClientStatus = "degraded"
 )
 
 // Client provides a way to obtain configurations from remote locations.
@@ -19,6 +37,46 @@ type Client struct {
 	downloader  Downloader
 	extractor   Extractor
 	provider    Provider
+	// interpolator is optional; when set, references within the configuration values are resolved.
+	interpolator *Interpolator
+	// validator is optional; when set, packages are validated before being used.
+	validator Validator
+	// packageVerifier is optional; when set, packages must match the digest and signature published by their source.
+	packageVerifier *PackageVerifier
+	// snapshotStore is optional; when set, the last known good package is used whenever the Downloader fails.
+	snapshotStore SnapshotStore
+	retryInterval time.Duration
+	// statusMutex guards the status and the versions.
+	statusMutex sync.Mutex
+	status      ClientStatus
+	// pinnedVersion is the version downloaded instead of the latest one; empty means the latest one.
+	pinnedVersion string
+	// activeVersion and previousVersion are the versions of the current and the replaced packages, when known.
+	activeVersion   string
+	previousVersion string
+	retrying        bool
+	// retryDone is closed once the background retry, if any, has stopped.
+	retryDone chan struct{}
+	// pushUpdatesDone is closed once the publications' watcher started by StartPushUpdates, if any, has stopped.
+	pushUpdatesDone chan struct{}
+	stopChannel     chan struct{}
+	stopOnce        sync.Once
+	// loadMutex serializes the loads of configuration packages into the working path.
+	loadMutex sync.Mutex
+	// initialization is the in-flight initialization shared by the concurrent callers of Get and Start.
+	initialization      *initialization
+	initializationMutex sync.Mutex
+	// secretKeyPattern matches the keys whose values are redacted from the ConfigDiffs.
+	secretKeyPattern *regexp.Regexp
+	subscribers      map[int]func(ConfigDiff)
+	nextSubscriberId int
+	subscribersMutex sync.Mutex
+}
+
+// initialization is the outcome of a Client's initialization, available once done is closed.
+type initialization struct {
+	done   chan struct{}
+	result core.Result[core.Empty, core.Error]
 }
 
 // NewClient creates a new instance of Client.
@@ -42,46 +100,763 @@ func NewClient(logger *zap.Logger,
 	client.downloader = downloader
 	client.extractor = extractor
 	client.provider = provider
+	client.status = Uninitialized
+	client.stopChannel = make(chan struct{})
+	client.secretKeyPattern = DefaultSecretKeyPattern
+	client.subscribers = make(map[int]func(ConfigDiff))
+
+	client.cleanStaleDirectories()
 
 	return client
 }
 
-// Close deletes the working path.
+// SetInterpolator makes the Client resolve references such as '${secret:<id>}' or '${env:NAME}' within
+// the values it returns.
+func (c *Client) SetInterpolator(interpolator *Interpolator) {
+	c.interpolator = interpolator
+}
+
+// SetValidator makes the Client validate every configuration package before using it.
+func (c *Client) SetValidator(validator Validator) {
+	c.validator = validator
+}
+
+// SetPackageVerifier makes the Client reject the downloaded configuration packages which do not match the digest
+// and signature published by their source. Only the packages of a SignedDownloader can be verified, so the packages
+// of any other Downloader are rejected.
+func (c *Client) SetPackageVerifier(packageVerifier *PackageVerifier) {
+	c.packageVerifier = packageVerifier
+}
+
+// SetSnapshotStore makes the Client persist every downloaded configuration package and fall back to the last
+// known good one whenever the Downloader fails while there is no current package, i.e. on start up. A failed
+// Reload keeps the current package instead. While falling back, the Client's status is Degraded and the
+// download is retried in the background, every retryInterval, until it succeeds.
+func (c *Client) SetSnapshotStore(snapshotStore SnapshotStore, retryInterval time.Duration) {
+	c.snapshotStore = snapshotStore
+	c.retryInterval = retryInterval
+}
+
+// SetSecretKeyPattern sets the pattern matching the keys whose values are redacted from the ConfigDiffs,
+// which is DefaultSecretKeyPattern by default. A nil pattern disables the redaction.
+func (c *Client) SetSecretKeyPattern(secretKeyPattern *regexp.Regexp) {
+	c.secretKeyPattern = secretKeyPattern
+}
+
+// Subscribe makes the Client call the subscriber with the ConfigDiff of every configuration package which replaces
+// the current one, whether by a reload, a pin or a rollback. Subscribers are called synchronously, so they must
+// not reload, pin nor roll back the Client. The returned function cancels the subscription.
+func (c *Client) Subscribe(subscriber func(ConfigDiff)) func() {
+	c.subscribersMutex.Lock()
+	defer c.subscribersMutex.Unlock()
+
+	subscriberId := c.nextSubscriberId
+	c.nextSubscriberId++
+	c.subscribers[subscriberId] = subscriber
+
+	return func() {
+		c.subscribersMutex.Lock()
+		defer c.subscribersMutex.Unlock()
+
+		delete(c.subscribers, subscriberId)
+	}
+}
+
+// Status returns the origin of the configuration package which is currently used.
+func (c *Client) Status() ClientStatus {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	return c.status
+}
+
+// ActiveVersion returns the version of the configuration package which is currently used, or an empty string
+// if it's unknown, i.e. the Downloader does not report versions or the package comes from a snapshot.
+func (c *Client) ActiveVersion() string {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	return c.activeVersion
+}
+
+// PinnedVersion returns the version the Client is pinned to, or an empty string if it follows the latest one.
+func (c *Client) PinnedVersion() string {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	return c.pinnedVersion
+}
+
+// ListVersions lists the versions of the configuration package, from the latest to the oldest.
+// The Downloader must be a VersionedDownloader.
+func (c *Client) ListVersions() core.Result[[]PackageVersion, core.Error] {
+	versionedDownloader, ok := c.downloader.(VersionedDownloader)
+
+	if !ok {
+		return core.Err[[]PackageVersion, core.Error](*core.NewError(core.InvalidInput, "downloader does not support package versions"))
+	}
+
+	return versionedDownloader.ListVersions(c.host, c.stage, c.environment, c.component)
+}
+
+// Pin makes the Client use the specified version of the configuration package, instead of the latest one, until
+// Unpin is called. The pinned version is loaded right away; if it fails, the Client keeps its previous pin.
+// The Downloader must be a VersionedDownloader.
+func (c *Client) Pin(version string) core.Result[core.Empty, core.Error] {
+	if _, ok := c.downloader.(VersionedDownloader); !ok {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, "downloader does not support package versions"))
+	}
+
+	if len(version) == 0 {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, "version cannot be empty"))
+	}
+
+	return c.loadPinnedVersion(version)
+}
+
+// Unpin makes the Client follow the latest version of the configuration package again, loading it right away.
+func (c *Client) Unpin() core.Result[core.Empty, core.Error] {
+	return c.loadPinnedVersion("")
+}
+
+// Rollback replaces the current configuration package with the previous one.
+// With a VersionedDownloader, the Client is pinned to the version which precedes the active one, so the rollback
+// survives reloads until Unpin is called. Otherwise, the package replaced by the last reload is restored, and
+// the next Reload downloads the latest package again.
+func (c *Client) Rollback() core.Result[core.Empty, core.Error] {
+	if _, ok := c.downloader.(VersionedDownloader); ok {
+		return c.rollbackVersion()
+	}
+
+	c.loadMutex.Lock()
+	defer c.loadMutex.Unlock()
+
+	rollbackResult := RollbackExtraction(c.workingPath)
+
+	if rollbackResult.IsErr() {
+		return rollbackResult
+	}
+
+	c.resetDownloadConditions()
+	c.cleanCaches()
+
+	c.statusMutex.Lock()
+	c.activeVersion, c.previousVersion = c.previousVersion, c.activeVersion
+	fromVersion, toVersion := c.previousVersion, c.activeVersion
+	c.statusMutex.Unlock()
+
+	c.logger.Info("Rolled back configuration package.", zap.String("version", toVersion))
+	c.publishDiff(fromVersion, toVersion)
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+func (c *Client) rollbackVersion() core.Result[core.Empty, core.Error] {
+	activeVersion := c.ActiveVersion()
+
+	if len(activeVersion) == 0 {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.NotFound, "active configuration package version is unknown"))
+	}
+
+	versionsResult := c.ListVersions()
+
+	if versionsResult.IsErr() {
+		return core.Err[core.Empty, core.Error](versionsResult.UnwrapErr())
+	}
+
+	versions := versionsResult.Unwrap()
+
+	for index, version := range versions {
+		if version.Version != activeVersion {
+			continue
+		}
+
+		if index+1 == len(versions) {
+			break
+		}
+
+		previousVersion := versions[index+1].Version
+		c.logger.Info("Rolling back configuration package.", zap.String("from", activeVersion), zap.String("to", previousVersion))
+
+		return c.Pin(previousVersion)
+	}
+
+	return core.Err[core.Empty, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("no configuration package version precedes '%s'", activeVersion)))
+}
+
+// loadPinnedVersion pins the Client to the version and loads it, restoring the previous pin if it fails.
+func (c *Client) loadPinnedVersion(version string) core.Result[core.Empty, core.Error] {
+	c.loadMutex.Lock()
+	defer c.loadMutex.Unlock()
+
+	c.statusMutex.Lock()
+	previousPin := c.pinnedVersion
+	c.pinnedVersion = version
+	c.statusMutex.Unlock()
+
+	// The conditions of a version downloaded earlier may not match the current package anymore.
+	c.resetDownloadConditions()
+
+	result := c.initializeConfig()
+
+	if result.IsErr() {
+		c.statusMutex.Lock()
+		c.pinnedVersion = previousPin
+		c.statusMutex.Unlock()
+	}
+
+	return result
+}
+
+// Close stops any background retry or push updates and deletes the working path, along with its version and previous
+// directories. It waits for them, and any other load, to finish, so they cannot recreate the working path.
 func (c *Client) Close() {
-	err := os.RemoveAll(c.workingPath)
-	if err != nil {
-		c.logger.Warn(fmt.Sprintf("Failed to remove working path '%s'.", c.workingPath))
+	c.stopOnce.Do(func() {
+		close(c.stopChannel)
+	})
+
+	c.statusMutex.Lock()
+	retryDone := c.retryDone
+	pushUpdatesDone := c.pushUpdatesDone
+	c.statusMutex.Unlock()
+
+	if retryDone != nil {
+		<-retryDone
+	}
+
+	if pushUpdatesDone != nil {
+		<-pushUpdatesDone
+	}
+
+	c.loadMutex.Lock()
+	defer c.loadMutex.Unlock()
+
+	result := RemoveExtraction(c.workingPath)
+	if result.IsErr() {
+		c.logger.Warn(fmt.Sprintf("Failed to remove working path '%s'.", c.workingPath), zap.String("err", result.UnwrapErr().Message))
 	}
 }
 
 // Get retrieves the configuration located within the specified file and at the specified key.
-// The different levels are separated by ':', i.e. "Root:Parent:Example".
+// The different levels are separated by ':', i.e. "Root:Parent:Example". Lists are indexed by number, i.e.
+// "Servers:0:Host", '*' matches every item, i.e. "Servers:*:Host", and a trailing '?' makes a level optional.
 func (c *Client) Get(filePath string, key string) core.Result[any, core.Error] {
-	if !_doesDirectoryExist(c.workingPath) {
-		initResult := c.initializeConfig()
+	initResult := c.ensureInitialized()
 
-		if !initResult.IsOk() {
-			return core.Err[any, core.Error](initResult.UnwrapErr())
-		}
+	if initResult.IsErr() {
+		return core.Err[any, core.Error](initResult.UnwrapErr())
+	}
+
+	result := c.provider.Get(filePath, key)
+
+	if result.IsErr() || c.interpolator == nil {
+		return result
+	}
+
+	return c.interpolator.Interpolate(result.Unwrap())
+}
+
+// Reload downloads the latest configuration package, or the pinned one, and replaces the current one with it.
+// The new package is extracted into a staging path and, if a Validator is set, validated before replacing
+// the current one; an invalid package is discarded and the current one is kept.
+func (c *Client) Reload() core.Result[core.Empty, core.Error] {
+	c.loadMutex.Lock()
+	defer c.loadMutex.Unlock()
+
+	return c.initializeConfig()
+}
+
+// Start loads the configuration package right away, instead of waiting for the first Get, so a missing or
+// invalid package is noticed on startup. If the context is done before the load finishes, its error is
+// returned and the load carries on in the background, where the first Get will wait for it.
+func (c *Client) Start(ctx context.Context) core.Result[core.Empty, core.Error] {
+	resultChannel := make(chan core.Result[core.Empty, core.Error], 1)
+
+	go func() {
+		resultChannel <- c.ensureInitialized()
+	}()
+
+	select {
+	case result := <-resultChannel:
+		return result
+	case <-ctx.Done():
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("configuration package did not load in time: %s", ctx.Err())))
+	}
+}
+
+// ensureInitialized loads the configuration package unless the working path already exists. Concurrent callers
+// share a single load and its result, instead of racing to download and extract the package.
+func (c *Client) ensureInitialized() core.Result[core.Empty, core.Error] {
+	c.initializationMutex.Lock()
+
+	if c.initialization != nil {
+		inFlight := c.initialization
+		c.initializationMutex.Unlock()
+		<-inFlight.done
+
+		return inFlight.result
 	}
 
-	return c.provider.Get(filePath, key)
+	if _doesDirectoryExist(c.workingPath) {
+		c.initializationMutex.Unlock()
+
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	inFlight := &initialization{done: make(chan struct{})}
+	c.initialization = inFlight
+	c.initializationMutex.Unlock()
+
+	inFlight.result = c.Reload()
+	close(inFlight.done)
+
+	c.initializationMutex.Lock()
+	c.initialization = nil
+	c.initializationMutex.Unlock()
+
+	return inFlight.result
 }
 
+// initializeConfig loads the configuration package into the working path; the caller must hold loadMutex.
+// Once the Client is closed, nothing is loaded, so a load which outlives Start's context cannot recreate
+// the working path removed by Close.
 func (c *Client) initializeConfig() core.Result[core.Empty, core.Error] {
-	err := os.MkdirAll(c.workingPath, os.ModePerm)
+	if c.isClosed() {
+		return closedClientError()
+	}
+
+	err := os.MkdirAll(filepath.Dir(c.workingPath), os.ModePerm)
 	if err != nil {
-		c.logger.Info(fmt.Sprintf("Failed to create working path '%s'.", c.workingPath))
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to create working path '%s': %s", c.workingPath, err)))
+	}
+
+	conditionalDownloader, isConditional := c.downloader.(ConditionalDownloader)
+
+	// Without a current package, there is nothing the conditional download could be compared against.
+	if isConditional && !_doesDirectoryExist(c.workingPath) {
+		conditionalDownloader.ResetConditions(c.host, c.stage, c.environment, c.component)
 	}
 
-	downloadResult := c.downloader.Download(c.host, c.stage, c.environment, c.component)
+	downloadResult := c.download()
+	status := Healthy
+
+	if downloadResult.IsErr() && downloadResult.UnwrapErr().ErrorKind == core.NotModified {
+		c.logger.Debug("Configuration package has not been modified.")
+		c.setStatus(Healthy)
+
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
 
-	if !downloadResult.IsOk() {
+	// The snapshot only stands in for a missing package: the current one may be newer than the snapshot or, after
+	// a Rollback, the one which replaced it, so it's kept whenever the download fails.
+	if downloadResult.IsErr() && _doesDirectoryExist(c.workingPath) {
 		return core.Err[core.Empty, core.Error](downloadResult.UnwrapErr())
 	}
 
+	if downloadResult.IsErr() {
+		fallbackResult := c.loadSnapshot(downloadResult.UnwrapErr())
+
+		if fallbackResult.IsErr() {
+			return core.Err[core.Empty, core.Error](fallbackResult.UnwrapErr())
+		}
+
+		// The snapshot's version is unknown.
+		downloadResult = core.Ok[configPackage, core.Error](configPackage{data: fallbackResult.Unwrap()})
+		status = Degraded
+	}
+
+	downloadedPackage := downloadResult.Unwrap()
+	defer downloadedPackage.close()
+
+	stagingPath := getStagingDirectoryPath(c.workingPath)
+	extractResult := c.extract(downloadedPackage, stagingPath)
+
+	if extractResult.IsErr() {
+		_ = os.RemoveAll(stagingPath)
+		c.resetDownloadConditions()
+
+		return extractResult
+	}
+
+	if c.validator != nil {
+		validationResult := c.validator.Validate(stagingPath)
+
+		if validationResult.IsErr() {
+			_ = os.RemoveAll(stagingPath)
+			c.resetDownloadConditions()
+			c.logger.Warn("Discarded invalid configuration package.", zap.String("err", validationResult.UnwrapErr().Message))
+
+			return validationResult
+		}
+	}
+
+	// Close may have been called while downloading; it waits for loadMutex, so it's checked right before the swap.
+	if c.isClosed() {
+		_ = os.RemoveAll(stagingPath)
+
+		return closedClientError()
+	}
+
+	hadPackage := _doesDirectoryExist(c.workingPath)
+	swapResult := c.swapWorkingPath(stagingPath)
+
+	if swapResult.IsErr() {
+		c.resetDownloadConditions()
+
+		return swapResult
+	}
+
+	c.cleanCaches()
+	previousVersion := c.setActiveVersion(downloadedPackage.version)
+
+	if hadPackage {
+		c.publishDiff(previousVersion, downloadedPackage.version)
+	}
+
+	if status == Healthy && c.snapshotStore != nil {
+		saveResult := c.saveSnapshot(downloadedPackage)
+
+		if saveResult.IsErr() {
+			c.logger.Warn("Failed to save configuration package snapshot.", zap.String("err", saveResult.UnwrapErr().Message))
+		}
+	}
+
+	c.setStatus(status)
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// configPackage is a configuration package which is either held in memory or spooled into a temporary file.
+type configPackage struct {
+	data    []byte
+	spooled *SpooledPackage
+	version string
+}
+
+func (p configPackage) close() {
+	if p.spooled != nil {
+		p.spooled.Close()
+	}
+}
+
+// download downloads the configuration package and, if a PackageVerifier is set, verifies it. The outcome of the
+// verification is reported to the SignedDownloader, so a rejected package is downloaded again by the next load.
+func (c *Client) download() core.Result[configPackage, core.Error] {
+	downloadResult := c.downloadPackage()
+
+	if downloadResult.IsErr() || c.packageVerifier == nil {
+		return downloadResult
+	}
+
+	downloadedPackage := downloadResult.Unwrap()
+	signedDownloader, isSigned := c.downloader.(SignedDownloader)
+	signature := PackageSignature{}
+
+	if isSigned {
+		signatureOption := signedDownloader.PackageSignature(c.host, c.stage, c.environment, c.component)
+
+		if signatureOption.IsSome() {
+			signature = signatureOption.Unwrap()
+		}
+	}
+
+	var verificationResult core.Result[core.Empty, core.Error]
+
+	if downloadedPackage.spooled != nil {
+		verificationResult = c.packageVerifier.VerifyDigest(downloadedPackage.spooled.Digest(), signature.Digest, signature.Signature)
+	} else {
+		verificationResult = c.packageVerifier.Verify(downloadedPackage.data, signature.Digest, signature.Signature)
+	}
+
+	if isSigned {
+		signedDownloader.ReportVerification(c.host, c.stage, c.environment, c.component, verificationResult.IsOk())
+	}
+
+	if verificationResult.IsErr() {
+		downloadedPackage.close()
+		c.logger.Warn("Rejected configuration package.", zap.String("err", verificationResult.UnwrapErr().Message))
+
+		return core.Err[configPackage, core.Error](verificationResult.UnwrapErr())
+	}
+
+	return downloadResult
+}
+
+// downloadPackage spools the configuration package into a temporary file when both the Downloader and the Extractor
+// support streaming, so the package is never held in memory; otherwise, the package is downloaded into memory.
+// A pinned version is always downloaded into memory through the VersionedDownloader. Conditional Downloaders are
+// asked for the package only if it has been modified, which is reported with a 'not_modified' error.
+func (c *Client) downloadPackage() core.Result[configPackage, core.Error] {
+	pinnedVersion := c.PinnedVersion()
+	streamDownloader, isStreamDownloader := c.downloader.(StreamDownloader)
+	_, isStreamExtractor := c.extractor.(StreamExtractor)
+
+	if len(pinnedVersion) == 0 && isStreamDownloader && isStreamExtractor {
+		var spoolResult core.Result[*SpooledPackage, core.Error]
+
+		if conditionalDownloader, ok := c.downloader.(ConditionalStreamDownloader); ok {
+			spoolResult = conditionalDownloader.DownloadStreamIfModified(c.host, c.stage, c.environment, c.component)
+		} else {
+			spoolResult = streamDownloader.DownloadStream(c.host, c.stage, c.environment, c.component)
+		}
+
+		if spoolResult.IsErr() {
+			return core.Err[configPackage, core.Error](spoolResult.UnwrapErr())
+		}
+
+		spooledPackage := spoolResult.Unwrap()
+
+		return core.Ok[configPackage, core.Error](configPackage{spooled: spooledPackage, version: spooledPackage.Version()})
+	}
+
+	if versionedDownloader, ok := c.downloader.(VersionedDownloader); ok {
+		var versionResult core.Result[VersionedPackage, core.Error]
+
+		if conditionalDownloader, ok := c.downloader.(ConditionalVersionedDownloader); ok {
+			versionResult = conditionalDownloader.DownloadVersionIfModified(c.host, c.stage, c.environment, c.component, pinnedVersion)
+		} else {
+			versionResult = versionedDownloader.DownloadVersion(c.host, c.stage, c.environment, c.component, pinnedVersion)
+		}
+
+		if versionResult.IsErr() {
+			return core.Err[configPackage, core.Error](versionResult.UnwrapErr())
+		}
+
+		versionedPackage := versionResult.Unwrap()
+
+		return core.Ok[configPackage, core.Error](configPackage{data: versionedPackage.Data, version: versionedPackage.Version})
+	}
+
+	var downloadResult core.Result[[]byte, core.Error]
+
+	if conditionalDownloader, ok := c.downloader.(ConditionalDownloader); ok {
+		downloadResult = conditionalDownloader.DownloadIfModified(c.host, c.stage, c.environment, c.component)
+	} else {
+		downloadResult = c.downloader.Download(c.host, c.stage, c.environment, c.component)
+	}
+
+	if downloadResult.IsErr() {
+		return core.Err[configPackage, core.Error](downloadResult.UnwrapErr())
+	}
+
+	return core.Ok[configPackage, core.Error](configPackage{data: downloadResult.Unwrap()})
+}
+
+func (c *Client) extract(downloadedPackage configPackage, targetPath string) core.Result[core.Empty, core.Error] {
+	if downloadedPackage.spooled != nil {
+		return c.extractor.(StreamExtractor).ExtractStream(downloadedPackage.spooled, downloadedPackage.spooled.Size(), targetPath)
+	}
+
+	return c.extractor.Extract(downloadedPackage.data, targetPath)
+}
+
+func (c *Client) saveSnapshot(downloadedPackage configPackage) core.Result[core.Empty, core.Error] {
+	if downloadedPackage.spooled == nil {
+		return c.snapshotStore.Save(downloadedPackage.data)
+	}
+
+	if streamSnapshotStore, ok := c.snapshotStore.(StreamSnapshotStore); ok {
+		return streamSnapshotStore.SaveStream(downloadedPackage.spooled.Reader())
+	}
+
+	dataResult := downloadedPackage.spooled.Bytes()
+
+	if dataResult.IsErr() {
+		return core.Err[core.Empty, core.Error](dataResult.UnwrapErr())
+	}
+
+	return c.snapshotStore.Save(dataResult.Unwrap())
+}
+
+func (c *Client) loadSnapshot(downloadError core.Error) core.Result[[]byte, core.Error] {
+	if c.snapshotStore == nil {
+		return core.Err[[]byte, core.Error](downloadError)
+	}
+
+	snapshotResult := c.snapshotStore.Load()
+
+	if snapshotResult.IsErr() {
+		c.logger.Warn("Failed to load configuration package snapshot.", zap.String("err", snapshotResult.UnwrapErr().Message))
+
+		return core.Err[[]byte, core.Error](downloadError)
+	}
+
+	snapshot := snapshotResult.Unwrap()
+	c.logger.Warn("Failed to download configuration package, falling back to the last known good one.",
+		zap.String("err", downloadError.Message),
+		zap.String("checksum", snapshot.Checksum),
+		zap.Time("downloadedAt", snapshot.DownloadedAt))
+
+	return core.Ok[[]byte, core.Error](snapshot.PackageData)
+}
+
+func (c *Client) setStatus(status ClientStatus) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	c.status = status
+
+	if status == Degraded && !c.retrying && c.retryInterval > 0 && !c.isClosed() {
+		c.retrying = true
+		retryDone := make(chan struct{})
+		c.retryDone = retryDone
+
+		go func() {
+			defer close(retryDone)
+
+			c.retryInBackground()
+		}()
+	}
+}
+
+func (c *Client) retryInBackground() {
+	ticker := time.NewTicker(c.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChannel:
+			c.stopRetrying()
+			return
+		case <-ticker.C:
+			// A tick may be picked over the stop channel, which is checked again before reloading.
+			if c.isClosed() {
+				c.stopRetrying()
+				return
+			}
+
+			result := c.Reload()
+
+			if result.IsErr() {
+				c.logger.Warn("Failed to reload configuration package while degraded.", zap.String("err", result.UnwrapErr().Message))
+				continue
+			}
+
+			if c.Status() == Healthy {
+				c.logger.Info("Recovered from degraded configuration package.")
+				c.stopRetrying()
+				return
+			}
+		}
+	}
+}
+
+// setActiveVersion records the version of the new package and returns the version of the replaced one.
+func (c *Client) setActiveVersion(version string) string {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	c.previousVersion = c.activeVersion
+	c.activeVersion = version
+
+	return c.previousVersion
+}
+
+// publishDiff logs the changes between the replaced package, kept within '<workingPath>.previous', and the current
+// one, and hands them over to the subscribers.
+func (c *Client) publishDiff(fromVersion string, toVersion string) {
+	var keyProvider KeyProvider
+
+	// The files encrypted at rest are compared once decrypted, since each encryption of the same content differs.
+	if encryptedExtractor, ok := c.extractor.(*EncryptedExtractor); ok {
+		keyProvider = encryptedExtractor.keyProvider
+	}
+
+	changesResult := DiffPackages(getPreviousDirectoryPath(c.workingPath), c.workingPath, c.secretKeyPattern, keyProvider)
+
+	if changesResult.IsErr() {
+		c.logger.Warn("Failed to compute configuration diff.", zap.String("err", changesResult.UnwrapErr().Message))
+		return
+	}
+
+	diff := ConfigDiff{FromVersion: fromVersion, ToVersion: toVersion, Changes: changesResult.Unwrap()}
+
+	if diff.IsEmpty() {
+		c.logger.Info("Configuration package replaced without changes.", zap.String("from", fromVersion), zap.String("to", toVersion))
+	} else {
+		c.logger.Info("Configuration package changed.",
+			zap.String("from", fromVersion),
+			zap.String("to", toVersion),
+			zap.Int("count", len(diff.Changes)),
+			zap.Any("changes", diff.Changes))
+	}
+
+	c.subscribersMutex.Lock()
+	subscriberIds := make([]int, 0, len(c.subscribers))
+
+	for subscriberId := range c.subscribers {
+		subscriberIds = append(subscriberIds, subscriberId)
+	}
+
+	// Subscribers are called in the order they subscribed.
+	sort.Ints(subscriberIds)
+	subscribers := make([]func(ConfigDiff), 0, len(subscriberIds))
+
+	for _, subscriberId := range subscriberIds {
+		subscribers = append(subscribers, c.subscribers[subscriberId])
+	}
+
+	c.subscribersMutex.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(diff)
+	}
+}
+
+func (c *Client) cleanCaches() {
 	c.provider.CleanCache()
-	return c.extractor.Extract(downloadResult.Unwrap(), c.workingPath)
+
+	if c.interpolator != nil {
+		c.interpolator.CleanCache()
+	}
+}
+
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.stopChannel:
+		return true
+	default:
+		return false
+	}
+}
+
+func closedClientError() core.Result[core.Empty, core.Error] {
+	return core.Err[core.Empty, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "client has been closed"))
+}
+
+func (c *Client) stopRetrying() {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	c.retrying = false
+}
+
+// resetDownloadConditions makes sure a discarded package is downloaded again, instead of being reported as not modified.
+func (c *Client) resetDownloadConditions() {
+	if conditionalDownloader, ok := c.downloader.(ConditionalDownloader); ok {
+		conditionalDownloader.ResetConditions(c.host, c.stage, c.environment, c.component)
+	}
+}
+
+// swapWorkingPath atomically points the working path at the staging path, keeping the replaced package behind
+// '<workingPath>.previous'.
+func (c *Client) swapWorkingPath(stagingPath string) core.Result[core.Empty, core.Error] {
+	result := replaceDirectory(stagingPath, c.workingPath)
+
+	if result.IsErr() {
+		_ = os.RemoveAll(stagingPath)
+	}
+
+	return result
+}
+
+// cleanStaleDirectories removes the staging directories left behind by a previous process which was interrupted.
+func (c *Client) cleanStaleDirectories() {
+	result := CleanStaleStagingDirectories(c.workingPath)
+
+	if result.IsErr() {
+		c.logger.Warn("Failed to remove stale staging directories.", zap.String("err", result.UnwrapErr().Message))
+	}
 }
 
 func _doesDirectoryExist(directory string) bool {
@@ -1,14 +1,24 @@
 package config
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"github.com/google/uuid"
 	"github.com/simpleg-eu/cuplan_core/pkg/core"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 const host = "https://simpleg.eu"
@@ -23,6 +33,14 @@ type MockDownloader struct {
 	mock.Mock
 }
 
+type MockConditionalDownloader struct {
+	MockDownloader
+}
+
+type MockVersionedDownloader struct {
+	MockDownloader
+}
+
 type MockExtractor struct {
 	mock.Mock
 }
@@ -31,6 +49,10 @@ type MockProvider struct {
 	mock.Mock
 }
 
+type MockValidator struct {
+	mock.Mock
+}
+
 type ClientTestSuite struct {
 	suite.Suite
 	WorkingPath string
@@ -48,11 +70,32 @@ func TestClientTestSuite(t *testing.T) {
 func (m *MockDownloader) Download(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
 	args := m.Called(host, stage, environment, component)
 	result, _ := args.Get(0).(core.Result[[]byte, core.Error])
-	return core.Ok[[]byte, core.Error](result.Unwrap())
+	return result
+}
+
+func (m *MockConditionalDownloader) DownloadIfModified(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
+	args := m.Called(host, stage, environment, component)
+	result, _ := args.Get(0).(core.Result[[]byte, core.Error])
+	return result
+}
+
+func (m *MockConditionalDownloader) ResetConditions(host string, stage string, environment string, component string) {
+	m.Called(host, stage, environment, component)
+}
+
+func (m *MockVersionedDownloader) ListVersions(host string, stage string, environment string, component string) core.Result[[]PackageVersion, core.Error] {
+	args := m.Called(host, stage, environment, component)
+	return args.Get(0).(core.Result[[]PackageVersion, core.Error])
+}
+
+func (m *MockVersionedDownloader) DownloadVersion(host string, stage string, environment string, component string, version string) core.Result[VersionedPackage, core.Error] {
+	args := m.Called(host, stage, environment, component, version)
+	return args.Get(0).(core.Result[VersionedPackage, core.Error])
 }
 
 func (m *MockExtractor) Extract(packageData []byte, targetPath string) core.Result[core.Empty, core.Error] {
 	m.Called(packageData, targetPath)
+	_ = os.MkdirAll(targetPath, os.ModePerm)
 	return core.Ok[core.Empty, core.Error](core.Empty{})
 }
 
@@ -65,6 +108,18 @@ func (m *MockProvider) CleanCache() {
 	m.Called()
 }
 
+func (m *MockValidator) Validate(packagePath string) core.Result[core.Empty, core.Error] {
+	args := m.Called(packagePath)
+	return args.Get(0).(core.Result[core.Empty, core.Error])
+}
+
+// stagingPathOf matches any staging path of the working path.
+func stagingPathOf(workingPath string) any {
+	return mock.MatchedBy(func(path string) bool {
+		return filepath.Dir(path) == getVersionsDirectoryPath(workingPath)
+	})
+}
+
 func (c *ClientTestSuite) SetupTest() {
 	c.WorkingPath = uuid.New().String()
 	c.PackageData = make([]byte, 0)
@@ -75,7 +130,7 @@ func (c *ClientTestSuite) SetupTest() {
 	c.Client = NewClient(logger, host, stage, environment, component, c.WorkingPath, c.Downloader, c.Extractor, c.Provider)
 
 	c.Downloader.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error](c.PackageData))
-	c.Extractor.On("Extract", c.PackageData, c.WorkingPath).Return()
+	c.Extractor.On("Extract", c.PackageData, stagingPathOf(c.WorkingPath)).Return()
 	c.Provider.On("Get", filePath, configKey).Return(value)
 	c.Provider.On("CleanCache").Return()
 }
@@ -88,6 +143,197 @@ func (c *ClientTestSuite) TestClient_Get_ReturnsExpectedValue() {
 	c.AssertCompleteFlowExecutedTimes(1)
 }
 
+func (c *ClientTestSuite) TestClient_Get_WithInterpolator_ReturnsResolvedValue() {
+	defer c.Client.Close()
+	const referenceKey = "Parent:Reference"
+	c.T().Setenv("CLIENT_TEST_VALUE", value)
+	c.Provider.On("Get", filePath, referenceKey).Return("${env:CLIENT_TEST_VALUE}")
+	c.Client.SetInterpolator(NewInterpolator(nil, core.NewCache(time.Hour), time.Hour))
+
+	result := c.Client.Get(filePath, referenceKey)
+
+	c.AssertExpectedValue(result)
+}
+
+func (c *ClientTestSuite) TestGetDuration_Client_ReturnsInterpolatedDuration() {
+	defer c.Client.Close()
+	const durationKey = "Parent:Timeout"
+	c.T().Setenv("CLIENT_TEST_TIMEOUT", "30s")
+	c.Provider.On("Get", filePath, durationKey).Return("${env:CLIENT_TEST_TIMEOUT}")
+	c.Client.SetInterpolator(NewInterpolator(nil, core.NewCache(time.Hour), time.Hour))
+
+	result := GetDuration(c.Client, filePath, durationKey)
+
+	assert.True(c.T(), result.IsOk())
+	assert.Equal(c.T(), 30*time.Second, result.Unwrap())
+}
+
+func (c *ClientTestSuite) TestClient_Get_InvalidPackage_ReturnsValidationError() {
+	defer c.Client.Close()
+	validator := new(MockValidator)
+	validator.On("Validate", stagingPathOf(c.WorkingPath)).Return(core.Err[core.Empty, core.Error](*NewViolationsError([]Violation{{FilePath: filePath, Message: "file is missing"}})))
+	c.Client.SetValidator(validator)
+
+	result := c.Client.Get(filePath, configKey)
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+	assert.False(c.T(), doesDirectoryExist(c.WorkingPath))
+	c.Provider.AssertNumberOfCalls(c.T(), "Get", 0)
+}
+
+func (c *ClientTestSuite) TestClient_Reload_InvalidPackage_KeepsCurrentPackage() {
+	defer c.Client.Close()
+	validator := new(MockValidator)
+	validator.On("Validate", stagingPathOf(c.WorkingPath)).Return(core.Ok[core.Empty, core.Error](core.Empty{})).Once()
+	validator.On("Validate", stagingPathOf(c.WorkingPath)).Return(core.Err[core.Empty, core.Error](*NewViolationsError([]Violation{{FilePath: filePath, Message: "file is missing"}})))
+	c.Client.SetValidator(validator)
+	_ = c.Client.Get(filePath, configKey)
+
+	reloadResult := c.Client.Reload()
+	result := c.Client.Get(filePath, configKey)
+
+	assert.True(c.T(), reloadResult.IsErr())
+	assert.True(c.T(), doesDirectoryExist(c.WorkingPath))
+	versionDirectories, _ := filepath.Glob(filepath.Join(getVersionsDirectoryPath(c.WorkingPath), "*"))
+	assert.Len(c.T(), versionDirectories, 1)
+	c.AssertExpectedValue(result)
+	c.Provider.AssertNumberOfCalls(c.T(), "CleanCache", 1)
+}
+
+func (c *ClientTestSuite) TestClient_Get_Healthy_SavesSnapshot() {
+	defer c.Client.Close()
+	snapshotPath := uuid.New().String()
+	defer os.RemoveAll(snapshotPath)
+	store := NewFileSnapshotStore(snapshotPath)
+	c.Client.SetSnapshotStore(store, time.Hour)
+
+	result := c.Client.Get(filePath, configKey)
+
+	c.AssertExpectedValue(result)
+	assert.Equal(c.T(), Healthy, c.Client.Status())
+	assert.True(c.T(), store.Load().IsOk())
+}
+
+func (c *ClientTestSuite) TestClient_Get_FailingDownloader_FallsBackToSnapshot() {
+	defer c.Client.Close()
+	snapshotPath := uuid.New().String()
+	defer os.RemoveAll(snapshotPath)
+	store := NewFileSnapshotStore(snapshotPath)
+	snapshotData := []byte("snapshot")
+	store.Save(snapshotData)
+	downloader := new(MockDownloader)
+	downloader.On("Download", host, stage, environment, component).Return(core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "server is down")))
+	c.Extractor.On("Extract", snapshotData, stagingPathOf(c.WorkingPath)).Return()
+	logger, _ := zap.NewDevelopment()
+	client := NewClient(logger, host, stage, environment, component, c.WorkingPath, downloader, c.Extractor, c.Provider)
+	defer client.Close()
+	client.SetSnapshotStore(store, time.Hour)
+
+	result := client.Get(filePath, configKey)
+
+	c.AssertExpectedValue(result)
+	assert.Equal(c.T(), Degraded, client.Status())
+	c.Extractor.AssertCalled(c.T(), "Extract", snapshotData, stagingPathOf(c.WorkingPath))
+}
+
+func (c *ClientTestSuite) TestClient_Reload_FailingDownloader_KeepsCurrentPackage() {
+	snapshotPath := uuid.New().String()
+	defer os.RemoveAll(snapshotPath)
+	store := NewFileSnapshotStore(snapshotPath)
+	store.Save([]byte("snapshot"))
+	downloader := new(MockDownloader)
+	downloader.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error](c.PackageData)).Once()
+	downloader.On("Download", host, stage, environment, component).Return(core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "server is down")))
+	logger, _ := zap.NewDevelopment()
+	client := NewClient(logger, host, stage, environment, component, c.WorkingPath, downloader, c.Extractor, c.Provider)
+	defer client.Close()
+	client.SetSnapshotStore(store, time.Hour)
+	_ = client.Get(filePath, configKey)
+
+	result := client.Reload()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.ConfigurationRetrievalFailure, result.UnwrapErr().ErrorKind)
+	assert.Equal(c.T(), Healthy, client.Status())
+	c.Extractor.AssertNumberOfCalls(c.T(), "Extract", 1)
+	c.AssertExpectedValue(client.Get(filePath, configKey))
+}
+
+func (c *ClientTestSuite) TestClient_Degraded_RecoversInBackground() {
+	snapshotPath := uuid.New().String()
+	defer os.RemoveAll(snapshotPath)
+	store := NewFileSnapshotStore(snapshotPath)
+	store.Save(c.PackageData)
+	downloader := new(MockDownloader)
+	downloader.On("Download", host, stage, environment, component).Return(core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "server is down"))).Once()
+	downloader.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error](c.PackageData))
+	logger, _ := zap.NewDevelopment()
+	client := NewClient(logger, host, stage, environment, component, c.WorkingPath, downloader, c.Extractor, c.Provider)
+	defer client.Close()
+	client.SetSnapshotStore(store, 10*time.Millisecond)
+
+	_ = client.Get(filePath, configKey)
+
+	assert.Eventually(c.T(), func() bool { return client.Status() == Healthy }, time.Second, 10*time.Millisecond)
+}
+
+func (c *ClientTestSuite) TestClient_Degraded_FailingRetries_KeepSnapshot() {
+	snapshotPath := uuid.New().String()
+	defer os.RemoveAll(snapshotPath)
+	store := NewFileSnapshotStore(snapshotPath)
+	store.Save(c.PackageData)
+	var attempts atomic.Int32
+	downloader := new(MockDownloader)
+	downloader.On("Download", host, stage, environment, component).Return(core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "server is down"))).Run(func(mock.Arguments) {
+		attempts.Add(1)
+	})
+	logger, _ := zap.NewDevelopment()
+	client := NewClient(logger, host, stage, environment, component, c.WorkingPath, downloader, c.Extractor, c.Provider)
+	client.SetSnapshotStore(store, 5*time.Millisecond)
+
+	_ = client.Get(filePath, configKey)
+	assert.Eventually(c.T(), func() bool { return attempts.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	client.Close()
+
+	c.Extractor.AssertNumberOfCalls(c.T(), "Extract", 1)
+	assert.Equal(c.T(), Degraded, client.Status())
+	assert.False(c.T(), doesDirectoryExist(c.WorkingPath))
+}
+
+func (c *ClientTestSuite) TestClient_Get_FailingDownloaderWithoutSnapshot_Error() {
+	downloader := new(MockDownloader)
+	downloader.On("Download", host, stage, environment, component).Return(core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "server is down")))
+	logger, _ := zap.NewDevelopment()
+	client := NewClient(logger, host, stage, environment, component, c.WorkingPath, downloader, c.Extractor, c.Provider)
+	defer client.Close()
+	client.SetSnapshotStore(NewFileSnapshotStore(uuid.New().String()), time.Hour)
+
+	result := client.Get(filePath, configKey)
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.ConfigurationRetrievalFailure, result.UnwrapErr().ErrorKind)
+	assert.Equal(c.T(), Uninitialized, client.Status())
+}
+
+func (c *ClientTestSuite) TestClient_Reload_NotModified_SkipsExtraction() {
+	downloader := new(MockConditionalDownloader)
+	downloader.On("ResetConditions", host, stage, environment, component).Return()
+	downloader.On("DownloadIfModified", host, stage, environment, component).Return(core.Ok[[]byte, core.Error](c.PackageData)).Once()
+	downloader.On("DownloadIfModified", host, stage, environment, component).Return(core.Err[[]byte, core.Error](*core.NewError(core.NotModified, "not modified")))
+	logger, _ := zap.NewDevelopment()
+	client := NewClient(logger, host, stage, environment, component, c.WorkingPath, downloader, c.Extractor, c.Provider)
+	defer client.Close()
+	_ = client.Get(filePath, configKey)
+
+	result := client.Reload()
+
+	assert.True(c.T(), result.IsOk())
+	downloader.AssertNumberOfCalls(c.T(), "ResetConditions", 1)
+	c.Extractor.AssertNumberOfCalls(c.T(), "Extract", 1)
+	c.Provider.AssertNumberOfCalls(c.T(), "CleanCache", 1)
+}
+
 func (c *ClientTestSuite) TestClient_Close_RemovesWorkingPath() {
 	c.Client.Close()
 
@@ -96,6 +342,349 @@ func (c *ClientTestSuite) TestClient_Close_RemovesWorkingPath() {
 	assert.True(c.T(), os.IsNotExist(err))
 }
 
+func (c *ClientTestSuite) TestClient_Get_StreamingDownloaderAndExtractor_SpoolsPackage() {
+	packageData := createZip(map[string]string{filePath: "Parent:\n  Child: " + value})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(packageData)
+	}))
+	defer server.Close()
+	logger, _ := zap.NewDevelopment()
+	downloader := NewServerDownloader(logger, "token", time.Second)
+	spoolDirectory := uuid.New().String()
+	_ = os.MkdirAll(spoolDirectory, os.ModePerm)
+	defer os.RemoveAll(spoolDirectory)
+	downloader.SetSpoolDirectory(spoolDirectory)
+	snapshotPath := uuid.New().String()
+	defer os.RemoveAll(snapshotPath)
+	store := NewFileSnapshotStore(snapshotPath)
+	provider := NewFileProvider(c.WorkingPath, core.NewCache(time.Hour), time.Hour)
+	client := NewClient(logger, server.URL, stage, environment, component, c.WorkingPath, downloader, NewAutoExtractor(logger), provider)
+	client.SetSnapshotStore(store, time.Hour)
+	defer client.Close()
+
+	result := client.Get(filePath, configKey)
+
+	spooledFiles, _ := os.ReadDir(spoolDirectory)
+	snapshotResult := store.Load()
+	c.AssertExpectedValue(result)
+	assert.Empty(c.T(), spooledFiles)
+	assert.True(c.T(), snapshotResult.IsOk())
+	assert.Equal(c.T(), packageData, snapshotResult.Unwrap().PackageData)
+}
+
+func (c *ClientTestSuite) TestClient_Reload_SignedPackage_Verified() {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	packageData := createZip(map[string]string{filePath: "Parent:\n  Child: " + value})
+	client := c.newSignedPackageClient(packageData, packageData, privateKey)
+	client.SetPackageVerifier(NewPackageVerifier([]ed25519.PublicKey{publicKey}, true))
+	defer client.Close()
+
+	result := client.Reload()
+
+	assert.True(c.T(), result.IsOk())
+	assert.Equal(c.T(), Healthy, client.Status())
+}
+
+func (c *ClientTestSuite) TestClient_Reload_TamperedPackage_IntegrityFailure() {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	packageData := createZip(map[string]string{filePath: "Parent:\n  Child: " + value})
+	client := c.newSignedPackageClient(packageData, createZip(map[string]string{filePath: "Tampered: true"}), privateKey)
+	client.SetPackageVerifier(NewPackageVerifier([]ed25519.PublicKey{publicKey}, true))
+	defer client.Close()
+
+	result := client.Reload()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.IntegrityFailure, result.UnwrapErr().ErrorKind)
+	assert.False(c.T(), doesDirectoryExist(c.WorkingPath))
+}
+
+func (c *ClientTestSuite) TestClient_Reload_UnsignedDownloader_IntegrityFailure() {
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	c.Client.SetPackageVerifier(NewPackageVerifier([]ed25519.PublicKey{publicKey}, false))
+
+	result := c.Client.Reload()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.IntegrityFailure, result.UnwrapErr().ErrorKind)
+	c.Extractor.AssertNotCalled(c.T(), "Extract", mock.Anything, mock.Anything)
+}
+
+func (c *ClientTestSuite) TestClient_Get_VersionedDownloader_ReportsActiveVersion() {
+	downloader := c.CreateVersionedDownloader()
+	client := c.CreateClient(downloader)
+	defer client.Close()
+
+	result := client.Get(filePath, configKey)
+
+	c.AssertExpectedValue(result)
+	assert.Equal(c.T(), "3.0.0", client.ActiveVersion())
+	assert.Empty(c.T(), client.PinnedVersion())
+}
+
+func (c *ClientTestSuite) TestClient_Pin_LoadsPinnedVersion() {
+	downloader := c.CreateVersionedDownloader()
+	client := c.CreateClient(downloader)
+	defer client.Close()
+	_ = client.Get(filePath, configKey)
+
+	pinResult := client.Pin("1.0.0")
+	reloadResult := client.Reload()
+
+	assert.True(c.T(), pinResult.IsOk())
+	assert.True(c.T(), reloadResult.IsOk())
+	assert.Equal(c.T(), "1.0.0", client.ActiveVersion())
+	assert.Equal(c.T(), "1.0.0", client.PinnedVersion())
+	downloader.AssertNumberOfCalls(c.T(), "DownloadVersion", 3)
+	downloader.AssertCalled(c.T(), "DownloadVersion", host, stage, environment, component, "1.0.0")
+}
+
+func (c *ClientTestSuite) TestClient_Pin_FailingDownload_KeepsPreviousPin() {
+	downloader := c.CreateVersionedDownloader()
+	downloader.On("DownloadVersion", host, stage, environment, component, "9.9.9").Return(core.Err[VersionedPackage, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "unknown version")))
+	client := c.CreateClient(downloader)
+	defer client.Close()
+	_ = client.Get(filePath, configKey)
+
+	result := client.Pin("9.9.9")
+
+	assert.True(c.T(), result.IsErr())
+	assert.Empty(c.T(), client.PinnedVersion())
+	assert.Equal(c.T(), "3.0.0", client.ActiveVersion())
+}
+
+func (c *ClientTestSuite) TestClient_Pin_UnversionedDownloader_Error() {
+	defer c.Client.Close()
+
+	result := c.Client.Pin("1.0.0")
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+	c.Downloader.AssertNumberOfCalls(c.T(), "Download", 0)
+}
+
+func (c *ClientTestSuite) TestClient_Unpin_LoadsLatestVersion() {
+	downloader := c.CreateVersionedDownloader()
+	client := c.CreateClient(downloader)
+	defer client.Close()
+	_ = client.Pin("1.0.0")
+
+	result := client.Unpin()
+
+	assert.True(c.T(), result.IsOk())
+	assert.Empty(c.T(), client.PinnedVersion())
+	assert.Equal(c.T(), "3.0.0", client.ActiveVersion())
+}
+
+func (c *ClientTestSuite) TestClient_Rollback_VersionedDownloader_PinsPreviousVersion() {
+	downloader := c.CreateVersionedDownloader()
+	client := c.CreateClient(downloader)
+	defer client.Close()
+	_ = client.Get(filePath, configKey)
+
+	result := client.Rollback()
+
+	assert.True(c.T(), result.IsOk())
+	assert.Equal(c.T(), "2.0.0", client.ActiveVersion())
+	assert.Equal(c.T(), "2.0.0", client.PinnedVersion())
+}
+
+func (c *ClientTestSuite) TestClient_Rollback_OldestVersion_NotFound() {
+	downloader := c.CreateVersionedDownloader()
+	client := c.CreateClient(downloader)
+	defer client.Close()
+	_ = client.Pin("1.0.0")
+
+	result := client.Rollback()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+	assert.Equal(c.T(), "1.0.0", client.ActiveVersion())
+}
+
+func (c *ClientTestSuite) TestClient_Rollback_UnversionedDownloader_RestoresPreviousPackage() {
+	defer c.Client.Close()
+	_ = c.Client.Get(filePath, configKey)
+	_ = c.Client.Reload()
+
+	result := c.Client.Rollback()
+	secondResult := c.Client.Rollback()
+
+	assert.True(c.T(), result.IsOk())
+	assert.True(c.T(), secondResult.IsOk())
+	assert.True(c.T(), doesDirectoryExist(c.WorkingPath))
+	c.Provider.AssertNumberOfCalls(c.T(), "CleanCache", 4)
+}
+
+func (c *ClientTestSuite) TestClient_Rollback_WithoutPreviousPackage_NotFound() {
+	defer c.Client.Close()
+	_ = c.Client.Get(filePath, configKey)
+
+	result := c.Client.Rollback()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (c *ClientTestSuite) TestClient_Get_Concurrent_InitializesOnce() {
+	downloader := new(MockDownloader)
+	downloader.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error](c.PackageData)).After(50 * time.Millisecond)
+	client := c.CreateClient(downloader)
+	defer client.Close()
+	results := make([]core.Result[any, core.Error], 10)
+	var waitGroup sync.WaitGroup
+
+	for index := range results {
+		waitGroup.Add(1)
+
+		go func(index int) {
+			defer waitGroup.Done()
+			results[index] = client.Get(filePath, configKey)
+		}(index)
+	}
+
+	waitGroup.Wait()
+
+	for _, result := range results {
+		c.AssertExpectedValue(result)
+	}
+
+	downloader.AssertNumberOfCalls(c.T(), "Download", 1)
+	c.Extractor.AssertNumberOfCalls(c.T(), "Extract", 1)
+}
+
+func (c *ClientTestSuite) TestClient_Start_PreloadsPackage() {
+	defer c.Client.Close()
+
+	startResult := c.Client.Start(context.Background())
+	result := c.Client.Get(filePath, configKey)
+
+	assert.True(c.T(), startResult.IsOk())
+	assert.Equal(c.T(), Healthy, c.Client.Status())
+	c.AssertExpectedValue(result)
+	c.AssertCompleteFlowExecutedTimes(1)
+}
+
+func (c *ClientTestSuite) TestClient_Start_ContextDone_ReturnsErrorAndKeepsLoading() {
+	downloader := new(MockDownloader)
+	downloader.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error](c.PackageData)).After(200 * time.Millisecond)
+	client := c.CreateClient(downloader)
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	startResult := client.Start(ctx)
+	result := client.Get(filePath, configKey)
+
+	assert.True(c.T(), startResult.IsErr())
+	assert.Equal(c.T(), core.ConfigurationRetrievalFailure, startResult.UnwrapErr().ErrorKind)
+	c.AssertExpectedValue(result)
+	downloader.AssertNumberOfCalls(c.T(), "Download", 1)
+}
+
+func (c *ClientTestSuite) TestClient_Reload_Closed_Error() {
+	c.Client.Close()
+
+	result := c.Client.Reload()
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.ConfigurationRetrievalFailure, result.UnwrapErr().ErrorKind)
+	assert.False(c.T(), doesDirectoryExist(c.WorkingPath))
+	c.Downloader.AssertNumberOfCalls(c.T(), "Download", 0)
+}
+
+func (c *ClientTestSuite) TestClient_Get_UncreatableWorkingPath_IOFailure() {
+	parentPath := uuid.New().String()
+	_ = os.WriteFile(parentPath, []byte("file"), 0644)
+	defer os.Remove(parentPath)
+	logger, _ := zap.NewDevelopment()
+	client := NewClient(logger, host, stage, environment, component, filepath.Join(parentPath, "config"), c.Downloader, c.Extractor, c.Provider)
+
+	result := client.Get(filePath, configKey)
+
+	assert.True(c.T(), result.IsErr())
+	assert.Equal(c.T(), core.IOFailure, result.UnwrapErr().ErrorKind)
+	c.Downloader.AssertNumberOfCalls(c.T(), "Download", 0)
+}
+
+func (c *ClientTestSuite) TestClient_Reload_ChangedPackage_NotifiesSubscribers() {
+	root := uuid.New().String()
+	defer os.RemoveAll(root)
+	packagePath := filepath.Join(root, stage, environment, component)
+	_ = os.MkdirAll(packagePath, os.ModePerm)
+	_ = os.WriteFile(filepath.Join(packagePath, filePath), []byte("Parent:\n  Child: a\n  Password: old\n"), 0644)
+	logger, _ := zap.NewDevelopment()
+	provider := NewFileProvider(c.WorkingPath, core.NewCache(time.Hour), time.Hour)
+	client := NewClient(logger, root, stage, environment, component, c.WorkingPath, NewDirectoryDownloader(), NewZipExtractor(logger), provider)
+	defer client.Close()
+	diffs := make([]ConfigDiff, 0)
+	client.Subscribe(func(diff ConfigDiff) {
+		diffs = append(diffs, diff)
+	})
+	unsubscribe := client.Subscribe(func(diff ConfigDiff) {
+		assert.Fail(c.T(), "unsubscribed subscriber has been called")
+	})
+	unsubscribe()
+	_ = client.Get(filePath, configKey)
+	_ = os.WriteFile(filepath.Join(packagePath, filePath), []byte("Parent:\n  Child: "+value+"\n  Password: new\n"), 0644)
+
+	reloadResult := client.Reload()
+	result := client.Get(filePath, configKey)
+
+	assert.True(c.T(), reloadResult.IsOk())
+	c.AssertExpectedValue(result)
+	assert.Equal(c.T(), []ConfigDiff{{Changes: []Change{
+		{Type: Changed, FilePath: filePath, KeyPath: "Parent:Child", OldValue: "a", NewValue: value},
+		{Type: Changed, FilePath: filePath, KeyPath: "Parent:Password", OldValue: RedactedValue, NewValue: RedactedValue},
+	}}}, diffs)
+}
+
+func (c *ClientTestSuite) TestClient_Reload_EncryptedAtRest_NotifiesDecryptedChanges() {
+	keyDirectory := c.T().TempDir()
+	_ = os.WriteFile(filepath.Join(keyDirectory, keyId+keyFileExtension), bytes.Repeat([]byte{1}, keyEncryptionKeySize), 0600)
+	keyProvider := NewFileKeyProvider(keyDirectory)
+	downloader := new(MockDownloader)
+	downloader.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error](EncryptEnvelope(keyProvider, keyId, createZip(map[string]string{filePath: "Parent:\n  Child: a\n  Same: b\n"})).Unwrap())).Once()
+	downloader.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error](EncryptEnvelope(keyProvider, keyId, createZip(map[string]string{filePath: "Parent:\n  Child: " + value + "\n  Same: b\n"})).Unwrap()))
+	logger, _ := zap.NewDevelopment()
+	extractor := NewEncryptedExtractor(logger, keyProvider, NewZipExtractor(logger))
+	extractor.SetEncryptionAtRest(keyId)
+	provider := NewFileProvider(c.WorkingPath, core.NewCache(time.Hour), time.Hour)
+	provider.SetKeyProvider(keyProvider)
+	client := NewClient(logger, host, stage, environment, component, c.WorkingPath, downloader, extractor, provider)
+	defer client.Close()
+	diffs := make([]ConfigDiff, 0)
+	client.Subscribe(func(diff ConfigDiff) {
+		diffs = append(diffs, diff)
+	})
+	_ = client.Get(filePath, configKey)
+
+	reloadResult := client.Reload()
+
+	assert.True(c.T(), reloadResult.IsOk())
+	assert.Equal(c.T(), []ConfigDiff{{Changes: []Change{
+		{Type: Changed, FilePath: filePath, KeyPath: "Parent:Child", OldValue: "a", NewValue: value},
+	}}}, diffs)
+}
+
+func (c *ClientTestSuite) CreateVersionedDownloader() *MockVersionedDownloader {
+	downloader := new(MockVersionedDownloader)
+	downloader.On("ListVersions", host, stage, environment, component).Return(core.Ok[[]PackageVersion, core.Error]([]PackageVersion{{Version: "3.0.0"}, {Version: "2.0.0"}, {Version: "1.0.0"}}))
+	downloader.On("DownloadVersion", host, stage, environment, component, "").Return(core.Ok[VersionedPackage, core.Error](VersionedPackage{Version: "3.0.0", Data: c.PackageData}))
+
+	for _, version := range []string{"2.0.0", "1.0.0"} {
+		downloader.On("DownloadVersion", host, stage, environment, component, version).Return(core.Ok[VersionedPackage, core.Error](VersionedPackage{Version: version, Data: c.PackageData}))
+	}
+
+	return downloader
+}
+
+func (c *ClientTestSuite) CreateClient(downloader Downloader) *Client {
+	logger, _ := zap.NewDevelopment()
+
+	return NewClient(logger, host, stage, environment, component, c.WorkingPath, downloader, c.Extractor, c.Provider)
+}
+
 func (c *ClientTestSuite) AssertExpectedValue(result core.Result[any, core.Error]) {
 	assert.True(c.T(), result.IsOk())
 	assert.Equal(c.T(), value, result.Unwrap())
@@ -107,3 +696,17 @@ func (c *ClientTestSuite) AssertCompleteFlowExecutedTimes(times int) {
 	c.Provider.AssertNumberOfCalls(c.T(), "CleanCache", times)
 	c.Provider.AssertNumberOfCalls(c.T(), "Get", times)
 }
+
+// newSignedPackageClient creates a Client whose server signs the signedPackage but serves the servedPackage.
+func (c *ClientTestSuite) newSignedPackageClient(signedPackage []byte, servedPackage []byte, privateKey ed25519.PrivateKey) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(PackageDigestHeader, ComputePackageDigest(signedPackage))
+		w.Header().Set(PackageSignatureHeader, SignPackage(privateKey, signedPackage))
+		_, _ = w.Write(servedPackage)
+	}))
+	c.T().Cleanup(server.Close)
+	logger, _ := zap.NewDevelopment()
+	provider := NewFileProvider(c.WorkingPath, core.NewCache(time.Hour), time.Hour)
+
+	return NewClient(logger, server.URL, stage, environment, component, c.WorkingPath, NewServerDownloader(logger, "token", time.Second), NewZipExtractor(logger), provider)
+}
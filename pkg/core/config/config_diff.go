@@ -0,0 +1,292 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RedactedValue replaces the values of secret keys within a ConfigDiff.
+const RedactedValue = "[REDACTED]"
+
+// DefaultSecretKeyPattern matches the names of the keys whose values are considered secrets.
+var DefaultSecretKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private[_-]?key|api[_-]?key)`)
+
+// ChangeType describes how a key path has changed between two configuration packages.
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+// Change is a single difference between two configuration packages. Files which are not YAML, or cannot be parsed
+// as YAML, are compared by content and their changes have an empty KeyPath and no values.
+type Change struct {
+	Type     ChangeType `json:"type"`
+	FilePath string     `json:"file"`
+	KeyPath  string     `json:"key,omitempty"`
+	OldValue any        `json:"old,omitempty"`
+	NewValue any        `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	if len(c.KeyPath) == 0 {
+		return fmt.Sprintf("%s %s", c.Type, c.FilePath)
+	}
+
+	return fmt.Sprintf("%s %s '%s'", c.Type, c.FilePath, c.KeyPath)
+}
+
+// ConfigDiff lists the changes made by replacing a configuration package with another one.
+type ConfigDiff struct {
+	// FromVersion and ToVersion are the versions of the replaced and the new packages, when known.
+	FromVersion string   `json:"from,omitempty"`
+	ToVersion   string   `json:"to,omitempty"`
+	Changes     []Change `json:"changes"`
+}
+
+// IsEmpty returns whether both packages contain the same configuration.
+func (d ConfigDiff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+// DiffPackages compares the configuration packages extracted within previousPath and currentPath.
+// The values located under a key whose name matches the secretKeyPattern are replaced by RedactedValue;
+// a nil pattern disables the redaction. Changes are sorted by file and key path.
+// The files encrypted at rest by an EncryptedExtractor are decrypted through the keyProvider, which may be nil,
+// since each encryption of the same content differs.
+func DiffPackages(previousPath string, currentPath string, secretKeyPattern *regexp.Regexp, keyProvider KeyProvider) core.Result[[]Change, core.Error] {
+	previousFilesResult := listPackageFiles(previousPath, keyProvider)
+
+	if previousFilesResult.IsErr() {
+		return core.Err[[]Change, core.Error](previousFilesResult.UnwrapErr())
+	}
+
+	currentFilesResult := listPackageFiles(currentPath, keyProvider)
+
+	if currentFilesResult.IsErr() {
+		return core.Err[[]Change, core.Error](currentFilesResult.UnwrapErr())
+	}
+
+	previousFiles := previousFilesResult.Unwrap()
+	currentFiles := currentFilesResult.Unwrap()
+	differ := configDiffer{secretKeyPattern: secretKeyPattern, changes: make([]Change, 0)}
+
+	for _, filePath := range unionOfKeys(previousFiles, currentFiles) {
+		previousContent, inPrevious := previousFiles[filePath]
+		currentContent, inCurrent := currentFiles[filePath]
+
+		switch {
+		case !inCurrent:
+			differ.changes = append(differ.changes, Change{Type: Removed, FilePath: filePath})
+		case !inPrevious:
+			differ.changes = append(differ.changes, Change{Type: Added, FilePath: filePath})
+		case !bytes.Equal(previousContent, currentContent):
+			differ.diffFile(filePath, previousContent, currentContent)
+		}
+	}
+
+	return core.Ok[[]Change, core.Error](differ.changes)
+}
+
+type configDiffer struct {
+	secretKeyPattern *regexp.Regexp
+	changes          []Change
+}
+
+func (d *configDiffer) diffFile(filePath string, previousContent []byte, currentContent []byte) {
+	extension := strings.ToLower(filepath.Ext(filePath))
+
+	var previousDocument, currentDocument any
+
+	if extension != ".yaml" && extension != ".yml" ||
+		yaml.Unmarshal(previousContent, &previousDocument) != nil ||
+		yaml.Unmarshal(currentContent, &currentDocument) != nil {
+		d.changes = append(d.changes, Change{Type: Changed, FilePath: filePath})
+		return
+	}
+
+	d.diffValues(filePath, "", previousDocument, currentDocument, false)
+}
+
+func (d *configDiffer) diffValues(filePath string, keyPath string, previous any, current any, secret bool) {
+	previousMap, previousIsMap := previous.(map[string]any)
+	currentMap, currentIsMap := current.(map[string]any)
+
+	if previousIsMap && currentIsMap {
+		for _, key := range unionOfKeys(previousMap, currentMap) {
+			childKeyPath := joinKeyPath(keyPath, key)
+			childSecret := secret || d.isSecretKey(key)
+			previousValue, inPrevious := previousMap[key]
+			currentValue, inCurrent := currentMap[key]
+
+			switch {
+			case !inCurrent:
+				d.addChange(Removed, filePath, childKeyPath, previousValue, nil, childSecret)
+			case !inPrevious:
+				d.addChange(Added, filePath, childKeyPath, nil, currentValue, childSecret)
+			default:
+				d.diffValues(filePath, childKeyPath, previousValue, currentValue, childSecret)
+			}
+		}
+
+		return
+	}
+
+	previousList, previousIsList := previous.([]any)
+	currentList, currentIsList := current.([]any)
+
+	if previousIsList && currentIsList {
+		for index := 0; index < len(previousList) || index < len(currentList); index++ {
+			childKeyPath := joinKeyPath(keyPath, strconv.Itoa(index))
+
+			switch {
+			case index >= len(currentList):
+				d.addChange(Removed, filePath, childKeyPath, previousList[index], nil, secret)
+			case index >= len(previousList):
+				d.addChange(Added, filePath, childKeyPath, nil, currentList[index], secret)
+			default:
+				d.diffValues(filePath, childKeyPath, previousList[index], currentList[index], secret)
+			}
+		}
+
+		return
+	}
+
+	if !valuesEqual(previous, current) {
+		d.addChange(Changed, filePath, keyPath, previous, current, secret)
+	}
+}
+
+func (d *configDiffer) addChange(changeType ChangeType, filePath string, keyPath string, oldValue any, newValue any, secret bool) {
+	if secret {
+		oldValue = redactValue(oldValue)
+		newValue = redactValue(newValue)
+	} else {
+		oldValue = d.redactSecrets(oldValue)
+		newValue = d.redactSecrets(newValue)
+	}
+
+	d.changes = append(d.changes, Change{Type: changeType, FilePath: filePath, KeyPath: keyPath, OldValue: oldValue, NewValue: newValue})
+}
+
+func (d *configDiffer) isSecretKey(key string) bool {
+	return d.secretKeyPattern != nil && d.secretKeyPattern.MatchString(key)
+}
+
+// redactSecrets copies the maps and lists of an added, removed or replaced subtree, redacting the values of its
+// secret keys at any depth.
+func (d *configDiffer) redactSecrets(value any) any {
+	switch typedValue := value.(type) {
+	case map[string]any:
+		redactedMap := make(map[string]any, len(typedValue))
+
+		for key, childValue := range typedValue {
+			if d.isSecretKey(key) {
+				redactedMap[key] = redactValue(childValue)
+			} else {
+				redactedMap[key] = d.redactSecrets(childValue)
+			}
+		}
+
+		return redactedMap
+	case []any:
+		redactedList := make([]any, len(typedValue))
+
+		for index, childValue := range typedValue {
+			redactedList[index] = d.redactSecrets(childValue)
+		}
+
+		return redactedList
+	default:
+		return value
+	}
+}
+
+func redactValue(value any) any {
+	if value == nil {
+		return nil
+	}
+
+	return RedactedValue
+}
+
+// listPackageFiles reads every regular file within the package, keyed by its slash separated relative path,
+// decrypting the ones encrypted at rest when a key provider is specified.
+func listPackageFiles(packagePath string, keyProvider KeyProvider) core.Result[map[string][]byte, core.Error] {
+	files := make(map[string][]byte)
+
+	// The package path may be a link, i.e. a Client's working path, which WalkDir would not follow.
+	packagePath, err := filepath.EvalSymlinks(packagePath)
+
+	if err != nil {
+		return core.Err[map[string][]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read configuration package: %s", err)))
+	}
+
+	// readError keeps the kind of the error which failed to read a file, i.e. a 'decryption_failure'.
+	readError := core.None[core.Error]()
+	err = filepath.WalkDir(packagePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(packagePath, path)
+
+		if err != nil {
+			return err
+		}
+
+		contentResult := readConfigFile(path, keyProvider)
+
+		if contentResult.IsErr() {
+			readError = core.Some(contentResult.UnwrapErr())
+
+			return filepath.SkipAll
+		}
+
+		files[filepath.ToSlash(relativePath)] = contentResult.Unwrap()
+
+		return nil
+	})
+
+	if readError.IsSome() {
+		return core.Err[map[string][]byte, core.Error](readError.Unwrap())
+	}
+
+	if err != nil {
+		return core.Err[map[string][]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read configuration package '%s': %s", packagePath, err)))
+	}
+
+	return core.Ok[map[string][]byte, core.Error](files)
+}
+
+func unionOfKeys[T any](a map[string]T, b map[string]T) []string {
+	keys := make([]string, 0, len(a)+len(b))
+
+	for key := range a {
+		keys = append(keys, key)
+	}
+
+	for key := range b {
+		if _, exists := a[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
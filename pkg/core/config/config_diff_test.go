@@ -0,0 +1,119 @@
+package config
+
+import (
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffPackages_ChangedKeys_ReturnsSortedChanges(t *testing.T) {
+	previousPath := writePackage(t, map[string]string{"application.yaml": "Server:\n  Host: a\n  Port: 80\nOld: 1\n"})
+	currentPath := writePackage(t, map[string]string{"application.yaml": "Server:\n  Host: b\n  Port: 80\nNew: 2\n"})
+
+	result := DiffPackages(previousPath, currentPath, DefaultSecretKeyPattern, nil)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []Change{
+		{Type: Added, FilePath: "application.yaml", KeyPath: "New", NewValue: 2},
+		{Type: Removed, FilePath: "application.yaml", KeyPath: "Old", OldValue: 1},
+		{Type: Changed, FilePath: "application.yaml", KeyPath: "Server:Host", OldValue: "a", NewValue: "b"},
+	}, result.Unwrap())
+}
+
+func TestDiffPackages_SecretKeys_RedactsValues(t *testing.T) {
+	previousPath := writePackage(t, map[string]string{"application.yaml": "Database:\n  Password: old\nCredentials:\n  User: a\n"})
+	currentPath := writePackage(t, map[string]string{"application.yaml": "Database:\n  Password: new\nCredentials:\n  User: b\n"})
+
+	result := DiffPackages(previousPath, currentPath, DefaultSecretKeyPattern, nil)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []Change{
+		{Type: Changed, FilePath: "application.yaml", KeyPath: "Credentials:User", OldValue: RedactedValue, NewValue: RedactedValue},
+		{Type: Changed, FilePath: "application.yaml", KeyPath: "Database:Password", OldValue: RedactedValue, NewValue: RedactedValue},
+	}, result.Unwrap())
+}
+
+func TestDiffPackages_AddedAndRemovedSubtrees_RedactsNestedSecrets(t *testing.T) {
+	previousPath := writePackage(t, map[string]string{"application.yaml": "Cache:\n  Nodes:\n    - Host: a\n      Token: old\n"})
+	currentPath := writePackage(t, map[string]string{"application.yaml": "Database:\n  Host: db\n  Password: hunter2\n"})
+
+	result := DiffPackages(previousPath, currentPath, DefaultSecretKeyPattern, nil)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []Change{
+		{Type: Removed, FilePath: "application.yaml", KeyPath: "Cache", OldValue: map[string]any{"Nodes": []any{map[string]any{"Host": "a", "Token": RedactedValue}}}},
+		{Type: Added, FilePath: "application.yaml", KeyPath: "Database", NewValue: map[string]any{"Host": "db", "Password": RedactedValue}},
+	}, result.Unwrap())
+}
+
+func TestDiffPackages_NilPattern_KeepsValues(t *testing.T) {
+	previousPath := writePackage(t, map[string]string{"application.yaml": "Password: old\n"})
+	currentPath := writePackage(t, map[string]string{"application.yaml": "Password: new\n"})
+
+	result := DiffPackages(previousPath, currentPath, nil, nil)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []Change{{Type: Changed, FilePath: "application.yaml", KeyPath: "Password", OldValue: "old", NewValue: "new"}}, result.Unwrap())
+}
+
+func TestDiffPackages_Lists_ComparesByIndex(t *testing.T) {
+	previousPath := writePackage(t, map[string]string{"application.yaml": "Servers:\n  - a\n  - b\n"})
+	currentPath := writePackage(t, map[string]string{"application.yaml": "Servers:\n  - c\n  - b\n  - d\n"})
+
+	result := DiffPackages(previousPath, currentPath, DefaultSecretKeyPattern, nil)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []Change{
+		{Type: Changed, FilePath: "application.yaml", KeyPath: "Servers:0", OldValue: "a", NewValue: "c"},
+		{Type: Added, FilePath: "application.yaml", KeyPath: "Servers:2", NewValue: "d"},
+	}, result.Unwrap())
+}
+
+func TestDiffPackages_Files_ReportsAddedRemovedAndChangedFiles(t *testing.T) {
+	previousPath := writePackage(t, map[string]string{"removed.yaml": "A: 1\n", "data/notes.txt": "a", "same.yaml": "A: 1\n"})
+	currentPath := writePackage(t, map[string]string{"added.yaml": "A: 1\n", "data/notes.txt": "b", "same.yaml": "A: 1\n"})
+
+	result := DiffPackages(previousPath, currentPath, DefaultSecretKeyPattern, nil)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []Change{
+		{Type: Added, FilePath: "added.yaml"},
+		{Type: Changed, FilePath: "data/notes.txt"},
+		{Type: Removed, FilePath: "removed.yaml"},
+	}, result.Unwrap())
+}
+
+func TestDiffPackages_MissingPackage_IOFailure(t *testing.T) {
+	currentPath := writePackage(t, map[string]string{"application.yaml": "A: 1\n"})
+
+	result := DiffPackages(uuid.New().String(), currentPath, DefaultSecretKeyPattern, nil)
+
+	assert.True(t, result.IsErr())
+}
+
+func TestDiffPackages_UndecryptableFile_DecryptionFailure(t *testing.T) {
+	keyProvider := NewFileKeyProvider(t.TempDir())
+	previousPath := writePackage(t, map[string]string{"application.yaml": string(envelopeMagic) + "broken"})
+	currentPath := writePackage(t, map[string]string{"application.yaml": "Value: 1\n"})
+
+	result := DiffPackages(previousPath, currentPath, DefaultSecretKeyPattern, keyProvider)
+
+	assert.True(t, result.IsErr())
+	assert.Equal(t, core.DecryptionFailure, result.UnwrapErr().ErrorKind)
+}
+
+func writePackage(t *testing.T, files map[string]string) string {
+	packagePath := uuid.New().String()
+	t.Cleanup(func() { _ = os.RemoveAll(packagePath) })
+
+	for filePath, content := range files {
+		fullPath := filepath.Join(packagePath, filePath)
+		_ = os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
+		_ = os.WriteFile(fullPath, []byte(content), 0644)
+	}
+
+	return packagePath
+}
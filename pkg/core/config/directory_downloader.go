@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const fileScheme = "file://"
+
+// packageFileExtensions are the extensions of the package files looked up by the DirectoryDownloader, in order.
+var packageFileExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.zst"}
+
+// DirectoryDownloader reads configuration packages from a local directory, which is useful for local development.
+// The host is the root directory, optionally prefixed by 'file://', and each package is located at
+// '<root>/<stage>/<environment>/<component>', either as a directory, which is packed as a zip package, or as a
+// package file with one of the extensions: '.zip', '.tar', '.tar.gz', '.tgz' or '.tar.zst'.
+type DirectoryDownloader struct{}
+
+func NewDirectoryDownloader() *DirectoryDownloader {
+	return new(DirectoryDownloader)
+}
+
+func (d *DirectoryDownloader) Download(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
+	for _, name := range []string{stage, environment, component} {
+		if len(name) == 0 || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return core.Err[[]byte, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("invalid package path segment '%s'", name)))
+		}
+	}
+
+	packagePath := filepath.Join(strings.TrimPrefix(host, fileScheme), stage, environment, component)
+
+	if info, err := os.Stat(packagePath); err == nil && info.IsDir() {
+		return PackDirectory(packagePath)
+	}
+
+	for _, extension := range packageFileExtensions {
+		packageData, err := os.ReadFile(packagePath + extension)
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return core.Err[[]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read package file '%s': %s", packagePath+extension, err)))
+		}
+
+		return core.Ok[[]byte, core.Error](packageData)
+	}
+
+	return core.Err[[]byte, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find configuration package: %s", packagePath)))
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type DirectoryDownloaderTestSuite struct {
+	suite.Suite
+	RootPath   string
+	TargetPath string
+	Downloader *DirectoryDownloader
+	Extractor  *AutoExtractor
+}
+
+func TestDirectoryDownloaderTestSuite(t *testing.T) {
+	suite.Run(t, new(DirectoryDownloaderTestSuite))
+}
+
+func (d *DirectoryDownloaderTestSuite) SetupTest() {
+	logger, _ := zap.NewDevelopment()
+	d.RootPath = uuid.New().String()
+	d.TargetPath = uuid.New().String()
+	d.Downloader = NewDirectoryDownloader()
+	d.Extractor = NewAutoExtractor(logger)
+}
+
+func (d *DirectoryDownloaderTestSuite) TearDownTest() {
+	_ = os.RemoveAll(d.RootPath)
+	_ = RemoveExtraction(d.TargetPath)
+}
+
+func (d *DirectoryDownloaderTestSuite) TestDirectoryDownloader_Download_Directory_ReturnsPackedDirectory() {
+	packagePath := filepath.Join(d.RootPath, stage, environment, component)
+	_ = os.MkdirAll(filepath.Join(packagePath, "config"), os.ModePerm)
+	_ = os.WriteFile(filepath.Join(packagePath, "config", "application.yaml"), []byte("Value: 1"), 0644)
+	_ = os.WriteFile(filepath.Join(packagePath, "cp-config"), []byte("#!/bin/sh"), 0755)
+	_ = os.Symlink("config/application.yaml", filepath.Join(packagePath, "application.yaml"))
+
+	result := d.Downloader.Download("file://"+d.RootPath, stage, environment, component)
+
+	assert.True(d.T(), result.IsOk())
+	assert.True(d.T(), d.Extractor.Extract(result.Unwrap(), d.TargetPath).IsOk())
+	content, _ := os.ReadFile(fmt.Sprintf("%s/application.yaml", d.TargetPath))
+	executableInfo, _ := os.Stat(fmt.Sprintf("%s/cp-config", d.TargetPath))
+	linkTarget, _ := os.Readlink(fmt.Sprintf("%s/application.yaml", d.TargetPath))
+	assert.Equal(d.T(), "Value: 1", string(content))
+	assert.Equal(d.T(), os.FileMode(0755), executableInfo.Mode().Perm())
+	assert.Equal(d.T(), "config/application.yaml", linkTarget)
+}
+
+func (d *DirectoryDownloaderTestSuite) TestDirectoryDownloader_Download_SameDirectory_ReturnsSamePackage() {
+	packagePath := filepath.Join(d.RootPath, stage, environment, component)
+	_ = os.MkdirAll(packagePath, os.ModePerm)
+	_ = os.WriteFile(filepath.Join(packagePath, "application.yaml"), []byte("Value: 1"), 0644)
+
+	first := d.Downloader.Download(d.RootPath, stage, environment, component)
+	second := d.Downloader.Download(d.RootPath, stage, environment, component)
+
+	assert.True(d.T(), first.IsOk())
+	assert.Equal(d.T(), first.Unwrap(), second.Unwrap())
+}
+
+func (d *DirectoryDownloaderTestSuite) TestDirectoryDownloader_Download_PackageFile_ReturnsFileContent() {
+	packageData := createZip(map[string]string{"application.yaml": "Value: 1"})
+	_ = os.MkdirAll(filepath.Join(d.RootPath, stage, environment), os.ModePerm)
+	_ = os.WriteFile(filepath.Join(d.RootPath, stage, environment, component+".zip"), packageData, 0644)
+
+	result := d.Downloader.Download(d.RootPath, stage, environment, component)
+
+	assert.True(d.T(), result.IsOk())
+	assert.Equal(d.T(), packageData, result.Unwrap())
+}
+
+func (d *DirectoryDownloaderTestSuite) TestDirectoryDownloader_Download_MissingPackage_NotFound() {
+	result := d.Downloader.Download(d.RootPath, stage, environment, component)
+
+	assert.True(d.T(), result.IsErr())
+	assert.Equal(d.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (d *DirectoryDownloaderTestSuite) TestDirectoryDownloader_Download_EscapingSegment_InvalidInput() {
+	result := d.Downloader.Download(d.RootPath, "..", environment, component)
+
+	assert.True(d.T(), result.IsErr())
+	assert.Equal(d.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
@@ -1,6 +1,10 @@
 package config
 
-import "github.com/simpleg-eu/cuplan_core/pkg/core"
+import (
+	"context"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"time"
+)
 
 // Downloader
 // Interface which provides a facility to download configuration packages.
@@ -19,3 +23,116 @@ type Downloader interface {
 	// Returns: Bytes slice containing the configuration package or an error.
 	Download(host string, stage string, environment string, component string) core.Result[[]byte, core.Error]
 }
+
+// ConditionalDownloader
+// Interface implemented by the Downloaders which remember the last configuration package downloaded through
+// DownloadIfModified and can tell when it has not changed since. Download itself is always unconditional.
+type ConditionalDownloader interface {
+	Downloader
+
+	// DownloadIfModified
+	// Works like Download, but returns a 'not_modified' error when the configuration package has not changed since
+	// its last download through DownloadIfModified.
+	DownloadIfModified(host string, stage string, environment string, component string) core.Result[[]byte, core.Error]
+
+	// ResetConditions
+	// Forgets the last downloaded configuration package, so its next conditional download always returns it.
+	// It takes the same arguments as Download; other packages keep their conditions.
+	ResetConditions(host string, stage string, environment string, component string)
+}
+
+// ConditionalStreamDownloader
+// Interface implemented by the StreamDownloaders which can also spool the configuration package conditionally.
+type ConditionalStreamDownloader interface {
+	ConditionalDownloader
+	StreamDownloader
+
+	// DownloadStreamIfModified
+	// Works like DownloadStream, but returns a 'not_modified' error like DownloadIfModified does.
+	DownloadStreamIfModified(host string, stage string, environment string, component string) core.Result[*SpooledPackage, core.Error]
+}
+
+// StreamDownloader
+// Interface implemented by the Downloaders which can spool the configuration package into a temporary file
+// instead of holding it in memory.
+type StreamDownloader interface {
+	Downloader
+
+	// DownloadStream
+	// Downloads the latest configuration package into a SpooledPackage, which must be closed by the caller.
+	// It takes the same arguments as Download.
+	DownloadStream(host string, stage string, environment string, component string) core.Result[*SpooledPackage, core.Error]
+}
+
+// PackageVersion describes a version of a configuration package.
+type PackageVersion struct {
+	Version   string    `json:"version"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VersionedPackage is a configuration package along with its version.
+type VersionedPackage struct {
+	Version string
+	Data    []byte
+}
+
+// VersionedDownloader
+// Interface implemented by the Downloaders whose source keeps the history of each configuration package.
+type VersionedDownloader interface {
+	Downloader
+
+	// ListVersions
+	// Lists the versions of the configuration package, from the latest to the oldest.
+	// It takes the same arguments as Download.
+	ListVersions(host string, stage string, environment string, component string) core.Result[[]PackageVersion, core.Error]
+
+	// DownloadVersion
+	// Downloads the specified version of the configuration package; an empty version means the latest one.
+	// The other arguments are the same as Download's.
+	DownloadVersion(host string, stage string, environment string, component string, version string) core.Result[VersionedPackage, core.Error]
+}
+
+// ConditionalVersionedDownloader
+// Interface implemented by the VersionedDownloaders which can also download each version conditionally.
+type ConditionalVersionedDownloader interface {
+	ConditionalDownloader
+	VersionedDownloader
+
+	// DownloadVersionIfModified
+	// Works like DownloadVersion, but returns a 'not_modified' error when the version has not changed since its last
+	// download through DownloadVersionIfModified.
+	DownloadVersionIfModified(host string, stage string, environment string, component string, version string) core.Result[VersionedPackage, core.Error]
+}
+
+// PublicationWatcher
+// Interface implemented by the Downloaders whose source pushes the new versions of each configuration package
+// as soon as they are published.
+type PublicationWatcher interface {
+	Downloader
+
+	// WatchPublications
+	// Calls onPublished with the latest version of the configuration package and then with every version published
+	// afterward, until the context is done or the connection is lost, which is reported as an error.
+	// The other arguments are the same as Download's.
+	WatchPublications(ctx context.Context, host string, stage string, environment string, component string, onPublished func(PackageVersion)) core.Result[core.Empty, core.Error]
+}
+
+// SignedDownloader
+// Interface implemented by the Downloaders whose source publishes the digest and the signature of each
+// configuration package, so the Client can verify the packages downloaded through any of the Downloader's methods.
+type SignedDownloader interface {
+	Downloader
+
+	// PackageSignature
+	// Returns the digest and signature sent along with the last configuration package downloaded, whichever its
+	// version, or none if the source did not send any. It takes the same arguments as Download.
+	PackageSignature(host string, stage string, environment string, component string) core.Option[PackageSignature]
+
+	// ReportVerification
+	// Reports whether the last configuration package downloaded passed its verification. A rejected package is a
+	// failure of its source: its conditions are forgotten, so it's downloaded again, and its later downloads only
+	// count as successful once one of them is verified. It takes the same arguments as Download.
+	ReportVerification(host string, stage string, environment string, component string, verified bool)
+}
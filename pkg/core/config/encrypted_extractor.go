@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"go.uber.org/zap"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// EncryptedExtractor decrypts configuration packages encrypted with EncryptEnvelope before delegating their
+// extraction to another Extractor. Unencrypted packages are rejected.
+type EncryptedExtractor struct {
+	logger      *zap.Logger
+	keyProvider KeyProvider
+	extractor   Extractor
+	// atRestKeyId is optional; when set, every extracted file is encrypted again with the key it identifies.
+	atRestKeyId string
+}
+
+func NewEncryptedExtractor(logger *zap.Logger, keyProvider KeyProvider, extractor Extractor) *EncryptedExtractor {
+	e := new(EncryptedExtractor)
+
+	e.logger = logger
+	e.keyProvider = keyProvider
+	e.extractor = extractor
+
+	return e
+}
+
+// SetEncryptionAtRest makes the EncryptedExtractor keep the extracted files encrypted with the key identified by keyId.
+// Those files can be read by a FileProvider whose key provider has been set through SetKeyProvider.
+func (e *EncryptedExtractor) SetEncryptionAtRest(keyId string) {
+	e.atRestKeyId = keyId
+}
+
+// Extract decrypts the package and extracts it into the targetPath. With encryption at rest, the files are
+// encrypted within the staging directory, before it replaces the targetPath, so plaintext never reaches it.
+func (e *EncryptedExtractor) Extract(packageData []byte, targetPath string) core.Result[core.Empty, core.Error] {
+	decryptResult := DecryptEnvelope(e.keyProvider, packageData)
+
+	if decryptResult.IsErr() {
+		return core.Err[core.Empty, core.Error](decryptResult.UnwrapErr())
+	}
+
+	if len(e.atRestKeyId) == 0 {
+		return e.extractor.Extract(decryptResult.Unwrap(), targetPath)
+	}
+
+	return extractAtomically(targetPath, func(stagingPath string) core.Result[core.Empty, core.Error] {
+		extractResult := e.extractor.Extract(decryptResult.Unwrap(), stagingPath)
+
+		if extractResult.IsErr() {
+			return extractResult
+		}
+
+		return e.encryptFiles(stagingPath)
+	})
+}
+
+func (e *EncryptedExtractor) encryptFiles(targetPath string) core.Result[core.Empty, core.Error] {
+	// encryptError keeps the kind of the error which failed to encrypt a file, i.e. a 'not_found' key.
+	encryptError := core.None[core.Error]()
+	err := filepath.WalkDir(targetPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		encryptResult := e.encryptFile(path, entry)
+
+		if encryptResult.IsErr() {
+			encryptError = core.Some(encryptResult.UnwrapErr())
+
+			return filepath.SkipAll
+		}
+
+		return nil
+	})
+
+	if encryptError.IsSome() {
+		return core.Err[core.Empty, core.Error](encryptError.Unwrap())
+	}
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.EncryptionFailure, fmt.Sprintf("failed to encrypt extracted files: %s", err)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// encryptFile replaces the file with its encrypted content. The content is written to a temporary file which is
+// renamed over the original one, so read-only files, i.e. '0444', are encrypted as well and keep their mode.
+func (e *EncryptedExtractor) encryptFile(path string, entry fs.DirEntry) core.Result[core.Empty, core.Error] {
+	info, err := entry.Info()
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.EncryptionFailure, fmt.Sprintf("failed to read extracted file '%s': %s", path, err)))
+	}
+
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.EncryptionFailure, fmt.Sprintf("failed to read extracted file '%s': %s", path, err)))
+	}
+
+	encryptResult := EncryptEnvelope(e.keyProvider, e.atRestKeyId, content)
+
+	if encryptResult.IsErr() {
+		return core.Err[core.Empty, core.Error](encryptResult.UnwrapErr())
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".encrypting-*")
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.EncryptionFailure, fmt.Sprintf("failed to create encrypted file for '%s': %s", path, err)))
+	}
+
+	_, err = file.Write(encryptResult.Unwrap())
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(file.Name(), info.Mode().Perm())
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+
+		return core.Err[core.Empty, core.Error](*core.NewError(core.EncryptionFailure, fmt.Sprintf("failed to write encrypted file for '%s': %s", path, err)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const keyId = "package-key"
+
+type EncryptedExtractorTestSuite struct {
+	suite.Suite
+	KeyDirectory string
+	TargetPath   string
+	KeyProvider  *FileKeyProvider
+	Extractor    *EncryptedExtractor
+}
+
+func TestEncryptedExtractorTestSuite(t *testing.T) {
+	suite.Run(t, new(EncryptedExtractorTestSuite))
+}
+
+func (e *EncryptedExtractorTestSuite) SetupTest() {
+	e.KeyDirectory = uuid.New().String()
+	e.TargetPath = uuid.New().String()
+	_ = os.MkdirAll(e.KeyDirectory, os.ModePerm)
+	key := make([]byte, keyEncryptionKeySize)
+	_, _ = rand.Read(key)
+	_ = os.WriteFile(filepath.Join(e.KeyDirectory, keyId+keyFileExtension), key, 0600)
+	e.KeyProvider = NewFileKeyProvider(e.KeyDirectory)
+	logger, _ := zap.NewDevelopment()
+	e.Extractor = NewEncryptedExtractor(logger, e.KeyProvider, NewZipExtractor(logger))
+}
+
+func (e *EncryptedExtractorTestSuite) TearDownTest() {
+	_ = os.RemoveAll(e.KeyDirectory)
+	_ = RemoveExtraction(e.TargetPath)
+}
+
+func (e *EncryptedExtractorTestSuite) TestEncryptedExtractor_Extract_EncryptedPackage_ExtractsFiles() {
+	envelope := EncryptEnvelope(e.KeyProvider, keyId, createZip(map[string]string{"application.yaml": "Root: \"yes\""})).Unwrap()
+
+	result := e.Extractor.Extract(envelope, e.TargetPath)
+
+	content, _ := os.ReadFile(filepath.Join(e.TargetPath, "application.yaml"))
+	assert.True(e.T(), result.IsOk())
+	assert.Equal(e.T(), "Root: \"yes\"", string(content))
+}
+
+func (e *EncryptedExtractorTestSuite) TestEncryptedExtractor_Extract_UnencryptedPackage_DecryptionFailure() {
+	result := e.Extractor.Extract(createZip(map[string]string{"application.yaml": "Root: \"yes\""}), e.TargetPath)
+
+	assert.True(e.T(), result.IsErr())
+	assert.Equal(e.T(), core.DecryptionFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (e *EncryptedExtractorTestSuite) TestEncryptedExtractor_Extract_TamperedPackage_DecryptionFailure() {
+	envelope := EncryptEnvelope(e.KeyProvider, keyId, createZip(map[string]string{"application.yaml": "Root: \"yes\""})).Unwrap()
+	envelope[len(envelope)-1] ^= 1
+
+	result := e.Extractor.Extract(envelope, e.TargetPath)
+
+	assert.True(e.T(), result.IsErr())
+	assert.Equal(e.T(), core.DecryptionFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (e *EncryptedExtractorTestSuite) TestEncryptedExtractor_Extract_EncryptionAtRest_FileProviderReadsFiles() {
+	envelope := EncryptEnvelope(e.KeyProvider, keyId, createZip(map[string]string{"application.yaml": "Root: \"yes\""})).Unwrap()
+	e.Extractor.SetEncryptionAtRest(keyId)
+	provider := NewFileProvider(e.TargetPath, core.NewCache(time.Hour), time.Hour)
+	provider.SetKeyProvider(e.KeyProvider)
+
+	result := e.Extractor.Extract(envelope, e.TargetPath)
+	value := provider.Get("application.yaml", "Root")
+
+	content, _ := os.ReadFile(filepath.Join(e.TargetPath, "application.yaml"))
+	assert.True(e.T(), result.IsOk())
+	assert.True(e.T(), IsEnvelope(content))
+	assert.False(e.T(), bytes.Contains(content, []byte("Root")))
+	assert.Equal(e.T(), "yes", value.Unwrap())
+}
+
+func (e *EncryptedExtractorTestSuite) TestEncryptedExtractor_Extract_EncryptionAtRestFails_KeepsNoPlaintext() {
+	envelope := EncryptEnvelope(e.KeyProvider, keyId, createZip(map[string]string{"application.yaml": "Root: \"yes\""})).Unwrap()
+	e.Extractor.SetEncryptionAtRest("missing")
+
+	result := e.Extractor.Extract(envelope, e.TargetPath)
+
+	versionDirectories, _ := filepath.Glob(filepath.Join(getVersionsDirectoryPath(e.TargetPath), "*"))
+	assert.True(e.T(), result.IsErr())
+	assert.False(e.T(), doesDirectoryExist(e.TargetPath))
+	assert.Empty(e.T(), versionDirectories)
+}
+
+func (e *EncryptedExtractorTestSuite) TestEncryptedExtractor_Extract_EncryptionAtRestMissingKey_KeepsErrorKind() {
+	envelope := EncryptEnvelope(e.KeyProvider, keyId, createZip(map[string]string{"application.yaml": "Root: \"yes\""})).Unwrap()
+	e.Extractor.SetEncryptionAtRest("missing")
+
+	result := e.Extractor.Extract(envelope, e.TargetPath)
+
+	assert.True(e.T(), result.IsErr())
+	assert.Equal(e.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (e *EncryptedExtractorTestSuite) TestEncryptedExtractor_Extract_EncryptionAtRestReadOnlyFile_EncryptsAndKeepsMode() {
+	packageData := createZipWithHeaders([]zipEntry{{Name: "application.yaml", Content: "Root: \"yes\"", Mode: 0444}})
+	envelope := EncryptEnvelope(e.KeyProvider, keyId, packageData).Unwrap()
+	e.Extractor.SetEncryptionAtRest(keyId)
+
+	result := e.Extractor.Extract(envelope, e.TargetPath)
+
+	filePath := filepath.Join(e.TargetPath, "application.yaml")
+	content, _ := os.ReadFile(filePath)
+	info, _ := os.Stat(filePath)
+	entries, _ := os.ReadDir(e.TargetPath)
+	assert.True(e.T(), result.IsOk())
+	assert.True(e.T(), IsEnvelope(content))
+	assert.Equal(e.T(), os.FileMode(0444), info.Mode().Perm())
+	assert.Len(e.T(), entries, 1)
+}
+
+func (e *EncryptedExtractorTestSuite) TestFileKeyProvider_UnwrapKey_MissingKey_NotFound() {
+	result := e.KeyProvider.UnwrapKey("missing", []byte("wrapped"))
+
+	assert.True(e.T(), result.IsErr())
+	assert.Equal(e.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (e *EncryptedExtractorTestSuite) TestFileKeyProvider_WrapKey_PathTraversingKeyId_InvalidInput() {
+	result := e.KeyProvider.WrapKey("../"+keyId, []byte("data key"))
+
+	assert.True(e.T(), result.IsErr())
+	assert.Equal(e.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func (e *EncryptedExtractorTestSuite) TestEnvelope_RoundTrips() {
+	data := []byte("sensitive")
+
+	result := DecryptEnvelope(e.KeyProvider, EncryptEnvelope(e.KeyProvider, keyId, data).Unwrap())
+
+	assert.True(e.T(), result.IsOk())
+	assert.Equal(e.T(), data, result.Unwrap())
+}
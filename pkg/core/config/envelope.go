@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+)
+
+// envelopeMagic prefixes every encrypted envelope.
+const envelopeMagic = "CUPLENC1"
+const dataKeySize = 32
+
+// envelopeHeader describes how to decrypt an envelope's content.
+type envelopeHeader struct {
+	KeyId      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+}
+
+// EncryptEnvelope encrypts the data with a fresh AES-256-GCM data key, which is wrapped by the key provider's key
+// identified by keyId. The result is laid out as: magic, header's length (4 bytes, big endian), header and ciphertext.
+func EncryptEnvelope(keyProvider KeyProvider, keyId string, data []byte) core.Result[[]byte, core.Error] {
+	dataKey := make([]byte, dataKeySize)
+
+	if _, err := rand.Read(dataKey); err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to generate data key: %s", err)))
+	}
+
+	wrappedKeyResult := keyProvider.WrapKey(keyId, dataKey)
+
+	if wrappedKeyResult.IsErr() {
+		return core.Err[[]byte, core.Error](wrappedKeyResult.UnwrapErr())
+	}
+
+	aeadResult := newAead(dataKey)
+
+	if aeadResult.IsErr() {
+		return core.Err[[]byte, core.Error](aeadResult.UnwrapErr())
+	}
+
+	aead := aeadResult.Unwrap()
+	header := envelopeHeader{KeyId: keyId, WrappedKey: wrappedKeyResult.Unwrap(), Nonce: make([]byte, aead.NonceSize())}
+
+	if _, err := rand.Read(header.Nonce); err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to generate nonce: %s", err)))
+	}
+
+	headerData, err := json.Marshal(header)
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to serialize envelope header: %s", err)))
+	}
+
+	prefix := make([]byte, 0, len(envelopeMagic)+4+len(headerData))
+	prefix = append(prefix, envelopeMagic...)
+	prefix = binary.BigEndian.AppendUint32(prefix, uint32(len(headerData)))
+	prefix = append(prefix, headerData...)
+
+	// The prefix is authenticated, so the header cannot be tampered with.
+	return core.Ok[[]byte, core.Error](aead.Seal(prefix, header.Nonce, data, prefix))
+}
+
+// DecryptEnvelope decrypts an envelope created by EncryptEnvelope.
+func DecryptEnvelope(keyProvider KeyProvider, envelope []byte) core.Result[[]byte, core.Error] {
+	if !IsEnvelope(envelope) {
+		return core.Err[[]byte, core.Error](*core.NewError(core.DecryptionFailure, "data is not an encrypted envelope"))
+	}
+
+	headerStart := len(envelopeMagic) + 4
+
+	if len(envelope) < headerStart {
+		return core.Err[[]byte, core.Error](*core.NewError(core.DecryptionFailure, "envelope is truncated"))
+	}
+
+	headerLength := int(binary.BigEndian.Uint32(envelope[len(envelopeMagic):headerStart]))
+
+	if headerLength > len(envelope)-headerStart {
+		return core.Err[[]byte, core.Error](*core.NewError(core.DecryptionFailure, "envelope is truncated"))
+	}
+
+	prefix := envelope[:headerStart+headerLength]
+	var header envelopeHeader
+
+	if err := json.Unmarshal(envelope[headerStart:headerStart+headerLength], &header); err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.DecryptionFailure, fmt.Sprintf("failed to read envelope header: %s", err)))
+	}
+
+	dataKeyResult := keyProvider.UnwrapKey(header.KeyId, header.WrappedKey)
+
+	if dataKeyResult.IsErr() {
+		return core.Err[[]byte, core.Error](dataKeyResult.UnwrapErr())
+	}
+
+	aeadResult := newAead(dataKeyResult.Unwrap())
+
+	if aeadResult.IsErr() {
+		return core.Err[[]byte, core.Error](aeadResult.UnwrapErr())
+	}
+
+	aead := aeadResult.Unwrap()
+
+	if len(header.Nonce) != aead.NonceSize() {
+		return core.Err[[]byte, core.Error](*core.NewError(core.DecryptionFailure, "envelope has an invalid nonce"))
+	}
+
+	data, err := aead.Open(nil, header.Nonce, envelope[len(prefix):], prefix)
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.DecryptionFailure, "failed to decrypt envelope, it may have been tampered with"))
+	}
+
+	return core.Ok[[]byte, core.Error](data)
+}
+
+// IsEnvelope returns whether the data is an encrypted envelope.
+func IsEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(envelopeMagic))
+}
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultFilePermissions fs.FileMode = 0644
+const maxSymlinkTargetLength = 4096
+
+// maxEntryOverhead is the most an archive is expected to add to the content of each entry: its headers, its name
+// and its padding.
+const maxEntryOverhead = 4096
+
+// ExtractionLimits protects the extraction of configuration packages against decompression bombs.
+// A zero value disables the corresponding limit.
+type ExtractionLimits struct {
+	// MaxFiles is the maximum number of entries within a package.
+	MaxFiles int
+	// MaxFileSize is the maximum uncompressed size, in bytes, of a single file.
+	MaxFileSize int64
+	// MaxTotalSize is the maximum uncompressed size, in bytes, of every file combined.
+	MaxTotalSize int64
+	// MaxCompressionRatio is the maximum ratio between a file's uncompressed and compressed sizes.
+	MaxCompressionRatio float64
+}
+
+// DefaultExtractionLimits returns limits which are generous for configuration packages: 10000 files,
+// 100 MiB per file, 1 GiB in total and a compression ratio of 200.
+func DefaultExtractionLimits() ExtractionLimits {
+	return ExtractionLimits{
+		MaxFiles:            10000,
+		MaxFileSize:         100 << 20,
+		MaxTotalSize:        1 << 30,
+		MaxCompressionRatio: 200,
+	}
+}
+
+// maxPackageSize returns the size of the biggest package which can be extracted within the limits: MaxTotalSize
+// bytes of content plus the overhead of MaxFiles entries and of the archive itself. A negative size means that
+// the limits do not bound the package's size.
+func (l ExtractionLimits) maxPackageSize() int64 {
+	if l.MaxTotalSize <= 0 || l.MaxFiles <= 0 {
+		return -1
+	}
+
+	return l.MaxTotalSize + int64(l.MaxFiles+1)*maxEntryOverhead
+}
+
+var errLimitExceeded = errors.New("limit exceeded")
+
+// extractionGuard enforces the rules shared by every Extractor: entries cannot escape the target path, symbolic
+// links must point within the target path, and the extraction limits must be respected.
+//
+// Symbolic links are only created by createSymlinks, once every other entry has been written, so no entry can be
+// written through a symbolic link of the package.
+type extractionGuard struct {
+	targetPath string
+	limits     ExtractionLimits
+	files      int
+	totalSize  int64
+	symlinks   []symlinkEntry
+}
+
+// symlinkEntry is a symbolic link whose creation is deferred until createSymlinks.
+type symlinkEntry struct {
+	name       string
+	path       string
+	linkTarget string
+}
+
+func newExtractionGuard(targetPath string, limits ExtractionLimits) core.Result[*extractionGuard, core.Error] {
+	absoluteTargetPath, err := filepath.Abs(targetPath)
+
+	if err != nil {
+		return core.Err[*extractionGuard, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to resolve target path: %s", err)))
+	}
+
+	guard := new(extractionGuard)
+	guard.targetPath = absoluteTargetPath
+	guard.limits = limits
+
+	return core.Ok[*extractionGuard, core.Error](guard)
+}
+
+// resolvePath returns the path where the entry must be extracted into, rejecting entries which escape the target path.
+func (g *extractionGuard) resolvePath(name string) core.Result[string, core.Error] {
+	normalizedName := strings.ReplaceAll(name, "\\", "/")
+
+	if len(normalizedName) == 0 || strings.HasPrefix(normalizedName, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return core.Err[string, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("entry '%s' has an absolute path", name)))
+	}
+
+	for _, element := range strings.Split(normalizedName, "/") {
+		if element == ".." {
+			return core.Err[string, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("entry '%s' escapes the target path", name)))
+		}
+	}
+
+	path := filepath.Join(g.targetPath, filepath.FromSlash(normalizedName))
+
+	if !g.isWithinTargetPath(path) {
+		return core.Err[string, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("entry '%s' escapes the target path", name)))
+	}
+
+	return core.Ok[string, core.Error](path)
+}
+
+// addEntry counts a new entry and checks its declared sizes against the limits.
+func (g *extractionGuard) addEntry(name string, compressedSize int64, uncompressedSize int64) core.Result[core.Empty, core.Error] {
+	g.files++
+
+	if g.limits.MaxFiles > 0 && g.files > g.limits.MaxFiles {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.PackageLimitExceeded, fmt.Sprintf("package contains more than %d entries", g.limits.MaxFiles)))
+	}
+
+	if g.limits.MaxFileSize > 0 && uncompressedSize > g.limits.MaxFileSize {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.PackageLimitExceeded, fmt.Sprintf("entry '%s' is bigger than %d bytes", name, g.limits.MaxFileSize)))
+	}
+
+	if g.limits.MaxTotalSize > 0 && g.totalSize+uncompressedSize > g.limits.MaxTotalSize {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.PackageLimitExceeded, fmt.Sprintf("package is bigger than %d bytes", g.limits.MaxTotalSize)))
+	}
+
+	if g.limits.MaxCompressionRatio > 0 && compressedSize > 0 && float64(uncompressedSize)/float64(compressedSize) > g.limits.MaxCompressionRatio {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.PackageLimitExceeded, fmt.Sprintf("entry '%s' exceeds the compression ratio of %v", name, g.limits.MaxCompressionRatio)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// writeFile writes the entry's content into the path, enforcing the size limits on the actual content since the
+// declared sizes may be forged.
+func (g *extractionGuard) writeFile(name string, path string, content io.Reader, mode fs.FileMode) core.Result[core.Empty, core.Error] {
+	parentsResult := g.checkParents(name, path)
+
+	if parentsResult.IsErr() {
+		return parentsResult
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create sub-directory for package data's extraction: %s", err)))
+	}
+
+	permissions := mode.Perm()
+
+	if permissions == 0 {
+		permissions = defaultFilePermissions
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, permissions)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create extracted file: %s", err)))
+	}
+
+	written, err := io.Copy(file, &limitedReader{reader: content, remaining: g.remainingSize()})
+	closeErr := file.Close()
+	g.totalSize += written
+
+	if errors.Is(err, errLimitExceeded) {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.PackageLimitExceeded, fmt.Sprintf("entry '%s' exceeds the size limits", name)))
+	}
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to copy package data's file content: %s", err)))
+	}
+
+	if closeErr != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to close extracted file: %s", closeErr)))
+	}
+
+	// The permissions passed to OpenFile are subject to the umask, so they're applied explicitly.
+	if err := os.Chmod(path, permissions); err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to set extracted file's permissions: %s", err)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// addSymlink validates a symbolic link, whose target must be relative and stay within the target path, and defers
+// its creation until createSymlinks.
+func (g *extractionGuard) addSymlink(name string, path string, linkTarget string) core.Result[core.Empty, core.Error] {
+	if len(linkTarget) == 0 || filepath.IsAbs(linkTarget) || strings.HasPrefix(linkTarget, "/") {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("symbolic link '%s' has an absolute target", name)))
+	}
+
+	resolvedTarget := filepath.Join(filepath.Dir(path), filepath.FromSlash(linkTarget))
+
+	if !g.isWithinTargetPath(resolvedTarget) {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("symbolic link '%s' points outside of the target path", name)))
+	}
+
+	g.symlinks = append(g.symlinks, symlinkEntry{name: name, path: path, linkTarget: linkTarget})
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// createSymlinks creates the symbolic links added to the guard, which must be called once every other entry has
+// been written. A link cannot be created within another link, and every link must resolve within the real target
+// path, since links pointing at each other may escape it even though each target stays within it lexically.
+func (g *extractionGuard) createSymlinks() core.Result[core.Empty, core.Error] {
+	for _, symlink := range g.symlinks {
+		parentsResult := g.checkParents(symlink.name, symlink.path)
+
+		if parentsResult.IsErr() {
+			return parentsResult
+		}
+
+		if _, err := os.Lstat(symlink.path); err == nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("symbolic link '%s' conflicts with another entry", symlink.name)))
+		}
+
+		err := os.MkdirAll(filepath.Dir(symlink.path), os.ModePerm)
+
+		if err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create sub-directory for package data's extraction: %s", err)))
+		}
+
+		if err := os.Symlink(filepath.FromSlash(symlink.linkTarget), symlink.path); err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create symbolic link '%s': %s", symlink.name, err)))
+		}
+	}
+
+	realTargetPath, err := filepath.EvalSymlinks(g.targetPath)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to resolve target path: %s", err)))
+	}
+
+	for _, symlink := range g.symlinks {
+		resolvedPath, err := filepath.EvalSymlinks(symlink.path)
+
+		if err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("symbolic link '%s' cannot be resolved: %s", symlink.name, err)))
+		}
+
+		if !isWithinPath(realTargetPath, resolvedPath) {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("symbolic link '%s' points outside of the target path", symlink.name)))
+		}
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// checkParents rejects the entry when any of its existing parent directories within the target path is a symbolic link.
+func (g *extractionGuard) checkParents(name string, path string) core.Result[core.Empty, core.Error] {
+	relativePath, err := filepath.Rel(g.targetPath, filepath.Dir(path))
+
+	if err != nil || relativePath == "." {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	parentPath := g.targetPath
+
+	for _, element := range strings.Split(relativePath, string(filepath.Separator)) {
+		parentPath = filepath.Join(parentPath, element)
+		info, err := os.Lstat(parentPath)
+
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+
+		if err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to inspect directory of entry '%s': %s", name, err)))
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("entry '%s' is located within a symbolic link", name)))
+		}
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// createDirectory creates a directory entry with its permissions.
+func (g *extractionGuard) createDirectory(path string, mode fs.FileMode) core.Result[core.Empty, core.Error] {
+	permissions := mode.Perm() | 0700
+
+	if err := os.MkdirAll(path, permissions); err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create directory: %s", err)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// remainingSize returns how many bytes the next file can have.
+func (g *extractionGuard) remainingSize() int64 {
+	remaining := int64(-1)
+
+	if g.limits.MaxFileSize > 0 {
+		remaining = g.limits.MaxFileSize
+	}
+
+	if g.limits.MaxTotalSize > 0 && (remaining < 0 || g.limits.MaxTotalSize-g.totalSize < remaining) {
+		remaining = g.limits.MaxTotalSize - g.totalSize
+	}
+
+	return remaining
+}
+
+func (g *extractionGuard) isWithinTargetPath(path string) bool {
+	return isWithinPath(g.targetPath, path)
+}
+
+func isWithinPath(basePath string, path string) bool {
+	relativePath, err := filepath.Rel(basePath, path)
+
+	if err != nil {
+		return false
+	}
+
+	return relativePath != ".." && !strings.HasPrefix(relativePath, ".."+string(filepath.Separator))
+}
+
+// limitedReader fails with errLimitExceeded once more than the remaining bytes are read. A negative remaining
+// disables the limit.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return l.reader.Read(p)
+	}
+
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.reader.Read(p)
+
+	if int64(n) > l.remaining {
+		return int(l.remaining), errLimitExceeded
+	}
+
+	l.remaining -= int64(n)
+
+	return n, err
+}
+
+// readAllWithinLimit reads the whole response's body, failing with a 'package_limit_exceeded' error once it's
+// bigger than the limit. A negative limit disables it.
+func readAllWithinLimit(body io.Reader, limit int64) core.Result[[]byte, core.Error] {
+	if limit >= 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+
+	data, err := io.ReadAll(body)
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to read response's body: %s", err)))
+	}
+
+	if limit >= 0 && int64(len(data)) > limit {
+		return core.Err[[]byte, core.Error](*core.NewError(core.PackageLimitExceeded, fmt.Sprintf("response's body is bigger than %d bytes", limit)))
+	}
+
+	return core.Ok[[]byte, core.Error](data)
+}
@@ -1,6 +1,9 @@
 package config
 
-import "github.com/simpleg-eu/cuplan_core/pkg/core"
+import (
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"io"
+)
 
 // Extractor
 // Interface which provides a facility to extract a configuration package.
@@ -13,3 +16,19 @@ type Extractor interface {
 	// * targetPath - Path where the configuration will be extracted into.
 	Extract(packageData []byte, targetPath string) core.Result[core.Empty, core.Error]
 }
+
+// StreamExtractor
+// Interface implemented by the Extractors which can read the configuration package from a file instead of memory.
+type StreamExtractor interface {
+	Extractor
+
+	// ExtractStream
+	// Extracts the configuration package's content into the targetPath.
+	//
+	// * reader - Reader of the package's raw data, i.e. a SpooledPackage.
+	//
+	// * size - Package's size in bytes.
+	//
+	// * targetPath - Path where the configuration will be extracted into.
+	ExtractStream(reader io.ReaderAt, size int64, targetPath string) core.Result[core.Empty, core.Error]
+}
@@ -5,7 +5,7 @@ import (
 	"github.com/simpleg-eu/cuplan_core/pkg/core"
 	"gopkg.in/yaml.v3"
 	"os"
-	"reflect"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -16,6 +16,10 @@ type FileProvider struct {
 	targetPath           string
 	cache                *core.Cache
 	expireCacheItemAfter time.Duration
+	overlays             []string
+	listMergeStrategy    ListMergeStrategy
+	// keyProvider is optional; when set, files encrypted at rest are decrypted before being read.
+	keyProvider KeyProvider
 }
 
 func NewFileProvider(targetPath string, cache *core.Cache, expireCacheItemAfter time.Duration) *FileProvider {
@@ -28,6 +32,27 @@ func NewFileProvider(targetPath string, cache *core.Cache, expireCacheItemAfter
 	return provider
 }
 
+// NewOverlayFileProvider creates a FileProvider which deep merges the specified overlays on top of every requested file.
+// For a requested file 'application.yaml' and the overlay 'production', the file 'application.production.yaml' is
+// merged on top of it if it exists. Overlays are applied in the specified order, so the last one has the highest priority.
+func NewOverlayFileProvider(targetPath string,
+	cache *core.Cache,
+	expireCacheItemAfter time.Duration,
+	overlays []string,
+	listMergeStrategy ListMergeStrategy) *FileProvider {
+	provider := NewFileProvider(targetPath, cache, expireCacheItemAfter)
+
+	provider.overlays = overlays
+	provider.listMergeStrategy = listMergeStrategy
+
+	return provider
+}
+
+// SetKeyProvider makes the FileProvider decrypt the files which have been encrypted at rest by an EncryptedExtractor.
+func (f *FileProvider) SetKeyProvider(keyProvider KeyProvider) {
+	f.keyProvider = keyProvider
+}
+
 func (f *FileProvider) Get(filePath string, key string) core.Result[any, core.Error] {
 	filePath = fmt.Sprintf("%s/%s", f.targetPath, filePath)
 
@@ -43,19 +68,13 @@ func (f *FileProvider) Get(filePath string, key string) core.Result[any, core.Er
 			return core.Err[any, core.Error](*core.NewError(core.InvalidCache, "failed to read cache as 'map[string]any'"))
 		}
 	} else {
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			return core.Err[any, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find file: %s", filePath)))
-		}
-
-		yamlConfig, err := os.ReadFile(filePath)
+		configResult := f.readConfig(filePath)
 
-		if err != nil {
-			return core.Err[any, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to open file '%s': %s", filePath, err)))
+		if configResult.IsErr() {
+			return core.Err[any, core.Error](configResult.UnwrapErr())
 		}
 
-		if err := yaml.Unmarshal(yamlConfig, &config); err != nil {
-			return core.Err[any, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to read file's content '%s' as YAML: %s", filePath, err)))
-		}
+		config = configResult.Unwrap()
 
 		f.cache.Set(filePath, config, f.expireCacheItemAfter)
 	}
@@ -67,27 +86,113 @@ func (f *FileProvider) CleanCache() {
 	f.cache.Clear()
 }
 
-func getValueFromKeys[T any](key string, object map[string]any) core.Result[T, core.Error] {
-	subKeys := strings.Split(key, keySeparator)
-
-	value := object[subKeys[0]]
-	var ok = true
-
-	subKeys = subKeys[1:]
-	for i, subKey := range subKeys {
-		if len(subKeys)-1 != i {
-			if value, ok = value.(map[string]any)[subKey].(map[string]any); !ok {
-				return core.Err[T, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("failed to read key '%s'", subKey)))
-			}
-		} else {
-			value = value.(map[string]any)[subKey]
+func (f *FileProvider) readConfig(filePath string) core.Result[map[string]any, core.Error] {
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return core.Err[map[string]any, core.Error](*core.NewError(core.FileNotFound, fmt.Sprintf("couldn't find file: %s", filePath)))
+	}
+
+	result := readYamlFile(filePath, f.keyProvider)
+
+	if result.IsErr() {
+		return result
+	}
+
+	return mergeOverlays(filePath, result.Unwrap(), f.overlays, f.listMergeStrategy, f.keyProvider)
+}
+
+// mergeOverlays deep merges the existing overlay files of the filePath on top of its config, in the specified order.
+func mergeOverlays(filePath string,
+	config map[string]any,
+	overlays []string,
+	listMergeStrategy ListMergeStrategy,
+	keyProvider KeyProvider) core.Result[map[string]any, core.Error] {
+	for _, overlay := range overlays {
+		overlayFilePath := getOverlayFilePath(filePath, overlay)
+
+		if _, err := os.Stat(overlayFilePath); os.IsNotExist(err) {
+			continue
+		}
+
+		overlayResult := readYamlFile(overlayFilePath, keyProvider)
+
+		if overlayResult.IsErr() {
+			return overlayResult
 		}
+
+		config = DeepMerge(config, overlayResult.Unwrap(), listMergeStrategy)
+	}
+
+	return core.Ok[map[string]any, core.Error](config)
+}
+
+func readYamlFile(filePath string, keyProvider KeyProvider) core.Result[map[string]any, core.Error] {
+	yamlConfigResult := readConfigFile(filePath, keyProvider)
+
+	if yamlConfigResult.IsErr() {
+		return core.Err[map[string]any, core.Error](yamlConfigResult.UnwrapErr())
+	}
+
+	yamlConfig := yamlConfigResult.Unwrap()
+
+	var config map[string]any
+
+	if err := yaml.Unmarshal(yamlConfig, &config); err != nil {
+		return core.Err[map[string]any, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to read file's content '%s' as YAML: %s", filePath, err)))
+	}
+
+	if config == nil {
+		config = make(map[string]any)
+	}
+
+	return core.Ok[map[string]any, core.Error](config)
+}
+
+// readConfigFile reads a configuration file, decrypting it if it has been encrypted at rest.
+func readConfigFile(filePath string, keyProvider KeyProvider) core.Result[[]byte, core.Error] {
+	content, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to open file '%s': %s", filePath, err)))
+	}
+
+	if keyProvider == nil || !IsEnvelope(content) {
+		return core.Ok[[]byte, core.Error](content)
+	}
+
+	return DecryptEnvelope(keyProvider, content)
+}
+
+// getOverlayFilePath inserts the overlay's name before the file's extension, i.e. 'application.yaml' with the
+// 'production' overlay becomes 'application.production.yaml'.
+func getOverlayFilePath(filePath string, overlay string) string {
+	extension := filepath.Ext(filePath)
+
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(filePath, extension), overlay, extension)
+}
+
+// getValueFromKeys returns the value located at the key path as T. See parseKeyPath for the key path's syntax.
+func getValueFromKeys[T any](key string, object map[string]any) core.Result[T, core.Error] {
+	result := resolveKeyPath(key, object)
+
+	if result.IsErr() {
+		return core.Err[T, core.Error](result.UnwrapErr())
+	}
+
+	value := result.Unwrap()
+
+	// Optional keys which are missing and YAML's null values are returned as T's zero value.
+	if value == nil {
+		var empty T
+
+		return core.Ok[T, core.Error](empty)
 	}
 
 	finalValue, ok := value.(T)
 
 	if !ok {
-		return core.Err[T, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("failed to get key '%s' as T '%v'", subKeys[len(subKeys)-1], reflect.TypeOf(value).String())))
+		var expected T
+
+		return core.Err[T, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("failed to get key '%s' as '%T', found '%T'", key, expected, value)))
 	}
 
 	return core.Ok[T, core.Error](finalValue)
@@ -60,3 +60,61 @@ func (f *FileProviderTestSuite) TestFileProvider_Get_CachedKey_ReturnsExpectedVa
 	f.TestFileProvider_Get_ReturnsExpectedValue()
 	f.TestFileProvider_Get_ReturnsExpectedValue()
 }
+
+func (f *FileProviderTestSuite) TestFileProvider_Get_Overlay_ReturnsOverriddenValue() {
+	provider := f.newOverlayProvider(ReplaceLists)
+
+	result := provider.Get(f.ConfigurationFile, "Example:Inner:Value")
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), 10, result.Unwrap())
+}
+
+func (f *FileProviderTestSuite) TestFileProvider_Get_Overlay_KeepsBaseValues() {
+	provider := f.newOverlayProvider(ReplaceLists)
+
+	rootResult := provider.Get(f.ConfigurationFile, "Root")
+	overlayResult := provider.Get(f.ConfigurationFile, "Overlay")
+
+	assert.Equal(f.T(), "yes", rootResult.Unwrap())
+	assert.Equal(f.T(), "production", overlayResult.Unwrap())
+}
+
+func (f *FileProviderTestSuite) TestFileProvider_Get_OverlayAppendLists_ReturnsMergedList() {
+	provider := f.newOverlayProvider(AppendLists)
+
+	result := provider.Get(f.ConfigurationFile, "Example:Tags")
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), []any{"base", "production"}, result.Unwrap())
+}
+
+func (f *FileProviderTestSuite) TestFileProvider_Get_MissingOverlay_ReturnsBaseValue() {
+	_, testFile, _, _ := runtime.Caller(0)
+	provider := NewOverlayFileProvider(core.GetTestDataPath(testFile).Unwrap(), core.NewCache(time.Hour), time.Hour, []string{"staging"}, ReplaceLists)
+
+	result := provider.Get(f.ConfigurationFile, "Example:Inner:Value")
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), 5, result.Unwrap())
+}
+
+func (f *FileProviderTestSuite) newOverlayProvider(listMergeStrategy ListMergeStrategy) *FileProvider {
+	_, testFile, _, _ := runtime.Caller(0)
+
+	return NewOverlayFileProvider(core.GetTestDataPath(testFile).Unwrap(), core.NewCache(time.Hour), time.Hour, []string{"production"}, listMergeStrategy)
+}
+
+func (f *FileProviderTestSuite) TestFileProvider_Get_MissingIntermediateKey_NotFound() {
+	result := f.Provider.Get(f.ConfigurationFile, "Example:Missing:Value")
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (f *FileProviderTestSuite) TestFileProvider_Get_ListIndex_ReturnsExpectedValue() {
+	result := f.Provider.Get(f.ConfigurationFile, "Example:Tags:0")
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), "base", result.Unwrap())
+}
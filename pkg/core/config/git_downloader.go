@@ -0,0 +1,191 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"go.uber.org/zap"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitDownloader packages configuration from a Git repository through the 'git' executable. The host is the
+// repository's url and each package is the repository's directory '<stage>/<environment>/<component>' at the
+// configured ref, packed as a zip package.
+//
+// The repository is mirrored within the cache directory, so only the new commits are fetched by later downloads.
+// Since the GitDownloader is a ConditionalDownloader, DownloadIfModified returns a 'not_modified' error when the
+// package's directory has not changed since its last conditional download.
+type GitDownloader struct {
+	logger         *zap.Logger
+	ref            string
+	cacheDirectory string
+	commandTimeout time.Duration
+	// trees contains the hash of the last downloaded tree of each package.
+	trees      map[string]string
+	treesMutex sync.Mutex
+	// repositoryMutex serializes the git commands, since they share the mirrored repositories.
+	repositoryMutex sync.Mutex
+}
+
+// NewGitDownloader creates a GitDownloader which packages the specified ref, i.e. a branch, a tag or a commit.
+func NewGitDownloader(logger *zap.Logger, ref string, cacheDirectory string, commandTimeout time.Duration) *GitDownloader {
+	g := new(GitDownloader)
+	g.logger = logger
+	g.ref = ref
+	g.cacheDirectory = cacheDirectory
+	g.commandTimeout = commandTimeout
+	g.trees = make(map[string]string)
+
+	return g
+}
+
+// ResetConditions forgets the downloaded package, so its next conditional download does not return 'not_modified'.
+func (g *GitDownloader) ResetConditions(host string, stage string, environment string, component string) {
+	g.treesMutex.Lock()
+	defer g.treesMutex.Unlock()
+
+	delete(g.trees, host+"#"+g.getTreeish(stage, environment, component))
+}
+
+func (g *GitDownloader) Download(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
+	return g.download(host, stage, environment, component, false)
+}
+
+// DownloadIfModified works like Download, but returns a 'not_modified' error when the package's tree is the one of
+// its last conditional download.
+func (g *GitDownloader) DownloadIfModified(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
+	return g.download(host, stage, environment, component, true)
+}
+
+func (g *GitDownloader) download(host string, stage string, environment string, component string, conditional bool) core.Result[[]byte, core.Error] {
+	g.repositoryMutex.Lock()
+	defer g.repositoryMutex.Unlock()
+
+	repositoryResult := g.updateRepository(host)
+
+	if repositoryResult.IsErr() {
+		return core.Err[[]byte, core.Error](repositoryResult.UnwrapErr())
+	}
+
+	repositoryPath := repositoryResult.Unwrap()
+	treeish := g.getTreeish(stage, environment, component)
+	treeResult := g.runGit(repositoryPath, "rev-parse", "--verify", "--quiet", treeish)
+
+	if treeResult.IsErr() {
+		return core.Err[[]byte, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find configuration package '%s' within '%s'", treeish, host)))
+	}
+
+	tree := strings.TrimSpace(string(treeResult.Unwrap()))
+	typeResult := g.runGit(repositoryPath, "cat-file", "-t", tree)
+
+	// Packages must be directories, so the object must be a tree rather than a file.
+	if typeResult.IsErr() || strings.TrimSpace(string(typeResult.Unwrap())) != "tree" {
+		return core.Err[[]byte, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("configuration package '%s' within '%s' is not a directory", treeish, host)))
+	}
+
+	packageId := host + "#" + treeish
+
+	if conditional && g.getTree(packageId) == tree {
+		return core.Err[[]byte, core.Error](*core.NewError(core.NotModified, "configuration package has not been modified"))
+	}
+
+	archiveResult := g.runGit(repositoryPath, "archive", "--format=zip", tree)
+
+	if archiveResult.IsErr() {
+		return archiveResult
+	}
+
+	if conditional {
+		g.setTree(packageId, tree)
+	}
+
+	return archiveResult
+}
+
+// updateRepository mirrors the repository within the cache directory or fetches its new commits.
+func (g *GitDownloader) updateRepository(repositoryUrl string) core.Result[string, core.Error] {
+	cacheDirectory, err := filepath.Abs(g.cacheDirectory)
+
+	if err != nil {
+		return core.Err[string, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to resolve git cache directory: %s", err)))
+	}
+
+	hash := sha256.Sum256([]byte(repositoryUrl))
+	repositoryPath := filepath.Join(cacheDirectory, hex.EncodeToString(hash[:8])+".git")
+
+	if _doesDirectoryExist(repositoryPath) {
+		fetchResult := g.runGit(repositoryPath, "fetch", "--quiet", "--prune", "--force", "origin")
+
+		if fetchResult.IsOk() {
+			return core.Ok[string, core.Error](repositoryPath)
+		}
+
+		g.logger.Warn("Failed to fetch git repository, cloning it again.", zap.String("err", fetchResult.UnwrapErr().Message))
+
+		if err := os.RemoveAll(repositoryPath); err != nil {
+			return core.Err[string, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to remove git repository '%s': %s", repositoryPath, err)))
+		}
+	}
+
+	if err := os.MkdirAll(cacheDirectory, os.ModePerm); err != nil {
+		return core.Err[string, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to create git cache directory: %s", err)))
+	}
+
+	cloneResult := g.runGit(cacheDirectory, "clone", "--quiet", "--mirror", "--", repositoryUrl, repositoryPath)
+
+	if cloneResult.IsErr() {
+		_ = os.RemoveAll(repositoryPath)
+
+		return core.Err[string, core.Error](cloneResult.UnwrapErr())
+	}
+
+	return core.Ok[string, core.Error](repositoryPath)
+}
+
+func (g *GitDownloader) runGit(directory string, args ...string) core.Result[[]byte, core.Error] {
+	ctx, cancel := context.WithTimeout(context.Background(), g.commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = directory
+	// Prevents git from prompting for credentials, which would block until the timeout.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.CommandFailure, fmt.Sprintf("failed to run 'git %s': %s %s", args[0], err, strings.TrimSpace(stderr.String()))))
+	}
+
+	return core.Ok[[]byte, core.Error](stdout.Bytes())
+}
+
+func (g *GitDownloader) getTreeish(stage string, environment string, component string) string {
+	return fmt.Sprintf("%s:%s", g.ref, path.Join(stage, environment, component))
+}
+
+func (g *GitDownloader) getTree(packageId string) string {
+	g.treesMutex.Lock()
+	defer g.treesMutex.Unlock()
+
+	return g.trees[packageId]
+}
+
+func (g *GitDownloader) setTree(packageId string, tree string) {
+	g.treesMutex.Lock()
+	defer g.treesMutex.Unlock()
+
+	g.trees[packageId] = tree
+}
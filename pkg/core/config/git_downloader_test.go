@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type GitDownloaderTestSuite struct {
+	suite.Suite
+	WorkPath       string
+	RepositoryPath string
+	CachePath      string
+	TargetPath     string
+	Downloader     *GitDownloader
+	Extractor      *AutoExtractor
+}
+
+func TestGitDownloaderTestSuite(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("'git' is not available")
+	}
+
+	suite.Run(t, new(GitDownloaderTestSuite))
+}
+
+func (g *GitDownloaderTestSuite) SetupTest() {
+	logger, _ := zap.NewDevelopment()
+	g.WorkPath, _ = filepath.Abs(uuid.New().String())
+	g.RepositoryPath, _ = filepath.Abs(uuid.New().String() + ".git")
+	g.CachePath = uuid.New().String()
+	g.TargetPath = uuid.New().String()
+	g.Downloader = NewGitDownloader(logger, "main", g.CachePath, 10*time.Second)
+	g.Extractor = NewAutoExtractor(logger)
+
+	g.git("", "init", "--quiet", "--bare", "--initial-branch=main", g.RepositoryPath)
+	g.git("", "init", "--quiet", "--initial-branch=main", g.WorkPath)
+	g.git(g.WorkPath, "remote", "add", "origin", g.RepositoryPath)
+	g.commit(map[string]string{
+		fmt.Sprintf("%s/%s/%s/application.yaml", stage, environment, component): "Value: 1",
+		"other/application.yaml": "Value: 2",
+	})
+}
+
+func (g *GitDownloaderTestSuite) TearDownTest() {
+	_ = os.RemoveAll(g.WorkPath)
+	_ = os.RemoveAll(g.RepositoryPath)
+	_ = os.RemoveAll(g.CachePath)
+	_ = RemoveExtraction(g.TargetPath)
+}
+
+func (g *GitDownloaderTestSuite) TestGitDownloader_Download_ReturnsPackagedDirectory() {
+	result := g.Downloader.Download(g.RepositoryPath, stage, environment, component)
+
+	if result.IsErr() {
+		g.FailNow(result.UnwrapErr().Message)
+	}
+
+	assert.True(g.T(), g.Extractor.Extract(result.Unwrap(), g.TargetPath).IsOk())
+	content, err := os.ReadFile(fmt.Sprintf("%s/application.yaml", g.TargetPath))
+	assert.Nil(g.T(), err)
+	assert.Equal(g.T(), "Value: 1", string(content))
+	assert.False(g.T(), doesDirectoryExist(fmt.Sprintf("%s/other", g.TargetPath)))
+}
+
+func (g *GitDownloaderTestSuite) TestGitDownloader_DownloadIfModified_Unchanged_NotModified() {
+	g.Downloader.DownloadIfModified(g.RepositoryPath, stage, environment, component)
+	g.commit(map[string]string{"other/application.yaml": "Value: 3"})
+
+	result := g.Downloader.DownloadIfModified(g.RepositoryPath, stage, environment, component)
+
+	assert.True(g.T(), result.IsErr())
+	assert.Equal(g.T(), core.NotModified, result.UnwrapErr().ErrorKind)
+}
+
+func (g *GitDownloaderTestSuite) TestGitDownloader_Download_Unchanged_ReturnsPackage() {
+	g.Downloader.DownloadIfModified(g.RepositoryPath, stage, environment, component)
+
+	result := g.Downloader.Download(g.RepositoryPath, stage, environment, component)
+
+	assert.True(g.T(), result.IsOk())
+}
+
+func (g *GitDownloaderTestSuite) TestGitDownloader_Download_NewCommit_ReturnsNewPackage() {
+	g.Downloader.Download(g.RepositoryPath, stage, environment, component)
+	g.commit(map[string]string{fmt.Sprintf("%s/%s/%s/application.yaml", stage, environment, component): "Value: 3"})
+
+	result := g.Downloader.Download(g.RepositoryPath, stage, environment, component)
+
+	assert.True(g.T(), result.IsOk())
+	assert.True(g.T(), g.Extractor.Extract(result.Unwrap(), g.TargetPath).IsOk())
+	content, _ := os.ReadFile(fmt.Sprintf("%s/application.yaml", g.TargetPath))
+	assert.Equal(g.T(), "Value: 3", string(content))
+}
+
+func (g *GitDownloaderTestSuite) TestGitDownloader_Download_ResetConditions_ReturnsPackage() {
+	g.Downloader.Download(g.RepositoryPath, stage, environment, component)
+	g.Downloader.ResetConditions(g.RepositoryPath, stage, environment, component)
+
+	result := g.Downloader.Download(g.RepositoryPath, stage, environment, component)
+
+	assert.True(g.T(), result.IsOk())
+}
+
+func (g *GitDownloaderTestSuite) TestGitDownloader_Download_MissingDirectory_NotFound() {
+	result := g.Downloader.Download(g.RepositoryPath, stage, environment, "missing")
+
+	assert.True(g.T(), result.IsErr())
+	assert.Equal(g.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (g *GitDownloaderTestSuite) TestGitDownloader_Download_MissingRepository_CommandFailure() {
+	result := g.Downloader.Download(g.RepositoryPath+".missing", stage, environment, component)
+
+	assert.True(g.T(), result.IsErr())
+	assert.Equal(g.T(), core.CommandFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (g *GitDownloaderTestSuite) commit(files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(g.WorkPath, name)
+		_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		_ = os.WriteFile(path, []byte(content), 0644)
+	}
+
+	g.git(g.WorkPath, "add", "--all")
+	g.git(g.WorkPath, "-c", "user.name=test", "-c", "user.email=test@simpleg.eu", "commit", "--quiet", "--message", "Update configuration.")
+	g.git(g.WorkPath, "push", "--quiet", "origin", "main")
+}
+
+func (g *GitDownloaderTestSuite) git(directory string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = directory
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		g.FailNow(fmt.Sprintf("failed to run 'git %s': %s %s", args[0], err, output))
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/secret"
+	"os"
+	"regexp"
+	"time"
+)
+
+const secretReferenceScheme = "secret"
+const environmentReferenceScheme = "env"
+
+var referencePattern = regexp.MustCompile(`\$\{(secret|env):([^}]+)}`)
+
+// Interpolator resolves references contained within configuration values.
+// A reference has the form '${secret:<id>}', which is resolved through the secret provider,
+// or '${env:NAME}', which is resolved from the environment variables.
+// Resolved secrets are cached for the specified duration and their values are never logged nor
+// included within errors.
+type Interpolator struct {
+	secretProvider       secret.Provider
+	cache                *core.Cache
+	expireCacheItemAfter time.Duration
+}
+
+// NewInterpolator creates an instance of Interpolator which resolves secrets through the specified secret provider.
+func NewInterpolator(secretProvider secret.Provider, cache *core.Cache, expireCacheItemAfter time.Duration) *Interpolator {
+	interpolator := new(Interpolator)
+
+	interpolator.secretProvider = secretProvider
+	interpolator.cache = cache
+	interpolator.expireCacheItemAfter = expireCacheItemAfter
+
+	return interpolator
+}
+
+// Interpolate resolves every reference contained within the value. Maps and lists are traversed recursively
+// and a copy is returned, so the value itself is never modified.
+func (i *Interpolator) Interpolate(value any) core.Result[any, core.Error] {
+	switch typedValue := value.(type) {
+	case string:
+		result := i.interpolateString(typedValue)
+
+		if result.IsErr() {
+			return core.Err[any, core.Error](result.UnwrapErr())
+		}
+
+		return core.Ok[any, core.Error](result.Unwrap())
+	case map[string]any:
+		interpolated := make(map[string]any, len(typedValue))
+
+		for key, item := range typedValue {
+			result := i.Interpolate(item)
+
+			if result.IsErr() {
+				return result
+			}
+
+			interpolated[key] = result.Unwrap()
+		}
+
+		return core.Ok[any, core.Error](interpolated)
+	case []any:
+		interpolated := make([]any, len(typedValue))
+
+		for index, item := range typedValue {
+			result := i.Interpolate(item)
+
+			if result.IsErr() {
+				return result
+			}
+
+			interpolated[index] = result.Unwrap()
+		}
+
+		return core.Ok[any, core.Error](interpolated)
+	default:
+		return core.Ok[any, core.Error](value)
+	}
+}
+
+// CleanCache removes every resolved secret from the cache.
+func (i *Interpolator) CleanCache() {
+	i.cache.Clear()
+}
+
+func (i *Interpolator) interpolateString(value string) core.Result[string, core.Error] {
+	var resolveError *core.Error
+
+	interpolated := referencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		if resolveError != nil {
+			return reference
+		}
+
+		groups := referencePattern.FindStringSubmatch(reference)
+		result := i.resolve(groups[1], groups[2])
+
+		if result.IsErr() {
+			err := result.UnwrapErr()
+			resolveError = &err
+
+			return reference
+		}
+
+		return result.Unwrap()
+	})
+
+	if resolveError != nil {
+		return core.Err[string, core.Error](*resolveError)
+	}
+
+	return core.Ok[string, core.Error](interpolated)
+}
+
+func (i *Interpolator) resolve(scheme string, name string) core.Result[string, core.Error] {
+	if scheme == environmentReferenceScheme {
+		value, exists := os.LookupEnv(name)
+
+		if !exists {
+			return core.Err[string, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("environment variable '%s' is not set", name)))
+		}
+
+		return core.Ok[string, core.Error](value)
+	}
+
+	cacheKey := secretReferenceScheme + ":" + name
+	cached := i.cache.Get(cacheKey)
+
+	if cached.IsSome() {
+		if value, ok := cached.Unwrap().(string); ok {
+			return core.Ok[string, core.Error](value)
+		}
+	}
+
+	if i.secretProvider == nil {
+		return core.Err[string, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("cannot resolve secret '%s' without a secret provider", name)))
+	}
+
+	result := i.secretProvider.Get(name)
+
+	if result.IsErr() {
+		err := result.UnwrapErr()
+
+		return core.Err[string, core.Error](*core.NewError(err.ErrorKind, fmt.Sprintf("failed to resolve secret '%s': %s", name, err.Message)))
+	}
+
+	i.cache.Set(cacheKey, result.Unwrap(), i.expireCacheItemAfter)
+
+	return result
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+const secretId = "7c1d5dfd-a58b-47cf-bee5-b0a600fe50c9"
+const secretValue = "le_secret :)"
+
+type MockSecretProvider struct {
+	mock.Mock
+}
+
+type InterpolatorTestSuite struct {
+	suite.Suite
+	SecretProvider *MockSecretProvider
+	Interpolator   *Interpolator
+}
+
+func TestInterpolatorTestSuite(t *testing.T) {
+	suite.Run(t, new(InterpolatorTestSuite))
+}
+
+func (m *MockSecretProvider) Get(secretId string) core.Result[string, core.Error] {
+	args := m.Called(secretId)
+	return args.Get(0).(core.Result[string, core.Error])
+}
+
+func (i *InterpolatorTestSuite) SetupTest() {
+	i.SecretProvider = new(MockSecretProvider)
+	i.SecretProvider.On("Get", secretId).Return(core.Ok[string, core.Error](secretValue))
+	i.SecretProvider.On("Get", "missing").Return(core.Err[string, core.Error](*core.NewError(core.CommandFailure, "failed to get secret")))
+	i.Interpolator = NewInterpolator(i.SecretProvider, core.NewCache(time.Hour), time.Hour)
+}
+
+func (i *InterpolatorTestSuite) TestInterpolator_Interpolate_SecretReference_ReturnsSecret() {
+	result := i.Interpolator.Interpolate("${secret:" + secretId + "}")
+
+	assert.True(i.T(), result.IsOk())
+	assert.Equal(i.T(), secretValue, result.Unwrap())
+}
+
+func (i *InterpolatorTestSuite) TestInterpolator_Interpolate_EmbeddedReferences_ReturnsInterpolatedString() {
+	i.T().Setenv("INTERPOLATOR_TEST_USER", "admin")
+
+	result := i.Interpolator.Interpolate("${env:INTERPOLATOR_TEST_USER}:${secret:" + secretId + "}@localhost")
+
+	assert.True(i.T(), result.IsOk())
+	assert.Equal(i.T(), "admin:"+secretValue+"@localhost", result.Unwrap())
+}
+
+func (i *InterpolatorTestSuite) TestInterpolator_Interpolate_NestedValues_ReturnsInterpolatedCopy() {
+	value := map[string]any{"List": []any{"${secret:" + secretId + "}", 5}}
+
+	result := i.Interpolator.Interpolate(value)
+
+	assert.True(i.T(), result.IsOk())
+	assert.Equal(i.T(), map[string]any{"List": []any{secretValue, 5}}, result.Unwrap())
+	assert.Equal(i.T(), "${secret:"+secretId+"}", value["List"].([]any)[0])
+}
+
+func (i *InterpolatorTestSuite) TestInterpolator_Interpolate_CachesResolvedSecrets() {
+	_ = i.Interpolator.Interpolate("${secret:" + secretId + "}")
+	_ = i.Interpolator.Interpolate("${secret:" + secretId + "}")
+
+	i.SecretProvider.AssertNumberOfCalls(i.T(), "Get", 1)
+}
+
+func (i *InterpolatorTestSuite) TestInterpolator_Interpolate_MissingEnvironmentVariable_NotFound() {
+	result := i.Interpolator.Interpolate("${env:INTERPOLATOR_TEST_MISSING_VARIABLE}")
+
+	assert.True(i.T(), result.IsErr())
+	assert.Equal(i.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (i *InterpolatorTestSuite) TestInterpolator_Interpolate_FailingSecretProvider_Error() {
+	result := i.Interpolator.Interpolate("${secret:missing}")
+
+	assert.True(i.T(), result.IsErr())
+	assert.Equal(i.T(), core.CommandFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (i *InterpolatorTestSuite) TestInterpolator_Interpolate_NonReferenceValue_ReturnsSameValue() {
+	result := i.Interpolator.Interpolate("${unknown:value}")
+
+	assert.True(i.T(), result.IsOk())
+	assert.Equal(i.T(), "${unknown:value}", result.Unwrap())
+}
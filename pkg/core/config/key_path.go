@@ -0,0 +1,285 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const escapeCharacter = '\\'
+const wildcardSegment = "*"
+const optionalSuffix = '?'
+
+// keySegment is a single level of a key path.
+type keySegment struct {
+	name     string
+	wildcard bool
+	optional bool
+}
+
+// parseKeyPath parses a key path whose levels are separated by ':'.
+//
+// * 'Servers:0:Host' - numeric levels index lists.
+//
+// * 'Servers:*:Host' - '*' matches every item of a list or every value of a map.
+//
+// * 'Logging:Level?' - a trailing '?' makes the level optional, so nothing is returned instead of an error if it's missing.
+//
+// * 'Urls:http\://example' - '\' escapes the next character, so ':', '*', '?' and '\' can be part of a level's name.
+func parseKeyPath(key string) core.Result[[]keySegment, core.Error] {
+	if len(key) == 0 {
+		return core.Err[[]keySegment, core.Error](*core.NewError(core.InvalidInput, "key path cannot be empty"))
+	}
+
+	segments := make([]keySegment, 0, strings.Count(key, keySeparator)+1)
+	builder := strings.Builder{}
+	escaped := false
+	runes := []rune(key)
+
+	appendSegment := func() core.Result[core.Empty, core.Error] {
+		name := builder.String()
+		builder.Reset()
+
+		if len(name) == 0 {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("key path '%s' contains an empty level", key)))
+		}
+
+		segments = append(segments, keySegment{name: name})
+
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	for i := 0; i < len(runes); i++ {
+		character := runes[i]
+
+		if escaped {
+			builder.WriteRune(character)
+			escaped = false
+			continue
+		}
+
+		switch {
+		case character == escapeCharacter:
+			escaped = true
+		case string(character) == keySeparator:
+			if result := appendSegment(); result.IsErr() {
+				return core.Err[[]keySegment, core.Error](result.UnwrapErr())
+			}
+		case character == optionalSuffix && (i == len(runes)-1 || string(runes[i+1]) == keySeparator):
+			if builder.Len() == 0 {
+				return core.Err[[]keySegment, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("key path '%s' contains an optional marker without a level", key)))
+			}
+
+			if result := appendSegment(); result.IsErr() {
+				return core.Err[[]keySegment, core.Error](result.UnwrapErr())
+			}
+
+			segments[len(segments)-1].optional = true
+
+			// Skip the separator which follows the optional marker.
+			i++
+		case string(character) == wildcardSegment && builder.Len() == 0 && (i == len(runes)-1 || string(runes[i+1]) == keySeparator):
+			segments = append(segments, keySegment{name: wildcardSegment, wildcard: true})
+
+			// Skip the separator which follows the wildcard.
+			i++
+		default:
+			builder.WriteRune(character)
+		}
+	}
+
+	if escaped {
+		return core.Err[[]keySegment, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("key path '%s' ends with an unfinished escape sequence", key)))
+	}
+
+	lastCharacter := string(runes[len(runes)-1])
+	endsWithMarker := (lastCharacter == string(optionalSuffix) || lastCharacter == wildcardSegment) && builder.Len() == 0
+
+	if !endsWithMarker {
+		if result := appendSegment(); result.IsErr() {
+			return core.Err[[]keySegment, core.Error](result.UnwrapErr())
+		}
+	}
+
+	return core.Ok[[]keySegment, core.Error](segments)
+}
+
+// escapeKeySegment escapes a level's name so it can be safely joined into a key path.
+func escapeKeySegment(name string) string {
+	builder := strings.Builder{}
+
+	for _, character := range name {
+		if character == escapeCharacter || string(character) == keySeparator || character == optionalSuffix || string(character) == wildcardSegment {
+			builder.WriteRune(escapeCharacter)
+		}
+
+		builder.WriteRune(character)
+	}
+
+	return builder.String()
+}
+
+// hasWildcard returns whether any of the segments may match more than one value.
+func hasWildcard(segments []keySegment) bool {
+	for _, segment := range segments {
+		if segment.wildcard {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveKeyPath returns the value located at the key path. If the key path contains a wildcard,
+// every match is returned within a '[]any'.
+func resolveKeyPath(key string, object any) core.Result[any, core.Error] {
+	segmentsResult := parseKeyPath(key)
+
+	if segmentsResult.IsErr() {
+		return core.Err[any, core.Error](segmentsResult.UnwrapErr())
+	}
+
+	segments := segmentsResult.Unwrap()
+
+	if hasWildcard(segments) {
+		matches := make([]any, 0)
+		result := collectMatches(key, segments, object, &matches)
+
+		if result.IsErr() {
+			return core.Err[any, core.Error](result.UnwrapErr())
+		}
+
+		return core.Ok[any, core.Error](matches)
+	}
+
+	value := object
+
+	for i, segment := range segments {
+		childResult := getChild(key, segment, value)
+
+		if childResult.IsErr() {
+			return core.Err[any, core.Error](childResult.UnwrapErr())
+		}
+
+		child := childResult.Unwrap()
+
+		if child.IsNone() {
+			if segment.optional {
+				return core.Ok[any, core.Error](nil)
+			}
+
+			return core.Err[any, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("key path '%s' has no level '%s'", key, joinSegments(segments[:i+1]))))
+		}
+
+		value = child.Unwrap()
+	}
+
+	return core.Ok[any, core.Error](value)
+}
+
+func collectMatches(key string, segments []keySegment, value any, matches *[]any) core.Result[core.Empty, core.Error] {
+	if len(segments) == 0 {
+		*matches = append(*matches, value)
+
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	segment := segments[0]
+
+	if segment.wildcard {
+		for _, child := range getChildren(value) {
+			result := collectMatches(key, segments[1:], child, matches)
+
+			if result.IsErr() {
+				return result
+			}
+		}
+
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	childResult := getChild(key, segment, value)
+
+	if childResult.IsErr() {
+		return core.Err[core.Empty, core.Error](childResult.UnwrapErr())
+	}
+
+	child := childResult.Unwrap()
+
+	// Missing levels are not an error within wildcard queries, they simply do not match.
+	if child.IsNone() {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	return collectMatches(key, segments[1:], child.Unwrap(), matches)
+}
+
+func getChild(key string, segment keySegment, value any) core.Result[core.Option[any], core.Error] {
+	switch typedValue := value.(type) {
+	case map[string]any:
+		child, exists := typedValue[segment.name]
+
+		if !exists {
+			return core.Ok[core.Option[any], core.Error](core.None[any]())
+		}
+
+		return core.Ok[core.Option[any], core.Error](core.Some(child))
+	case []any:
+		index, err := strconv.Atoi(segment.name)
+
+		if err != nil || index < 0 {
+			return core.Err[core.Option[any], core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("key path '%s' uses '%s' as a list index", key, segment.name)))
+		}
+
+		if index >= len(typedValue) {
+			return core.Ok[core.Option[any], core.Error](core.None[any]())
+		}
+
+		return core.Ok[core.Option[any], core.Error](core.Some(typedValue[index]))
+	case nil:
+		return core.Ok[core.Option[any], core.Error](core.None[any]())
+	default:
+		return core.Err[core.Option[any], core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("key path '%s' cannot read level '%s' from a value of type '%T'", key, segment.name, value)))
+	}
+}
+
+func getChildren(value any) []any {
+	switch typedValue := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(typedValue))
+
+		for key := range typedValue {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		children := make([]any, 0, len(keys))
+
+		for _, key := range keys {
+			children = append(children, typedValue[key])
+		}
+
+		return children
+	case []any:
+		return typedValue
+	default:
+		return nil
+	}
+}
+
+func joinSegments(segments []keySegment) string {
+	names := make([]string, 0, len(segments))
+
+	for _, segment := range segments {
+		if segment.wildcard {
+			names = append(names, wildcardSegment)
+			continue
+		}
+
+		names = append(names, escapeKeySegment(segment.name))
+	}
+
+	return strings.Join(names, keySeparator)
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var keyPathObject = map[string]any{
+	"Servers": []any{
+		map[string]any{"Host": "a", "Port": 1},
+		map[string]any{"Host": "b"},
+	},
+	"Urls": map[string]any{
+		"http://example": "plain",
+		"*":              "star",
+	},
+	"Parent": map[string]any{"Child": "value"},
+	"Scalar": 5,
+}
+
+func TestResolveKeyPath_ListIndex_ReturnsItem(t *testing.T) {
+	result := resolveKeyPath("Servers:1:Host", keyPathObject)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, "b", result.Unwrap())
+}
+
+func TestResolveKeyPath_EscapedSeparator_ReturnsValue(t *testing.T) {
+	result := resolveKeyPath(`Urls:http\://example`, keyPathObject)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, "plain", result.Unwrap())
+}
+
+func TestResolveKeyPath_EscapedWildcard_ReturnsLiteralKey(t *testing.T) {
+	result := resolveKeyPath(`Urls:\*`, keyPathObject)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, "star", result.Unwrap())
+}
+
+func TestResolveKeyPath_Wildcard_ReturnsEveryMatch(t *testing.T) {
+	result := resolveKeyPath("Servers:*:Host", keyPathObject)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []any{"a", "b"}, result.Unwrap())
+}
+
+func TestResolveKeyPath_WildcardPartialMatches_SkipsMissingLevels(t *testing.T) {
+	result := resolveKeyPath("Servers:*:Port", keyPathObject)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []any{1}, result.Unwrap())
+}
+
+func TestResolveKeyPath_MissingOptionalKey_ReturnsNil(t *testing.T) {
+	result := resolveKeyPath("Parent:Missing?:Deeper", keyPathObject)
+
+	assert.True(t, result.IsOk())
+	assert.Nil(t, result.Unwrap())
+}
+
+func TestResolveKeyPath_ExistingOptionalKey_ReturnsValue(t *testing.T) {
+	result := resolveKeyPath("Parent:Child?", keyPathObject)
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, "value", result.Unwrap())
+}
+
+func TestResolveKeyPath_MissingIntermediateKey_NotFound(t *testing.T) {
+	result := resolveKeyPath("Missing:Child", keyPathObject)
+
+	assert.True(t, result.IsErr())
+	assert.Equal(t, core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func TestResolveKeyPath_IndexOutOfRange_NotFound(t *testing.T) {
+	result := resolveKeyPath("Servers:5:Host", keyPathObject)
+
+	assert.True(t, result.IsErr())
+	assert.Equal(t, core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func TestResolveKeyPath_NonNumericListIndex_InvalidInput(t *testing.T) {
+	result := resolveKeyPath("Servers:Host", keyPathObject)
+
+	assert.True(t, result.IsErr())
+	assert.Equal(t, core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func TestResolveKeyPath_LevelWithinScalar_InvalidInput(t *testing.T) {
+	result := resolveKeyPath("Scalar:Child", keyPathObject)
+
+	assert.True(t, result.IsErr())
+	assert.Equal(t, core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func TestResolveKeyPath_MalformedKeyPaths_InvalidInput(t *testing.T) {
+	for _, key := range []string{"", "Parent::Child", "Parent:", `Parent\`, "Parent:?"} {
+		result := resolveKeyPath(key, keyPathObject)
+
+		assert.True(t, result.IsErr(), key)
+		assert.Equal(t, core.InvalidInput, result.UnwrapErr().ErrorKind, key)
+	}
+}
+
+func TestEscapeKeySegment_RoundTrips(t *testing.T) {
+	const name = `a:b*c?d\e`
+
+	result := parseKeyPath(escapeKeySegment(name))
+
+	assert.True(t, result.IsOk())
+	assert.Equal(t, []keySegment{{name: name}}, result.Unwrap())
+}
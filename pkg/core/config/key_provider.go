@@ -0,0 +1,125 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const keyEncryptionKeySize = 32
+const keyFileExtension = ".key"
+
+// KeyProvider
+// Interface which provides a facility to wrap and unwrap data keys with key encryption keys, like a KMS does.
+type KeyProvider interface {
+	// WrapKey
+	// Encrypts the data key with the key encryption key identified by keyId.
+	WrapKey(keyId string, dataKey []byte) core.Result[[]byte, core.Error]
+
+	// UnwrapKey
+	// Decrypts the wrapped data key with the key encryption key identified by keyId.
+	UnwrapKey(keyId string, wrappedKey []byte) core.Result[[]byte, core.Error]
+}
+
+// FileKeyProvider is a KeyProvider whose key encryption keys are stored within a directory, one file per key named
+// '<keyId>.key', containing either 32 raw bytes or their hex encoding. Keys are wrapped with AES-256-GCM.
+type FileKeyProvider struct {
+	directory string
+}
+
+func NewFileKeyProvider(directory string) *FileKeyProvider {
+	provider := new(FileKeyProvider)
+	provider.directory = directory
+
+	return provider
+}
+
+func (f *FileKeyProvider) WrapKey(keyId string, dataKey []byte) core.Result[[]byte, core.Error] {
+	aeadResult := f.getAead(keyId)
+
+	if aeadResult.IsErr() {
+		return core.Err[[]byte, core.Error](aeadResult.UnwrapErr())
+	}
+
+	aead := aeadResult.Unwrap()
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to generate nonce: %s", err)))
+	}
+
+	return core.Ok[[]byte, core.Error](aead.Seal(nonce, nonce, dataKey, []byte(keyId)))
+}
+
+func (f *FileKeyProvider) UnwrapKey(keyId string, wrappedKey []byte) core.Result[[]byte, core.Error] {
+	aeadResult := f.getAead(keyId)
+
+	if aeadResult.IsErr() {
+		return core.Err[[]byte, core.Error](aeadResult.UnwrapErr())
+	}
+
+	aead := aeadResult.Unwrap()
+
+	if len(wrappedKey) < aead.NonceSize() {
+		return core.Err[[]byte, core.Error](*core.NewError(core.DecryptionFailure, "wrapped key is too short"))
+	}
+
+	dataKey, err := aead.Open(nil, wrappedKey[:aead.NonceSize()], wrappedKey[aead.NonceSize():], []byte(keyId))
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.DecryptionFailure, fmt.Sprintf("failed to unwrap key with key '%s'", keyId)))
+	}
+
+	return core.Ok[[]byte, core.Error](dataKey)
+}
+
+func (f *FileKeyProvider) getAead(keyId string) core.Result[cipher.AEAD, core.Error] {
+	if len(keyId) == 0 || strings.ContainsAny(keyId, `/\`) || keyId == "." || keyId == ".." {
+		return core.Err[cipher.AEAD, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("invalid key id '%s'", keyId)))
+	}
+
+	keyFilePath := filepath.Join(f.directory, keyId+keyFileExtension)
+	content, err := os.ReadFile(keyFilePath)
+
+	if os.IsNotExist(err) {
+		return core.Err[cipher.AEAD, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find key '%s'", keyId)))
+	}
+
+	if err != nil {
+		return core.Err[cipher.AEAD, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read key '%s': %s", keyId, err)))
+	}
+
+	key := content
+
+	if len(content) != keyEncryptionKeySize {
+		key, err = hex.DecodeString(strings.TrimSpace(string(content)))
+
+		if err != nil || len(key) != keyEncryptionKeySize {
+			return core.Err[cipher.AEAD, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("key '%s' must contain %d raw or hex encoded bytes", keyId, keyEncryptionKeySize)))
+		}
+	}
+
+	return newAead(key)
+}
+
+func newAead(key []byte) core.Result[cipher.AEAD, core.Error] {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return core.Err[cipher.AEAD, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("failed to create cipher: %s", err)))
+	}
+
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return core.Err[cipher.AEAD, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("failed to create GCM: %s", err)))
+	}
+
+	return core.Ok[cipher.AEAD, core.Error](aead)
+}
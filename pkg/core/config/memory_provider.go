@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// MemoryProvider is a Provider whose files are held in memory, so configurations can be supplied programmatically,
+// i.e. within tests, or from assets embedded into the binary through an embed.FS.
+type MemoryProvider struct {
+	files      map[string]map[string]any
+	filesMutex sync.RWMutex
+}
+
+// NewMemoryProvider creates a MemoryProvider from the files' documents, keyed by file path, i.e.
+// 'map[string]any{"application.yaml": map[string]any{"Server": map[string]any{"Port": 8080}}}'. The documents
+// are copied, and their maps and slices normalized to the types read from YAML files, such as '[]any'.
+func NewMemoryProvider(files map[string]any) core.Result[*MemoryProvider, core.Error] {
+	provider := new(MemoryProvider)
+	provider.files = make(map[string]map[string]any)
+
+	for filePath, document := range files {
+		result := provider.Set(filePath, document)
+
+		if result.IsErr() {
+			return core.Err[*MemoryProvider, core.Error](result.UnwrapErr())
+		}
+	}
+
+	return core.Ok[*MemoryProvider, core.Error](provider)
+}
+
+// NewMemoryProviderFromFS creates a MemoryProvider from every YAML file within the file system, keyed by its path
+// relative to the file system's root. Use fs.Sub to read the files of an embed.FS's subdirectory.
+func NewMemoryProviderFromFS(fileSystem fs.FS) core.Result[*MemoryProvider, core.Error] {
+	provider := new(MemoryProvider)
+	provider.files = make(map[string]map[string]any)
+
+	err := fs.WalkDir(fileSystem, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		extension := strings.ToLower(path.Ext(filePath))
+
+		if entry.IsDir() || (extension != ".yaml" && extension != ".yml") {
+			return nil
+		}
+
+		content, err := fs.ReadFile(fileSystem, filePath)
+
+		if err != nil {
+			return err
+		}
+
+		var document map[string]any
+
+		if err := yaml.Unmarshal(content, &document); err != nil {
+			return fmt.Errorf("failed to read file's content '%s' as YAML: %w", filePath, err)
+		}
+
+		if document == nil {
+			document = make(map[string]any)
+		}
+
+		provider.files[filePath] = document
+
+		return nil
+	})
+
+	if err != nil {
+		return core.Err[*MemoryProvider, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to read file system: %s", err)))
+	}
+
+	return core.Ok[*MemoryProvider, core.Error](provider)
+}
+
+// Set replaces the document of the file, which must be a mapping. See NewMemoryProvider.
+func (m *MemoryProvider) Set(filePath string, document any) core.Result[core.Empty, core.Error] {
+	data, err := yaml.Marshal(document)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to serialize file '%s': %s", filePath, err)))
+	}
+
+	var normalizedDocument map[string]any
+
+	if err := yaml.Unmarshal(data, &normalizedDocument); err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("file '%s' must be a mapping: %s", filePath, err)))
+	}
+
+	if normalizedDocument == nil {
+		normalizedDocument = make(map[string]any)
+	}
+
+	m.filesMutex.Lock()
+	defer m.filesMutex.Unlock()
+
+	m.files[filePath] = normalizedDocument
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+func (m *MemoryProvider) Get(filePath string, key string) core.Result[any, core.Error] {
+	m.filesMutex.RLock()
+	defer m.filesMutex.RUnlock()
+
+	document, exists := m.files[filePath]
+
+	if !exists {
+		return core.Err[any, core.Error](*core.NewError(core.FileNotFound, fmt.Sprintf("couldn't find file: %s", filePath)))
+	}
+
+	return getValueFromKeys[any](key, document)
+}
+
+// CleanCache does nothing, since the MemoryProvider has no cache.
+func (m *MemoryProvider) CleanCache() {
+}
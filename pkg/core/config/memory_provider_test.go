@@ -0,0 +1,91 @@
+package config
+
+import (
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"testing/fstest"
+)
+
+type MemoryProviderTestSuite struct {
+	suite.Suite
+	Provider *MemoryProvider
+}
+
+func TestMemoryProviderTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryProviderTestSuite))
+}
+
+func (m *MemoryProviderTestSuite) SetupTest() {
+	m.Provider = NewMemoryProvider(map[string]any{
+		"application.yaml": map[string]any{
+			"Server": map[string]any{"Host": "localhost", "Port": 8080},
+			"Hosts":  []string{"a", "b"},
+			"Limits": map[string]int{"Connections": 10},
+		},
+	}).Unwrap()
+}
+
+func (m *MemoryProviderTestSuite) TestMemoryProvider_Get_ReturnsExpectedValue() {
+	result := m.Provider.Get("application.yaml", "Server:Port")
+
+	assert.True(m.T(), result.IsOk())
+	assert.Equal(m.T(), 8080, result.Unwrap())
+}
+
+func (m *MemoryProviderTestSuite) TestMemoryProvider_Get_NormalizesTypedValues() {
+	host := m.Provider.Get("application.yaml", "Hosts:1")
+	connections := m.Provider.Get("application.yaml", "Limits:Connections")
+
+	assert.Equal(m.T(), "b", host.Unwrap())
+	assert.Equal(m.T(), 10, connections.Unwrap())
+}
+
+func (m *MemoryProviderTestSuite) TestMemoryProvider_Get_MissingFile_FileNotFound() {
+	result := m.Provider.Get("missing.yaml", "Server:Port")
+
+	assert.True(m.T(), result.IsErr())
+	assert.Equal(m.T(), core.FileNotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (m *MemoryProviderTestSuite) TestMemoryProvider_Set_ReplacesFile() {
+	setResult := m.Provider.Set("application.yaml", map[string]any{"Server": map[string]any{"Port": 9090}})
+	result := m.Provider.Get("application.yaml", "Server:Port")
+
+	assert.True(m.T(), setResult.IsOk())
+	assert.Equal(m.T(), 9090, result.Unwrap())
+}
+
+func (m *MemoryProviderTestSuite) TestMemoryProvider_Set_NotMapping_InvalidInput() {
+	result := m.Provider.Set("application.yaml", []string{"a"})
+
+	assert.True(m.T(), result.IsErr())
+	assert.Equal(m.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func (m *MemoryProviderTestSuite) TestNewMemoryProviderFromFS_ReadsYamlFiles() {
+	fileSystem := fstest.MapFS{
+		"application.yaml":     {Data: []byte("Server:\n  Port: 8080\n")},
+		"nested/database.yml":  {Data: []byte("Host: db\n")},
+		"README.md":            {Data: []byte("not configuration")},
+		"nested/empty.yaml":    {Data: []byte("")},
+		"nested/ignored.jsonl": {Data: []byte("{}")},
+	}
+
+	provider := NewMemoryProviderFromFS(fileSystem).Unwrap()
+
+	assert.Equal(m.T(), 8080, provider.Get("application.yaml", "Server:Port").Unwrap())
+	assert.Equal(m.T(), "db", provider.Get("nested/database.yml", "Host").Unwrap())
+	assert.True(m.T(), provider.Get("nested/empty.yaml", "Host?").IsOk())
+	assert.Equal(m.T(), core.FileNotFound, provider.Get("README.md", "Host").UnwrapErr().ErrorKind)
+}
+
+func (m *MemoryProviderTestSuite) TestNewMemoryProviderFromFS_InvalidYaml_SerializationFailure() {
+	fileSystem := fstest.MapFS{"application.yaml": {Data: []byte("Server: [")}}
+
+	result := NewMemoryProviderFromFS(fileSystem)
+
+	assert.True(m.T(), result.IsErr())
+	assert.Equal(m.T(), core.SerializationFailure, result.UnwrapErr().ErrorKind)
+}
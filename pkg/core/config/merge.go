@@ -0,0 +1,123 @@
+package config
+
+// ListMergeStrategy defines how lists are combined when an overlay is deep merged on top of a base configuration.
+type ListMergeStrategy int
+
+const (
+	// ReplaceLists makes the overlay's list replace the base's list.
+	ReplaceLists ListMergeStrategy = iota
+	// AppendLists appends the overlay's list items after the base's list items.
+	AppendLists
+	// MergeListsByIndex deep merges the items which share the same index, keeping the remaining items of the longest list.
+	MergeListsByIndex
+)
+
+// DeepMerge merges the overlay on top of the base and returns the result as a new map.
+// Maps are merged recursively, lists are merged following the specified strategy and any other value
+// present in the overlay replaces the base's value. Neither the base nor the overlay are modified.
+func DeepMerge(base map[string]any, overlay map[string]any, listMergeStrategy ListMergeStrategy) map[string]any {
+	result := make(map[string]any, len(base)+len(overlay))
+
+	for key, value := range base {
+		result[key] = copyValue(value)
+	}
+
+	for key, overlayValue := range overlay {
+		baseValue, exists := result[key]
+
+		if !exists {
+			result[key] = copyValue(overlayValue)
+			continue
+		}
+
+		result[key] = mergeValues(baseValue, overlayValue, listMergeStrategy)
+	}
+
+	return result
+}
+
+func mergeValues(base any, overlay any, listMergeStrategy ListMergeStrategy) any {
+	switch overlayValue := overlay.(type) {
+	case map[string]any:
+		baseMap, ok := base.(map[string]any)
+
+		if !ok {
+			return copyValue(overlayValue)
+		}
+
+		return DeepMerge(baseMap, overlayValue, listMergeStrategy)
+	case []any:
+		baseList, ok := base.([]any)
+
+		if !ok {
+			return copyValue(overlayValue)
+		}
+
+		return mergeLists(baseList, overlayValue, listMergeStrategy)
+	default:
+		return overlay
+	}
+}
+
+func mergeLists(base []any, overlay []any, listMergeStrategy ListMergeStrategy) []any {
+	switch listMergeStrategy {
+	case AppendLists:
+		result := make([]any, 0, len(base)+len(overlay))
+
+		for _, item := range base {
+			result = append(result, copyValue(item))
+		}
+
+		for _, item := range overlay {
+			result = append(result, copyValue(item))
+		}
+
+		return result
+	case MergeListsByIndex:
+		length := len(base)
+
+		if len(overlay) > length {
+			length = len(overlay)
+		}
+
+		result := make([]any, length)
+
+		for i := 0; i < length; i++ {
+			switch {
+			case i >= len(overlay):
+				result[i] = copyValue(base[i])
+			case i >= len(base):
+				result[i] = copyValue(overlay[i])
+			default:
+				result[i] = mergeValues(base[i], overlay[i], listMergeStrategy)
+			}
+		}
+
+		return result
+	default:
+		return copyValue(overlay).([]any)
+	}
+}
+
+func copyValue(value any) any {
+	switch typedValue := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(typedValue))
+
+		for key, item := range typedValue {
+			result[key] = copyValue(item)
+		}
+
+		return result
+	case []any:
+		result := make([]any, len(typedValue))
+
+		for i, item := range typedValue {
+			result[i] = copyValue(item)
+		}
+
+		return result
+	default:
+		return value
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDeepMerge_NestedMaps_MergesRecursively(t *testing.T) {
+	base := map[string]any{"Parent": map[string]any{"Keep": 1, "Override": 2}}
+	overlay := map[string]any{"Parent": map[string]any{"Override": 3, "New": 4}}
+
+	result := DeepMerge(base, overlay, ReplaceLists)
+
+	assert.Equal(t, map[string]any{"Parent": map[string]any{"Keep": 1, "Override": 3, "New": 4}}, result)
+}
+
+func TestDeepMerge_DoesNotModifyInputs(t *testing.T) {
+	base := map[string]any{"Parent": map[string]any{"Value": 1}}
+	overlay := map[string]any{"Parent": map[string]any{"Value": 2}}
+
+	_ = DeepMerge(base, overlay, ReplaceLists)
+
+	assert.Equal(t, 1, base["Parent"].(map[string]any)["Value"])
+	assert.Equal(t, 2, overlay["Parent"].(map[string]any)["Value"])
+}
+
+func TestDeepMerge_ReplaceLists_UsesOverlayList(t *testing.T) {
+	base := map[string]any{"List": []any{1, 2}}
+	overlay := map[string]any{"List": []any{3}}
+
+	result := DeepMerge(base, overlay, ReplaceLists)
+
+	assert.Equal(t, []any{3}, result["List"])
+}
+
+func TestDeepMerge_AppendLists_ConcatenatesLists(t *testing.T) {
+	base := map[string]any{"List": []any{1, 2}}
+	overlay := map[string]any{"List": []any{3}}
+
+	result := DeepMerge(base, overlay, AppendLists)
+
+	assert.Equal(t, []any{1, 2, 3}, result["List"])
+}
+
+func TestDeepMerge_MergeListsByIndex_MergesItemsAtSameIndex(t *testing.T) {
+	base := map[string]any{"List": []any{map[string]any{"Host": "a", "Port": 1}, "b"}}
+	overlay := map[string]any{"List": []any{map[string]any{"Port": 2}}}
+
+	result := DeepMerge(base, overlay, MergeListsByIndex)
+
+	assert.Equal(t, []any{map[string]any{"Host": "a", "Port": 2}, "b"}, result["List"])
+}
+
+func TestDeepMerge_DifferentTypes_OverlayWins(t *testing.T) {
+	base := map[string]any{"Value": map[string]any{"Inner": 1}}
+	overlay := map[string]any{"Value": "scalar"}
+
+	result := DeepMerge(base, overlay, ReplaceLists)
+
+	assert.Equal(t, "scalar", result["Value"])
+}
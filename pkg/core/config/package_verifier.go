@@ -0,0 +1,150 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"strings"
+)
+
+// PackageDigestHeader contains the hex encoded SHA-256 digest of the configuration package.
+const PackageDigestHeader = "X-Config-Package-Sha256"
+
+// PackageSignatureHeader contains the base64 encoded Ed25519 signature of the configuration package's SHA-256 digest.
+const PackageSignatureHeader = "X-Config-Package-Signature"
+
+// PackageSignature is the digest and the optional signature published along with a configuration package.
+type PackageSignature struct {
+	// Digest is the hex encoded SHA-256 digest of the package.
+	Digest string
+	// Signature is the base64 encoded Ed25519 signature of the package's digest; empty if the package is not signed.
+	Signature string
+}
+
+// PackageVerifier verifies the integrity and, optionally, the authenticity of configuration packages.
+type PackageVerifier struct {
+	publicKeys       []ed25519.PublicKey
+	requireSignature bool
+}
+
+// NewPackageVerifier creates an instance of PackageVerifier which accepts signatures made by any of the public keys.
+// If requireSignature is set, packages without a signature are rejected.
+func NewPackageVerifier(publicKeys []ed25519.PublicKey, requireSignature bool) *PackageVerifier {
+	verifier := new(PackageVerifier)
+
+	verifier.publicKeys = publicKeys
+	verifier.requireSignature = requireSignature
+
+	return verifier
+}
+
+// Verify checks the package against its expected hex encoded SHA-256 digest and its base64 encoded signature,
+// which may be empty if signatures are not required.
+func (p *PackageVerifier) Verify(packageData []byte, expectedDigest string, signature string) core.Result[core.Empty, core.Error] {
+	digest := sha256.Sum256(packageData)
+
+	return p.VerifyDigest(digest[:], expectedDigest, signature)
+}
+
+// VerifyDigest checks the package's computed SHA-256 digest against its expected hex encoded SHA-256 digest and its
+// base64 encoded signature, which may be empty if signatures are not required.
+func (p *PackageVerifier) VerifyDigest(digest []byte, expectedDigest string, signature string) core.Result[core.Empty, core.Error] {
+	if len(expectedDigest) == 0 {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IntegrityFailure, "configuration package has no digest"))
+	}
+
+	decodedExpectedDigest, err := hex.DecodeString(strings.TrimSpace(expectedDigest))
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IntegrityFailure, fmt.Sprintf("failed to decode configuration package's digest: %s", err)))
+	}
+
+	if !strings.EqualFold(hex.EncodeToString(digest), hex.EncodeToString(decodedExpectedDigest)) {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IntegrityFailure, "configuration package does not match its digest"))
+	}
+
+	if len(signature) == 0 {
+		if p.requireSignature {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.IntegrityFailure, "configuration package has no signature"))
+		}
+
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	decodedSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IntegrityFailure, fmt.Sprintf("failed to decode configuration package's signature: %s", err)))
+	}
+
+	for _, publicKey := range p.publicKeys {
+		if ed25519.Verify(publicKey, digest, decodedSignature) {
+			return core.Ok[core.Empty, core.Error](core.Empty{})
+		}
+	}
+
+	return core.Err[core.Empty, core.Error](*core.NewError(core.IntegrityFailure, "configuration package's signature does not match any trusted public key"))
+}
+
+// ComputePackageDigest returns the hex encoded SHA-256 digest of the package, as expected by PackageDigestHeader.
+func ComputePackageDigest(packageData []byte) string {
+	return computeChecksum(packageData)
+}
+
+// SignPackage returns the base64 encoded Ed25519 signature of the package's SHA-256 digest, as expected by PackageSignatureHeader.
+func SignPackage(privateKey ed25519.PrivateKey, packageData []byte) string {
+	digest := sha256.Sum256(packageData)
+
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, digest[:]))
+}
+
+// ParseEd25519PublicKey parses a PEM encoded PKIX Ed25519 public key.
+func ParseEd25519PublicKey(pemData []byte) core.Result[ed25519.PublicKey, core.Error] {
+	block, _ := pem.Decode(pemData)
+
+	if block == nil {
+		return core.Err[ed25519.PublicKey, core.Error](*core.NewError(core.InvalidInput, "failed to decode PEM data"))
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+
+	if err != nil {
+		return core.Err[ed25519.PublicKey, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("failed to parse public key: %s", err)))
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+
+	if !ok {
+		return core.Err[ed25519.PublicKey, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("expected an Ed25519 public key but found '%T'", key)))
+	}
+
+	return core.Ok[ed25519.PublicKey, core.Error](publicKey)
+}
+
+// ParseEd25519PrivateKey parses a PEM encoded PKCS #8 Ed25519 private key.
+func ParseEd25519PrivateKey(pemData []byte) core.Result[ed25519.PrivateKey, core.Error] {
+	block, _ := pem.Decode(pemData)
+
+	if block == nil {
+		return core.Err[ed25519.PrivateKey, core.Error](*core.NewError(core.InvalidInput, "failed to decode PEM data"))
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+	if err != nil {
+		return core.Err[ed25519.PrivateKey, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("failed to parse private key: %s", err)))
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+
+	if !ok {
+		return core.Err[ed25519.PrivateKey, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("expected an Ed25519 private key but found '%T'", key)))
+	}
+
+	return core.Ok[ed25519.PrivateKey, core.Error](privateKey)
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type PackageVerifierTestSuite struct {
+	suite.Suite
+	PackageData []byte
+	PublicKey   ed25519.PublicKey
+	PrivateKey  ed25519.PrivateKey
+	Verifier    *PackageVerifier
+}
+
+func TestPackageVerifierTestSuite(t *testing.T) {
+	suite.Run(t, new(PackageVerifierTestSuite))
+}
+
+func (p *PackageVerifierTestSuite) SetupTest() {
+	p.PackageData = []byte("package")
+	p.PublicKey, p.PrivateKey, _ = ed25519.GenerateKey(rand.Reader)
+	p.Verifier = NewPackageVerifier([]ed25519.PublicKey{p.PublicKey}, true)
+}
+
+func (p *PackageVerifierTestSuite) TestVerify_ValidDigestAndSignature_Ok() {
+	result := p.Verifier.Verify(p.PackageData, ComputePackageDigest(p.PackageData), SignPackage(p.PrivateKey, p.PackageData))
+
+	assert.True(p.T(), result.IsOk())
+}
+
+func (p *PackageVerifierTestSuite) TestVerify_TamperedPackage_IntegrityFailure() {
+	digest := ComputePackageDigest(p.PackageData)
+	signature := SignPackage(p.PrivateKey, p.PackageData)
+
+	result := p.Verifier.Verify([]byte("tampered"), digest, signature)
+
+	p.assertIntegrityFailure(result)
+}
+
+func (p *PackageVerifierTestSuite) TestVerify_MissingDigest_IntegrityFailure() {
+	result := p.Verifier.Verify(p.PackageData, "", SignPackage(p.PrivateKey, p.PackageData))
+
+	p.assertIntegrityFailure(result)
+}
+
+func (p *PackageVerifierTestSuite) TestVerify_UntrustedSignature_IntegrityFailure() {
+	_, untrustedPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	result := p.Verifier.Verify(p.PackageData, ComputePackageDigest(p.PackageData), SignPackage(untrustedPrivateKey, p.PackageData))
+
+	p.assertIntegrityFailure(result)
+}
+
+func (p *PackageVerifierTestSuite) TestVerify_MissingRequiredSignature_IntegrityFailure() {
+	result := p.Verifier.Verify(p.PackageData, ComputePackageDigest(p.PackageData), "")
+
+	p.assertIntegrityFailure(result)
+}
+
+func (p *PackageVerifierTestSuite) TestVerify_MissingOptionalSignature_Ok() {
+	verifier := NewPackageVerifier(nil, false)
+
+	result := verifier.Verify(p.PackageData, ComputePackageDigest(p.PackageData), "")
+
+	assert.True(p.T(), result.IsOk())
+}
+
+func (p *PackageVerifierTestSuite) TestParseEd25519PublicKey_PemKey_ReturnsKey() {
+	der, _ := x509.MarshalPKIXPublicKey(p.PublicKey)
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	result := ParseEd25519PublicKey(pemData)
+
+	assert.True(p.T(), result.IsOk())
+	assert.Equal(p.T(), p.PublicKey, result.Unwrap())
+}
+
+func (p *PackageVerifierTestSuite) assertIntegrityFailure(result core.Result[core.Empty, core.Error]) {
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.IntegrityFailure, result.UnwrapErr().ErrorKind)
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// packageModificationTime is used for every entry, so packing the same directory always produces the same package.
+var packageModificationTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// PackDirectory packs the directory's content into a zip configuration package, keeping the files' permissions
+// and symbolic links. The output is deterministic, so its digest only changes when the content does.
+func PackDirectory(directory string) core.Result[[]byte, core.Error] {
+	buffer := new(bytes.Buffer)
+	writer := zip.NewWriter(buffer)
+
+	// WalkDir visits the entries in lexical order.
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == directory {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(directory, path)
+
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return err
+		}
+
+		return writePackageEntry(writer, path, filepath.ToSlash(relativePath), info)
+	})
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to pack directory '%s': %s", directory, err)))
+	}
+
+	if err := writer.Close(); err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to pack directory '%s': %s", directory, err)))
+	}
+
+	return core.Ok[[]byte, core.Error](buffer.Bytes())
+}
+
+func writePackageEntry(writer *zip.Writer, path string, name string, info fs.FileInfo) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: packageModificationTime}
+	header.SetMode(info.Mode())
+
+	if info.IsDir() {
+		header.Name += "/"
+		header.Method = zip.Store
+		_, err := writer.CreateHeader(header)
+
+		return err
+	}
+
+	entryWriter, err := writer.CreateHeader(header)
+
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		linkTarget, err := os.Readlink(path)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = entryWriter.Write([]byte(filepath.ToSlash(linkTarget)))
+
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("'%s' is not a regular file", name)
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(entryWriter, file)
+
+	return err
+}
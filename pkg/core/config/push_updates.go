@@ -0,0 +1,174 @@
+package config
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"time"
+)
+
+// PackagePublishedEvent is the server-sent event whose data is the PackageVersion of a newly published package.
+const PackagePublishedEvent = "published"
+
+// WebhookSignatureHeader contains the signature of the webhook's body, 'sha256=<hex encoded HMAC-SHA256>'.
+const WebhookSignatureHeader = "X-Config-Webhook-Signature"
+
+// maxPackageVersionSize limits the bodies which contain at most a PackageVersion, i.e. the webhook's.
+const maxPackageVersionSize = 64 * 1024
+
+// StartPushUpdates makes the Client reload the configuration package as soon as a new version is published,
+// instead of waiting for the next Reload. The Downloader must be a PublicationWatcher, i.e. the ServerDownloader,
+// whose connection is kept until Close and re-established after the reconnect interval whenever it's lost.
+// It can only be started once, and not after Close.
+func (c *Client) StartPushUpdates(reconnectInterval time.Duration) core.Result[core.Empty, core.Error] {
+	watcher, ok := c.downloader.(PublicationWatcher)
+
+	if !ok {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, "downloader does not support push updates"))
+	}
+
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	if c.pushUpdatesDone != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, "push updates have already been started"))
+	}
+
+	if c.isClosed() {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.InvalidInput, "client has been closed"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pushUpdatesDone := make(chan struct{})
+	c.pushUpdatesDone = pushUpdatesDone
+
+	go func() {
+		select {
+		case <-c.stopChannel:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	go func() {
+		defer close(pushUpdatesDone)
+		defer cancel()
+
+		c.watchPublications(ctx, watcher, reconnectInterval)
+	}()
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+func (c *Client) watchPublications(ctx context.Context, watcher PublicationWatcher, reconnectInterval time.Duration) {
+	onPublished := func(version PackageVersion) {
+		result := c.reloadPublished(version)
+
+		if result.IsErr() {
+			c.logger.Warn("Failed to reload published configuration package.", zap.String("version", version.Version), zap.String("err", result.UnwrapErr().Message))
+		}
+	}
+
+	for {
+		result := watcher.WatchPublications(ctx, c.host, c.stage, c.environment, c.component, onPublished)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if result.IsErr() {
+			c.logger.Warn("Lost configuration publications, reconnecting.", zap.Duration("delay", reconnectInterval), zap.String("err", result.UnwrapErr().Message))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+// reloadPublished reloads the configuration package once a new version has been published. It's skipped while
+// a version is pinned, when the published version is already active, before the package is first loaded, since
+// the first Get loads the latest version anyway, or once the Client is closed.
+func (c *Client) reloadPublished(version PackageVersion) core.Result[core.Empty, core.Error] {
+	if c.isClosed() || len(c.PinnedVersion()) > 0 || c.Status() == Uninitialized {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	if len(version.Version) > 0 && version.Version == c.ActiveVersion() {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	c.logger.Info("Configuration package published, reloading it.", zap.String("version", version.Version))
+
+	return c.Reload()
+}
+
+// WebhookHandler returns a handler which reloads the configuration package on every 'POST' request, as an
+// alternative to StartPushUpdates for the pipelines which publish the packages. The body may contain the published
+// PackageVersion as JSON, so an already active version is not downloaded again. Requests must be signed with
+// the secret, which cannot be empty, within the WebhookSignatureHeader; see SignWebhook.
+func (c *Client) WebhookHandler(secret string) core.Result[http.Handler, core.Error] {
+	if len(secret) == 0 {
+		return core.Err[http.Handler, core.Error](*core.NewError(core.InvalidInput, "webhook secret cannot be empty"))
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPackageVersionSize))
+
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if !hmac.Equal([]byte(r.Header.Get(WebhookSignatureHeader)), []byte(SignWebhook(secret, body))) {
+			c.logger.Warn("Rejected configuration webhook with an invalid signature.")
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var version PackageVersion
+
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &version); err != nil {
+				http.Error(w, fmt.Sprintf("invalid body: %s", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		result := c.reloadPublished(version)
+
+		if result.IsErr() {
+			c.logger.Warn("Failed to reload configuration package from webhook.", zap.String("err", result.UnwrapErr().Message))
+			// The error's details stay within the logs, since they are not meant for the webhook's caller.
+			http.Error(w, "failed to reload configuration package", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return core.Ok[http.Handler, core.Error](handler)
+}
+
+// SignWebhook returns the WebhookSignatureHeader's value for the webhook's body.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const webhookSecret = "webhook-secret"
+
+type MockPublicationWatcher struct {
+	MockDownloader
+	Publications chan PackageVersion
+}
+
+type PushUpdatesTestSuite struct {
+	suite.Suite
+	WorkingPath string
+	Watcher     *MockPublicationWatcher
+	Extractor   *MockExtractor
+	Provider    *MockProvider
+	Client      *Client
+}
+
+func TestPushUpdatesTestSuite(t *testing.T) {
+	suite.Run(t, new(PushUpdatesTestSuite))
+}
+
+func (m *MockPublicationWatcher) WatchPublications(ctx context.Context, host string, stage string, environment string, component string, onPublished func(PackageVersion)) core.Result[core.Empty, core.Error] {
+	for {
+		select {
+		case <-ctx.Done():
+			return core.Ok[core.Empty, core.Error](core.Empty{})
+		case version := <-m.Publications:
+			onPublished(version)
+		}
+	}
+}
+
+func (p *PushUpdatesTestSuite) SetupTest() {
+	p.WorkingPath = uuid.New().String()
+	p.Watcher = &MockPublicationWatcher{Publications: make(chan PackageVersion)}
+	p.Extractor = new(MockExtractor)
+	p.Provider = new(MockProvider)
+	logger, _ := zap.NewDevelopment()
+	p.Client = NewClient(logger, host, stage, environment, component, p.WorkingPath, p.Watcher, p.Extractor, p.Provider)
+
+	p.Watcher.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error]([]byte{}))
+	p.Extractor.On("Extract", []byte{}, stagingPathOf(p.WorkingPath)).Return()
+	p.Provider.On("Get", filePath, configKey).Return(value)
+	p.Provider.On("CleanCache").Return()
+}
+
+func (p *PushUpdatesTestSuite) TearDownTest() {
+	p.Client.Close()
+	_ = RemoveExtraction(p.WorkingPath)
+}
+
+func (p *PushUpdatesTestSuite) TestStartPushUpdates_Published_Reloads() {
+	_ = p.Client.Get(filePath, configKey)
+	reloaded := make(chan ConfigDiff, 1)
+	p.Client.Subscribe(func(diff ConfigDiff) {
+		reloaded <- diff
+	})
+
+	result := p.Client.StartPushUpdates(10 * time.Millisecond)
+	p.Watcher.Publications <- PackageVersion{Version: "2.0.0"}
+
+	assert.True(p.T(), result.IsOk())
+	select {
+	case <-reloaded:
+		p.Watcher.AssertNumberOfCalls(p.T(), "Download", 2)
+	case <-time.After(time.Second):
+		p.Fail("configuration package was not reloaded")
+	}
+}
+
+func (p *PushUpdatesTestSuite) TestStartPushUpdates_AlreadyStarted_InvalidInput() {
+	_ = p.Client.StartPushUpdates(time.Second)
+
+	result := p.Client.StartPushUpdates(time.Second)
+
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func (p *PushUpdatesTestSuite) TestStartPushUpdates_Closed_InvalidInput() {
+	p.Client.Close()
+
+	result := p.Client.StartPushUpdates(time.Second)
+
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func (p *PushUpdatesTestSuite) TestClose_PushUpdatesStarted_WaitsForWatcher() {
+	_ = p.Client.Get(filePath, configKey)
+	_ = p.Client.StartPushUpdates(time.Second)
+
+	p.Client.Close()
+
+	select {
+	case <-p.Client.pushUpdatesDone:
+	default:
+		p.Fail("publications' watcher is still running")
+	}
+	assert.False(p.T(), doesDirectoryExist(p.WorkingPath))
+}
+
+func (p *PushUpdatesTestSuite) TestStartPushUpdates_NotWatcher_InvalidInput() {
+	logger, _ := zap.NewDevelopment()
+	client := NewClient(logger, host, stage, environment, component, p.WorkingPath, new(MockDownloader), p.Extractor, p.Provider)
+
+	result := client.StartPushUpdates(time.Second)
+
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func (p *PushUpdatesTestSuite) TestWebhookHandler_ValidSignature_Reloads() {
+	_ = p.Client.Get(filePath, configKey)
+	body := []byte(`{"version":"2.0.0"}`)
+
+	response := p.PostWebhook(body, SignWebhook(webhookSecret, body))
+
+	assert.Equal(p.T(), http.StatusNoContent, response.Code)
+	p.Watcher.AssertNumberOfCalls(p.T(), "Download", 2)
+}
+
+func (p *PushUpdatesTestSuite) TestWebhookHandler_InvalidSignature_Unauthorized() {
+	_ = p.Client.Get(filePath, configKey)
+	body := []byte(`{"version":"2.0.0"}`)
+
+	response := p.PostWebhook(body, SignWebhook("other-secret", body))
+
+	assert.Equal(p.T(), http.StatusUnauthorized, response.Code)
+	p.Watcher.AssertNumberOfCalls(p.T(), "Download", 1)
+}
+
+func (p *PushUpdatesTestSuite) TestWebhookHandler_Uninitialized_SkipsReload() {
+	response := p.PostWebhook(nil, SignWebhook(webhookSecret, nil))
+
+	assert.Equal(p.T(), http.StatusNoContent, response.Code)
+	p.Watcher.AssertNotCalled(p.T(), "Download", host, stage, environment, component)
+}
+
+func (p *PushUpdatesTestSuite) TestWebhookHandler_Closed_SkipsReload() {
+	_ = p.Client.Get(filePath, configKey)
+	p.Client.Close()
+	body := []byte(`{"version":"2.0.0"}`)
+
+	response := p.PostWebhook(body, SignWebhook(webhookSecret, body))
+
+	assert.Equal(p.T(), http.StatusNoContent, response.Code)
+	p.Watcher.AssertNumberOfCalls(p.T(), "Download", 1)
+	assert.False(p.T(), doesDirectoryExist(p.WorkingPath))
+}
+
+func (p *PushUpdatesTestSuite) TestWebhookHandler_FailedReload_HidesError() {
+	watcher := &MockPublicationWatcher{}
+	watcher.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error]([]byte{})).Once()
+	watcher.On("Download", host, stage, environment, component).Return(core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "failed to reach '/internal/path'")))
+	logger, _ := zap.NewDevelopment()
+	p.Client = NewClient(logger, host, stage, environment, component, p.WorkingPath, watcher, p.Extractor, p.Provider)
+	_ = p.Client.Get(filePath, configKey)
+	body := []byte(`{"version":"2.0.0"}`)
+
+	response := p.PostWebhook(body, SignWebhook(webhookSecret, body))
+
+	assert.Equal(p.T(), http.StatusInternalServerError, response.Code)
+	assert.NotContains(p.T(), response.Body.String(), "/internal/path")
+}
+
+func (p *PushUpdatesTestSuite) TestWebhookHandler_EmptySecret_InvalidInput() {
+	result := p.Client.WebhookHandler("")
+
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func (p *PushUpdatesTestSuite) TestWebhookHandler_Get_MethodNotAllowed() {
+	response := httptest.NewRecorder()
+
+	p.Client.WebhookHandler(webhookSecret).Unwrap().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+
+	assert.Equal(p.T(), http.StatusMethodNotAllowed, response.Code)
+}
+
+func (p *PushUpdatesTestSuite) TestServerDownloader_WatchPublications_ReadsPublishedEvents() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(": keep-alive\n\nevent: other\ndata: {}\n\nevent: published\ndata: {\"version\":\"2.0.0\",\ndata: \"digest\":\"abc\"}\n\n"))
+	}))
+	defer server.Close()
+	logger, _ := zap.NewDevelopment()
+	downloader := NewServerDownloader(logger, "token", time.Second)
+	versions := make([]PackageVersion, 0)
+
+	result := downloader.WatchPublications(context.Background(), server.URL, stage, environment, component, func(version PackageVersion) {
+		versions = append(versions, version)
+	})
+
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.ConfigurationRetrievalFailure, result.UnwrapErr().ErrorKind)
+	assert.Equal(p.T(), []PackageVersion{{Version: "2.0.0", Digest: "abc"}}, versions)
+}
+
+func (p *PushUpdatesTestSuite) PostWebhook(body []byte, signature string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+	request.Header.Set(WebhookSignatureHeader, signature)
+	response := httptest.NewRecorder()
+
+	p.Client.WebhookHandler(webhookSecret).Unwrap().ServeHTTP(response, request)
+
+	return response
+}
@@ -0,0 +1,333 @@
+package config
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const signatureAlgorithm = "AWS4-HMAC-SHA256"
+const s3Service = "s3"
+
+// S3PackageDigestHeader is the object's user metadata 'config-package-sha256', which contains the hex encoded SHA-256
+// digest of the configuration package.
+const S3PackageDigestHeader = "X-Amz-Meta-Config-Package-Sha256"
+
+// S3PackageSignatureHeader is the object's user metadata 'config-package-signature', which contains the base64
+// encoded Ed25519 signature of the configuration package's SHA-256 digest.
+const S3PackageSignatureHeader = "X-Amz-Meta-Config-Package-Signature"
+
+// emptyPayloadHash is the SHA-256 digest of an empty body, which is the payload of GET requests.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Downloader downloads configuration packages from an S3-compatible object storage, authenticating requests
+// with AWS Signature Version 4. The host is the storage's endpoint, i.e. 'https://s3.eu-west-1.amazonaws.com',
+// and each package is the object '<stage>/<environment>/<component>.zip' of the bucket, addressed path-style.
+//
+// Since the S3Downloader is a ConditionalDownloader, DownloadIfModified returns a 'not_modified' error when
+// the object's ETag has not changed since its last conditional download. Objects uploaded with the user metadata
+// 'config-package-sha256' and 'config-package-signature' can be verified, since the S3Downloader is a SignedDownloader.
+type S3Downloader struct {
+	logger          *zap.Logger
+	bucket          string
+	region          string
+	accessKeyId     string
+	secretAccessKey string
+	downloadTimeout time.Duration
+	// packageLimits bound the size of the downloaded objects.
+	packageLimits ExtractionLimits
+	// etags contains the 'ETag' header of the last object downloaded from each url.
+	etags      map[string]string
+	etagsMutex sync.Mutex
+	// signatures contains the digest and signature stored within the metadata of the last object downloaded from each url.
+	signatures      map[string]PackageSignature
+	signaturesMutex sync.Mutex
+}
+
+func NewS3Downloader(logger *zap.Logger,
+	bucket string,
+	region string,
+	accessKeyId string,
+	secretAccessKey string,
+	downloadTimeout time.Duration) *S3Downloader {
+	s := new(S3Downloader)
+	s.logger = logger
+	s.bucket = bucket
+	s.region = region
+	s.accessKeyId = accessKeyId
+	s.secretAccessKey = secretAccessKey
+	s.downloadTimeout = downloadTimeout
+	s.packageLimits = DefaultExtractionLimits()
+	s.etags = make(map[string]string)
+	s.signatures = make(map[string]PackageSignature)
+
+	return s
+}
+
+// SetPackageLimits sets the limits which bound the size of the downloaded objects, which are the
+// DefaultExtractionLimits by default; they should match the limits of the Extractor the packages are extracted by.
+func (s *S3Downloader) SetPackageLimits(limits ExtractionLimits) {
+	s.packageLimits = limits
+}
+
+// ResetConditions forgets the ETag of the package's object, so its next conditional download returns it.
+func (s *S3Downloader) ResetConditions(host string, stage string, environment string, component string) {
+	s.etagsMutex.Lock()
+	defer s.etagsMutex.Unlock()
+
+	delete(s.etags, s.getObjectUrl(host, getObjectKey(stage, environment, component)))
+}
+
+// PackageSignature returns the digest and signature stored within the metadata of the last downloaded object.
+func (s *S3Downloader) PackageSignature(host string, stage string, environment string, component string) core.Option[PackageSignature] {
+	s.signaturesMutex.Lock()
+	defer s.signaturesMutex.Unlock()
+
+	signature, exists := s.signatures[s.getObjectUrl(host, getObjectKey(stage, environment, component))]
+
+	if !exists {
+		return core.None[PackageSignature]()
+	}
+
+	return core.Some(signature)
+}
+
+// ReportVerification forgets the ETag of a rejected object, so it's downloaded again.
+func (s *S3Downloader) ReportVerification(host string, stage string, environment string, component string, verified bool) {
+	if !verified {
+		s.ResetConditions(host, stage, environment, component)
+	}
+}
+
+func (s *S3Downloader) Download(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
+	return s.download(host, stage, environment, component, false)
+}
+
+// DownloadIfModified works like Download, but sends the ETag of the object's last conditional download within
+// the 'If-None-Match' header, so a 'not_modified' error is returned when it has not changed.
+func (s *S3Downloader) DownloadIfModified(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
+	return s.download(host, stage, environment, component, true)
+}
+
+func (s *S3Downloader) download(host string, stage string, environment string, component string, conditional bool) core.Result[[]byte, core.Error] {
+	objectKey := getObjectKey(stage, environment, component)
+	objectUrl := s.getObjectUrl(host, objectKey)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.downloadTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "GET", objectUrl, nil)
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to create request: %s", err)))
+	}
+
+	if etag := s.getEtag(objectUrl); conditional && len(etag) > 0 {
+		request.Header.Set("If-None-Match", etag)
+	}
+
+	SignRequestV4(request, s.accessKeyId, s.secretAccessKey, s.region, s3Service, time.Now())
+
+	client := http.Client{
+		Timeout: s.downloadTimeout,
+	}
+
+	response, err := client.Do(request)
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to make GET request: %s", err)))
+	}
+
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			s.logger.Warn("Failed to close response's body.", zap.String("err", err.Error()))
+		}
+	}(response.Body)
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return core.Err[[]byte, core.Error](*core.NewError(core.NotModified, "configuration package has not been modified"))
+	case http.StatusNotFound:
+		return core.Err[[]byte, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find object '%s' within bucket '%s'", objectKey, s.bucket)))
+	default:
+		return core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("received an unexpected status code %d", response.StatusCode)))
+	}
+
+	bodyResult := readAllWithinLimit(response.Body, s.packageLimits.maxPackageSize())
+
+	if bodyResult.IsErr() {
+		return bodyResult
+	}
+
+	if conditional {
+		s.setEtag(objectUrl, response.Header.Get("ETag"))
+	}
+
+	s.setSignature(objectUrl, PackageSignature{
+		Digest:    response.Header.Get(S3PackageDigestHeader),
+		Signature: response.Header.Get(S3PackageSignatureHeader),
+	})
+
+	return bodyResult
+}
+
+func getObjectKey(stage string, environment string, component string) string {
+	return fmt.Sprintf("%s/%s/%s.zip", stage, environment, component)
+}
+
+func (s *S3Downloader) getObjectUrl(host string, objectKey string) string {
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(host, "/"), s.bucket, encodeObjectPath(objectKey))
+}
+
+func (s *S3Downloader) getEtag(objectUrl string) string {
+	s.etagsMutex.Lock()
+	defer s.etagsMutex.Unlock()
+
+	return s.etags[objectUrl]
+}
+
+func (s *S3Downloader) setEtag(objectUrl string, etag string) {
+	s.etagsMutex.Lock()
+	defer s.etagsMutex.Unlock()
+
+	if len(etag) == 0 {
+		delete(s.etags, objectUrl)
+		return
+	}
+
+	s.etags[objectUrl] = etag
+}
+
+func (s *S3Downloader) setSignature(objectUrl string, signature PackageSignature) {
+	s.signaturesMutex.Lock()
+	defer s.signaturesMutex.Unlock()
+
+	s.signatures[objectUrl] = signature
+}
+
+// SignRequestV4 signs a request without body with AWS Signature Version 4. Every header already set on the
+// request is signed, along with the 'Host', 'X-Amz-Date' and 'X-Amz-Content-Sha256' headers which are added.
+func SignRequestV4(request *http.Request, accessKeyId string, secretAccessKey string, region string, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+
+	if len(request.Host) > 0 {
+		headers["host"] = request.Host
+	}
+
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	headerNames := make([]string, 0, len(headers))
+
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+
+	sort.Strings(headerNames)
+
+	canonicalHeaders := strings.Builder{}
+
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(headerNames, ";")
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQueryString(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{dateStamp, region, service, "aws4_request"}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{signatureAlgorithm, amzDate, scope, hex.EncodeToString(canonicalRequestHash[:])}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+secretAccessKey), dateStamp)
+	signingKey = hmacSha256(signingKey, region)
+	signingKey = hmacSha256(signingKey, service)
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signatureAlgorithm, accessKeyId, scope, signedHeaders, signature))
+}
+
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	parameters := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+
+		for _, value := range values {
+			parameters = append(parameters, encodeUriComponent(key)+"="+encodeUriComponent(value))
+		}
+	}
+
+	return strings.Join(parameters, "&")
+}
+
+// encodeObjectPath encodes each segment of the object's key, keeping the '/' separators.
+func encodeObjectPath(objectKey string) string {
+	segments := strings.Split(objectKey, "/")
+
+	for i, segment := range segments {
+		segments[i] = encodeUriComponent(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// encodeUriComponent encodes every byte except the unreserved characters, as required by Signature Version 4.
+func encodeUriComponent(value string) string {
+	builder := strings.Builder{}
+
+	for _, character := range []byte(value) {
+		if ('A' <= character && character <= 'Z') || ('a' <= character && character <= 'z') || ('0' <= character && character <= '9') ||
+			character == '-' || character == '_' || character == '.' || character == '~' {
+			builder.WriteByte(character)
+		} else {
+			builder.WriteString(fmt.Sprintf("%%%02X", character))
+		}
+	}
+
+	return builder.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
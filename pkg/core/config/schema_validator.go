@@ -0,0 +1,413 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"gopkg.in/yaml.v3"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// SchemaValidator validates configuration files against JSON Schemas.
+// The supported keywords are: 'type', 'enum', 'const', 'properties', 'required', 'additionalProperties',
+// 'items', 'minItems', 'maxItems', 'minLength', 'maxLength', 'pattern', 'minimum', 'maximum',
+// 'exclusiveMinimum' and 'exclusiveMaximum'.
+// The violations never contain the configuration's values, since those may be secrets.
+type SchemaValidator struct {
+	schemas           map[string]map[string]any
+	overlays          []string
+	listMergeStrategy ListMergeStrategy
+	// keyProvider is optional; when set, files encrypted at rest are decrypted before being validated.
+	keyProvider KeyProvider
+}
+
+// NewSchemaValidator creates an instance of SchemaValidator which validates each file path of the
+// configuration package against its schema. Every file with a schema is required to exist.
+func NewSchemaValidator(schemas map[string]map[string]any) *SchemaValidator {
+	validator := new(SchemaValidator)
+	validator.schemas = schemas
+
+	return validator
+}
+
+// LoadSchemaValidator creates an instance of SchemaValidator from a YAML or JSON document which maps
+// each file path of the configuration package to its schema.
+func LoadSchemaValidator(schemaFilePath string) core.Result[*SchemaValidator, core.Error] {
+	content, err := os.ReadFile(schemaFilePath)
+
+	if err != nil {
+		return core.Err[*SchemaValidator, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read schema file '%s': %s", schemaFilePath, err)))
+	}
+
+	var schemas map[string]map[string]any
+
+	if err := yaml.Unmarshal(content, &schemas); err != nil {
+		return core.Err[*SchemaValidator, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to read schema file '%s': %s", schemaFilePath, err)))
+	}
+
+	return core.Ok[*SchemaValidator, core.Error](NewSchemaValidator(schemas))
+}
+
+// SetKeyProvider makes the SchemaValidator decrypt the files which have been encrypted at rest by an EncryptedExtractor.
+func (s *SchemaValidator) SetKeyProvider(keyProvider KeyProvider) {
+	s.keyProvider = keyProvider
+}
+
+// SetOverlays makes the SchemaValidator validate each file once the specified overlays have been deep merged on
+// top of it, the same way a FileProvider created through NewOverlayFileProvider reads it.
+func (s *SchemaValidator) SetOverlays(overlays []string, listMergeStrategy ListMergeStrategy) {
+	s.overlays = overlays
+	s.listMergeStrategy = listMergeStrategy
+}
+
+func (s *SchemaValidator) Validate(packagePath string) core.Result[core.Empty, core.Error] {
+	violations := s.Violations(packagePath)
+
+	if len(violations) > 0 {
+		return core.Err[core.Empty, core.Error](*NewViolationsError(violations))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
+
+// Violations returns every violation found within the configuration package extracted within the packagePath.
+func (s *SchemaValidator) Violations(packagePath string) []Violation {
+	filePaths := make([]string, 0, len(s.schemas))
+
+	for filePath := range s.schemas {
+		filePaths = append(filePaths, filePath)
+	}
+
+	sort.Strings(filePaths)
+
+	violations := make([]Violation, 0)
+
+	for _, filePath := range filePaths {
+		fullPath := filepath.Join(packagePath, filePath)
+
+		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+			violations = append(violations, Violation{FilePath: filePath, Message: "file is missing"})
+			continue
+		}
+
+		contentResult := readConfigFile(fullPath, s.keyProvider)
+
+		if contentResult.IsErr() {
+			violations = append(violations, Violation{FilePath: filePath, Message: fmt.Sprintf("failed to read file: %s", contentResult.UnwrapErr().Message)})
+			continue
+		}
+
+		content := contentResult.Unwrap()
+
+		var document any
+
+		if err := yaml.Unmarshal(content, &document); err != nil {
+			violations = append(violations, Violation{FilePath: filePath, Message: fmt.Sprintf("failed to parse file: %s", err)})
+			continue
+		}
+
+		documentResult := s.mergeOverlays(fullPath, document)
+
+		if documentResult.IsErr() {
+			violations = append(violations, Violation{FilePath: filePath, Message: fmt.Sprintf("failed to merge overlays: %s", documentResult.UnwrapErr().Message)})
+			continue
+		}
+
+		document = documentResult.Unwrap()
+
+		violations = append(violations, ValidateAgainstSchema(filePath, document, s.schemas[filePath])...)
+	}
+
+	return violations
+}
+
+// mergeOverlays deep merges the overlays on top of the document, unless there are none or the document is not an object.
+func (s *SchemaValidator) mergeOverlays(fullPath string, document any) core.Result[any, core.Error] {
+	if len(s.overlays) == 0 {
+		return core.Ok[any, core.Error](document)
+	}
+
+	if document == nil {
+		document = make(map[string]any)
+	}
+
+	config, ok := document.(map[string]any)
+
+	if !ok {
+		return core.Ok[any, core.Error](document)
+	}
+
+	result := mergeOverlays(fullPath, config, s.overlays, s.listMergeStrategy, s.keyProvider)
+
+	if result.IsErr() {
+		return core.Err[any, core.Error](result.UnwrapErr())
+	}
+
+	return core.Ok[any, core.Error](result.Unwrap())
+}
+
+// ValidateAgainstSchema returns every violation of the schema found within the document.
+func ValidateAgainstSchema(filePath string, document any, schema map[string]any) []Violation {
+	violations := make([]Violation, 0)
+	validateValue(filePath, "", document, schema, &violations)
+
+	return violations
+}
+
+func validateValue(filePath string, keyPath string, value any, schema map[string]any, violations *[]Violation) {
+	addViolation := func(format string, args ...any) {
+		*violations = append(*violations, Violation{FilePath: filePath, KeyPath: keyPath, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if expectedTypes, exists := schema["type"]; exists {
+		if !matchesAnyType(value, expectedTypes) {
+			addViolation("expected type %v but found %s", expectedTypes, describeType(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		if !containsValue(enum, value) {
+			addViolation("value is not one of %v", enum)
+		}
+	}
+
+	if constant, exists := schema["const"]; exists {
+		if !valuesEqual(constant, value) {
+			addViolation("value is not equal to '%v'", constant)
+		}
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]any:
+		validateObject(filePath, keyPath, typedValue, schema, violations, addViolation)
+	case []any:
+		validateArray(filePath, keyPath, typedValue, schema, violations, addViolation)
+	case string:
+		validateString(typedValue, schema, addViolation)
+	default:
+		if number, ok := toFloat(value); ok {
+			validateNumber(number, schema, addViolation)
+		}
+	}
+}
+
+func validateObject(filePath string, keyPath string, object map[string]any, schema map[string]any, violations *[]Violation, addViolation func(string, ...any)) {
+	properties, _ := schema["properties"].(map[string]any)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, requiredKey := range required {
+			key := fmt.Sprintf("%v", requiredKey)
+
+			if _, exists := object[key]; !exists {
+				addViolation("required key '%s' is missing", key)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(object))
+
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childKeyPath := joinKeyPath(keyPath, key)
+
+		if propertySchema, ok := properties[key].(map[string]any); ok {
+			validateValue(filePath, childKeyPath, object[key], propertySchema, violations)
+			continue
+		}
+
+		if _, isProperty := properties[key]; isProperty {
+			continue
+		}
+
+		switch additionalProperties := schema["additionalProperties"].(type) {
+		case bool:
+			if !additionalProperties {
+				*violations = append(*violations, Violation{FilePath: filePath, KeyPath: childKeyPath, Message: "key is not allowed"})
+			}
+		case map[string]any:
+			validateValue(filePath, childKeyPath, object[key], additionalProperties, violations)
+		}
+	}
+}
+
+func validateArray(filePath string, keyPath string, array []any, schema map[string]any, violations *[]Violation, addViolation func(string, ...any)) {
+	if minItems, ok := toFloat(schema["minItems"]); ok && float64(len(array)) < minItems {
+		addViolation("expected at least %v item(s) but found %d", minItems, len(array))
+	}
+
+	if maxItems, ok := toFloat(schema["maxItems"]); ok && float64(len(array)) > maxItems {
+		addViolation("expected at most %v item(s) but found %d", maxItems, len(array))
+	}
+
+	itemSchema, ok := schema["items"].(map[string]any)
+
+	if !ok {
+		return
+	}
+
+	for i, item := range array {
+		validateValue(filePath, joinKeyPath(keyPath, strconv.Itoa(i)), item, itemSchema, violations)
+	}
+}
+
+func validateString(value string, schema map[string]any, addViolation func(string, ...any)) {
+	length := float64(len([]rune(value)))
+
+	if minLength, ok := toFloat(schema["minLength"]); ok && length < minLength {
+		addViolation("expected at least %v character(s) but found %v", minLength, length)
+	}
+
+	if maxLength, ok := toFloat(schema["maxLength"]); ok && length > maxLength {
+		addViolation("expected at most %v character(s) but found %v", maxLength, length)
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		expression, err := regexp.Compile(pattern)
+
+		if err != nil {
+			addViolation("schema contains an invalid pattern '%s': %s", pattern, err)
+		} else if !expression.MatchString(value) {
+			addViolation("value does not match the pattern '%s'", pattern)
+		}
+	}
+}
+
+func validateNumber(value float64, schema map[string]any, addViolation func(string, ...any)) {
+	if minimum, ok := toFloat(schema["minimum"]); ok && value < minimum {
+		addViolation("value is lower than the minimum %v", minimum)
+	}
+
+	if maximum, ok := toFloat(schema["maximum"]); ok && value > maximum {
+		addViolation("value is greater than the maximum %v", maximum)
+	}
+
+	if exclusiveMinimum, ok := toFloat(schema["exclusiveMinimum"]); ok && value <= exclusiveMinimum {
+		addViolation("value must be greater than %v", exclusiveMinimum)
+	}
+
+	if exclusiveMaximum, ok := toFloat(schema["exclusiveMaximum"]); ok && value >= exclusiveMaximum {
+		addViolation("value must be lower than %v", exclusiveMaximum)
+	}
+}
+
+func matchesAnyType(value any, expectedTypes any) bool {
+	switch typedExpectedTypes := expectedTypes.(type) {
+	case string:
+		return matchesType(value, typedExpectedTypes)
+	case []any:
+		for _, expectedType := range typedExpectedTypes {
+			if matchesType(value, fmt.Sprintf("%v", expectedType)) {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return true
+	}
+}
+
+func matchesType(value any, expectedType string) bool {
+	switch expectedType {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		number, ok := toFloat(value)
+		return ok && number == math.Trunc(number)
+	default:
+		return false
+	}
+}
+
+func describeType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	default:
+		return reflect.TypeOf(value).String()
+	}
+}
+
+func toFloat(value any) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	case float64:
+		return number, true
+	default:
+		return 0, false
+	}
+}
+
+func containsValue(values []any, value any) bool {
+	for _, candidate := range values {
+		if valuesEqual(candidate, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func valuesEqual(a any, b any) bool {
+	aNumber, aIsNumber := toFloat(a)
+	bNumber, bIsNumber := toFloat(b)
+
+	if aIsNumber && bIsNumber {
+		return aNumber == bNumber
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func joinKeyPath(keyPath string, key string) string {
+	key = escapeKeySegment(key)
+
+	if len(keyPath) == 0 {
+		return key
+	}
+
+	return keyPath + keySeparator + key
+}
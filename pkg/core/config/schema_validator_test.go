@@ -0,0 +1,139 @@
+package config
+
+import (
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const validatedFile = "application.yaml"
+
+type SchemaValidatorTestSuite struct {
+	suite.Suite
+	PackagePath string
+	Validator   *SchemaValidator
+}
+
+func TestSchemaValidatorTestSuite(t *testing.T) {
+	suite.Run(t, new(SchemaValidatorTestSuite))
+}
+
+func (s *SchemaValidatorTestSuite) SetupTest() {
+	s.PackagePath = uuid.New().String()
+	_ = os.MkdirAll(s.PackagePath, os.ModePerm)
+	s.Validator = NewSchemaValidator(map[string]map[string]any{
+		validatedFile: {
+			"type":     "object",
+			"required": []any{"Host", "Port"},
+			"properties": map[string]any{
+				"Host": map[string]any{"type": "string", "minLength": 1},
+				"Port": map[string]any{"type": "integer", "minimum": 1, "maximum": 65535},
+				"Mode": map[string]any{"enum": []any{"fast", "safe"}},
+				"Servers": map[string]any{
+					"type":  "array",
+					"items": map[string]any{"type": "object", "required": []any{"Host"}},
+				},
+			},
+			"additionalProperties": false,
+		},
+	})
+}
+
+func (s *SchemaValidatorTestSuite) TearDownTest() {
+	_ = os.RemoveAll(s.PackagePath)
+}
+
+func (s *SchemaValidatorTestSuite) TestSchemaValidator_Validate_ValidPackage_Ok() {
+	s.writeFile("Host: \"localhost\"\nPort: 8080\nMode: \"fast\"\nServers:\n  - Host: \"a\"\n")
+
+	result := s.Validator.Validate(s.PackagePath)
+
+	assert.True(s.T(), result.IsOk())
+}
+
+func (s *SchemaValidatorTestSuite) TestSchemaValidator_Validate_MissingFile_Error() {
+	result := s.Validator.Validate(s.PackagePath)
+
+	assert.True(s.T(), result.IsErr())
+	assert.Equal(s.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+	assert.Contains(s.T(), result.UnwrapErr().Message, "application.yaml: file is missing")
+}
+
+func (s *SchemaValidatorTestSuite) TestSchemaValidator_Violations_ReportsEveryViolation() {
+	s.writeFile("Port: 70000\nMode: \"slow\"\nUnknown: true\nServers:\n  - Port: 1\n")
+
+	violations := s.Validator.Violations(s.PackagePath)
+
+	assert.ElementsMatch(s.T(), []Violation{
+		{FilePath: validatedFile, KeyPath: "", Message: "required key 'Host' is missing"},
+		{FilePath: validatedFile, KeyPath: "Mode", Message: "value is not one of [fast safe]"},
+		{FilePath: validatedFile, KeyPath: "Port", Message: "value is greater than the maximum 65535"},
+		{FilePath: validatedFile, KeyPath: "Servers:0", Message: "required key 'Host' is missing"},
+		{FilePath: validatedFile, KeyPath: "Unknown", Message: "key is not allowed"},
+	}, violations)
+}
+
+func (s *SchemaValidatorTestSuite) TestSchemaValidator_Violations_WrongType_ReportsType() {
+	s.writeFile("Host: 5\nPort: \"80\"\n")
+
+	violations := s.Validator.Violations(s.PackagePath)
+
+	assert.Equal(s.T(), []Violation{
+		{FilePath: validatedFile, KeyPath: "Host", Message: "expected type string but found integer"},
+		{FilePath: validatedFile, KeyPath: "Port", Message: "expected type integer but found string"},
+	}, violations)
+}
+
+func (s *SchemaValidatorTestSuite) TestSchemaValidator_Violations_SecretValue_NotReported() {
+	validator := NewSchemaValidator(map[string]map[string]any{
+		validatedFile: {
+			"properties": map[string]any{
+				"Password": map[string]any{"type": "string", "pattern": "^[a-z]+$", "enum": []any{"other"}},
+			},
+		},
+	})
+	s.writeFile("Password: \"S3cr3t!\"\n")
+
+	violations := validator.Violations(s.PackagePath)
+
+	assert.Len(s.T(), violations, 2)
+
+	for _, violation := range violations {
+		assert.NotContains(s.T(), violation.Message, "S3cr3t!")
+	}
+}
+
+func (s *SchemaValidatorTestSuite) TestSchemaValidator_Violations_Overlays_ValidatesMergedFile() {
+	s.writeFile("Host: \"localhost\"\n")
+	_ = os.WriteFile(filepath.Join(s.PackagePath, "application.production.yaml"), []byte("Port: 70000\n"), 0644)
+	s.Validator.SetOverlays([]string{"production"}, ReplaceLists)
+
+	violations := s.Validator.Violations(s.PackagePath)
+
+	assert.Equal(s.T(), []Violation{
+		{FilePath: validatedFile, KeyPath: "Port", Message: "value is greater than the maximum 65535"},
+	}, violations)
+}
+
+func (s *SchemaValidatorTestSuite) TestLoadSchemaValidator_ValidSchemaFile_Ok() {
+	schemaFilePath := filepath.Join(s.PackagePath, "schema.yaml")
+	_ = os.WriteFile(schemaFilePath, []byte("application.yaml:\n  type: object\n  required: [\"Host\"]\n"), 0644)
+	s.writeFile("Port: 80\n")
+
+	result := LoadSchemaValidator(schemaFilePath)
+
+	assert.True(s.T(), result.IsOk())
+	assert.Len(s.T(), result.Unwrap().Violations(s.PackagePath), 1)
+}
+
+func (s *SchemaValidatorTestSuite) writeFile(content string) {
+	err := os.WriteFile(filepath.Join(s.PackagePath, validatedFile), []byte(content), 0644)
+
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+}
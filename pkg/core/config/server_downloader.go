@@ -1,45 +1,566 @@
 package config
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/authorization"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// defaultMaxRetryAfter is the longest 'Retry-After' waited for when the retry policy has no MaxDelay.
+const defaultMaxRetryAfter = time.Minute
+
+// maxVersionsSize limits how much of the configuration server's list of versions is read.
+const maxVersionsSize = 4 << 20
+
+// PackageVersionHeader contains the version of the configuration package served by the configuration server.
+const PackageVersionHeader = "X-Config-Package-Version"
+
 type ServerDownloader struct {
-	logger *zap.Logger
-	// TODO: Can expire, a renewal mechanism must be developed.
-	accessToken     string
+	logger          *zap.Logger
+	tokenSource     authorization.TokenSource
 	downloadTimeout time.Duration
+	retryPolicy     core.RetryPolicy
+	// circuitBreaker is optional; when set, downloads are short-circuited after repeated failures.
+	circuitBreaker *core.CircuitBreaker
+	// validators contains the 'ETag' and 'Last-Modified' headers of the last package conditionally downloaded from each url.
+	validators      map[string]packageValidators
+	validatorsMutex sync.Mutex
+	// signatures contains the digest and signature sent along with the last package downloaded from each package url.
+	signatures map[string]PackageSignature
+	// rejectedPackages contains the package urls whose last package failed its verification.
+	rejectedPackages map[string]bool
+	signaturesMutex  sync.Mutex
+	// spoolDirectory is where DownloadStream spools packages into; empty means the operating system's temporary directory.
+	spoolDirectory string
+	// spoolLimits bound the size of the downloaded packages, whether spooled by DownloadStream or read into memory.
+	spoolLimits ExtractionLimits
+	// tenant is optional; when set, it's sent along with every request so the server picks the tenant's package.
+	tenant string
+	// longPollTimeout is optional; when set, WatchPublications long-polls the server instead of streaming its events.
+	longPollTimeout time.Duration
+}
+
+type packageValidators struct {
+	etag         string
+	lastModified string
 }
 
+// downloadAttempt is the outcome of a single request made to the configuration server.
+type downloadAttempt[T any] struct {
+	result       core.Result[T, core.Error]
+	retryable    bool
+	retryAfter   time.Duration
+	unauthorized bool
+}
+
+// NewServerDownloader creates a ServerDownloader which authenticates with a static access token.
 func NewServerDownloader(logger *zap.Logger, accessToken string, downloadTimeout time.Duration) *ServerDownloader {
+	return NewServerDownloaderWithTokenSource(logger, authorization.NewStaticTokenSource(accessToken), downloadTimeout)
+}
+
+// NewServerDownloaderWithTokenSource creates a ServerDownloader which authenticates with the access tokens provided
+// by the token source. Whenever the server rejects an access token, it's refreshed and the request is retried once.
+func NewServerDownloaderWithTokenSource(logger *zap.Logger, tokenSource authorization.TokenSource, downloadTimeout time.Duration) *ServerDownloader {
 	s := new(ServerDownloader)
 	s.logger = logger
-	s.accessToken = accessToken
+	s.tokenSource = tokenSource
 	s.downloadTimeout = downloadTimeout
+	s.retryPolicy = core.NoRetryPolicy()
+	s.validators = make(map[string]packageValidators)
+	s.spoolLimits = DefaultExtractionLimits()
+	s.signatures = make(map[string]PackageSignature)
+	s.rejectedPackages = make(map[string]bool)
 
 	return s
 }
 
-func (s ServerDownloader) Download(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
-	url := fmt.Sprintf("%s/config?stage=%s&environment=%s&component=%s", host, stage, environment, component)
+// SetRetryPolicy makes the ServerDownloader retry transient failures: connection errors, '429 Too Many Requests'
+// and '5xx' responses. The 'Retry-After' header is honored whenever it asks for a longer delay than the policy,
+// up to the policy's MaxDelay; a longer one fails the download without retrying.
+func (s *ServerDownloader) SetRetryPolicy(retryPolicy core.RetryPolicy) {
+	s.retryPolicy = retryPolicy
+}
+
+// SetCircuitBreaker makes the ServerDownloader fail fast with a 'circuit_open' error after repeated download failures.
+func (s *ServerDownloader) SetCircuitBreaker(circuitBreaker *core.CircuitBreaker) {
+	s.circuitBreaker = circuitBreaker
+}
+
+// SetSpoolDirectory sets the directory where DownloadStream spools the packages into.
+func (s *ServerDownloader) SetSpoolDirectory(spoolDirectory string) {
+	s.spoolDirectory = spoolDirectory
+}
+
+// SetSpoolLimits sets the limits which bound the size of the downloaded packages, whether spooled by DownloadStream
+// or read into memory by Download, which are the DefaultExtractionLimits by default; they should match the limits of the Extractor the packages are extracted by.
+func (s *ServerDownloader) SetSpoolLimits(limits ExtractionLimits) {
+	s.spoolLimits = limits
+}
+
+// SetTenant makes the ServerDownloader request the tenant's configuration packages, by sending the tenant as the
+// 'tenant' query parameter.
+func (s *ServerDownloader) SetTenant(tenant string) {
+	s.tenant = tenant
+}
+
+// SetLongPollTimeout makes WatchPublications long-poll the configuration server, with requests held open for up to
+// the timeout, instead of keeping a server-sent events stream open; i.e. when a proxy does not allow streaming.
+func (s *ServerDownloader) SetLongPollTimeout(longPollTimeout time.Duration) {
+	s.longPollTimeout = longPollTimeout
+}
+
+// ResetConditions forgets the 'ETag' and 'Last-Modified' headers of the package's downloads, including the ones
+// of its versions, so its next download does not send a conditional request.
+func (s *ServerDownloader) ResetConditions(host string, stage string, environment string, component string) {
+	packageUrl := s.getPackageUrl(host, stage, environment, component)
+
+	s.validatorsMutex.Lock()
+	defer s.validatorsMutex.Unlock()
+
+	for url := range s.validators {
+		if url == packageUrl || strings.HasPrefix(url, packageUrl+"&") {
+			delete(s.validators, url)
+		}
+	}
+}
+
+// PackageSignature returns the digest and signature sent within the PackageDigestHeader and PackageSignatureHeader
+// headers along with the last downloaded package, whichever its version.
+func (s *ServerDownloader) PackageSignature(host string, stage string, environment string, component string) core.Option[PackageSignature] {
+	s.signaturesMutex.Lock()
+	defer s.signaturesMutex.Unlock()
+
+	signature, exists := s.signatures[s.getPackageUrl(host, stage, environment, component)]
+
+	if !exists {
+		return core.None[PackageSignature]()
+	}
+
+	return core.Some(signature)
+}
+
+// ReportVerification forgets the conditions of a rejected package and records it as a circuit breaker failure.
+// Until one of its packages is verified, the package's downloads are not recorded as circuit breaker successes.
+func (s *ServerDownloader) ReportVerification(host string, stage string, environment string, component string, verified bool) {
+	packageUrl := s.getPackageUrl(host, stage, environment, component)
+
+	s.signaturesMutex.Lock()
+	wasRejected := s.rejectedPackages[packageUrl]
+
+	if verified {
+		delete(s.rejectedPackages, packageUrl)
+	} else {
+		s.rejectedPackages[packageUrl] = true
+	}
+
+	s.signaturesMutex.Unlock()
+
+	if !verified {
+		s.ResetConditions(host, stage, environment, component)
+	}
+
+	if s.circuitBreaker == nil {
+		return
+	}
+
+	if !verified {
+		s.recordCircuitBreakerResult(true)
+	} else if wasRejected {
+		s.circuitBreaker.RecordSuccess()
+	}
+}
+
+// Download downloads the configuration package.
+func (s *ServerDownloader) Download(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
+	return download(s, s.getPackageUrl(host, stage, environment, component), false, s.readPackage)
+}
+
+// DownloadIfModified works like Download, but sends a conditional request based on the 'ETag' and 'Last-Modified'
+// headers of the package's last conditional download, so a 'not_modified' error is returned when it has not changed.
+func (s *ServerDownloader) DownloadIfModified(host string, stage string, environment string, component string) core.Result[[]byte, core.Error] {
+	return download(s, s.getPackageUrl(host, stage, environment, component), true, s.readPackage)
+}
+
+// DownloadStream works like Download, but spools the package into a temporary file instead of holding it in memory.
+func (s *ServerDownloader) DownloadStream(host string, stage string, environment string, component string) core.Result[*SpooledPackage, core.Error] {
+	return download(s, s.getPackageUrl(host, stage, environment, component), false, s.spoolPackage)
+}
+
+// DownloadStreamIfModified works like DownloadIfModified, but spools the package like DownloadStream does.
+func (s *ServerDownloader) DownloadStreamIfModified(host string, stage string, environment string, component string) core.Result[*SpooledPackage, core.Error] {
+	return download(s, s.getPackageUrl(host, stage, environment, component), true, s.spoolPackage)
+}
+
+// ListVersions lists the versions of the configuration package known by the configuration server, from the latest
+// to the oldest.
+func (s *ServerDownloader) ListVersions(host string, stage string, environment string, component string) core.Result[[]PackageVersion, core.Error] {
+	return download(s, s.withTenant(getVersionsUrl(host, stage, environment, component)), false, s.readVersions)
+}
+
+// DownloadVersion downloads the specified version of the configuration package; an empty version means the latest one.
+func (s *ServerDownloader) DownloadVersion(host string, stage string, environment string, component string, version string) core.Result[VersionedPackage, core.Error] {
+	return download(s, s.getVersionUrl(host, stage, environment, component, version), false, s.readVersionedPackage)
+}
+
+// DownloadVersionIfModified works like DownloadVersion, but returns a 'not_modified' error when the version has not
+// changed since its last conditional download, like DownloadIfModified does.
+func (s *ServerDownloader) DownloadVersionIfModified(host string, stage string, environment string, component string, version string) core.Result[VersionedPackage, core.Error] {
+	return download(s, s.getVersionUrl(host, stage, environment, component, version), true, s.readVersionedPackage)
+}
+
+// WatchPublications keeps a server-sent events stream open with the configuration server and calls onPublished
+// with every PackagePublishedEvent, starting with the latest version. It returns once the context is done, or with
+// an error once the stream is closed or cannot be opened. With a long-poll timeout, see SetLongPollTimeout, the
+// server is long-polled instead, until the context is done or a request fails.
+func (s *ServerDownloader) WatchPublications(ctx context.Context, host string, stage string, environment string, component string, onPublished func(PackageVersion)) core.Result[core.Empty, core.Error] {
+	if s.longPollTimeout > 0 {
+		return s.pollPublications(ctx, host, stage, environment, component, onPublished)
+	}
+
+	eventsUrl := s.withTenant(getEventsUrl(host, stage, environment, component))
+	responseResult := s.openWatchRequest(ctx, eventsUrl, "text/event-stream")
+
+	if responseResult.IsErr() {
+		return core.Err[core.Empty, core.Error](responseResult.UnwrapErr())
+	}
+
+	response := responseResult.Unwrap()
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("received an unexpected status code %d", response.StatusCode)))
+	}
+
+	readResult := s.readEvents(response.Body, onPublished)
+
+	if ctx.Err() != nil {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	return readResult
+}
+
+// pollPublications long-polls the configuration server with the digest of the last version it returned, so each
+// request is answered as soon as a different version is published, or once the long-poll timeout expires.
+func (s *ServerDownloader) pollPublications(ctx context.Context, host string, stage string, environment string, component string, onPublished func(PackageVersion)) core.Result[core.Empty, core.Error] {
+	digest := ""
+
+	for {
+		pollUrl := s.withTenant(getPollUrl(host, stage, environment, component, digest, s.longPollTimeout))
+		// The server answers once the timeout expires, so the request only fails if it takes longer than the download timeout on top of it.
+		requestCtx, cancel := context.WithTimeout(ctx, s.longPollTimeout+s.downloadTimeout)
+		versionResult := s.pollPublication(requestCtx, pollUrl)
+		cancel()
+
+		if ctx.Err() != nil {
+			return core.Ok[core.Empty, core.Error](core.Empty{})
+		}
+
+		if versionResult.IsErr() {
+			return core.Err[core.Empty, core.Error](versionResult.UnwrapErr())
+		}
+
+		if version := versionResult.Unwrap(); version.IsSome() {
+			digest = version.Unwrap().Digest
+			onPublished(version.Unwrap())
+		}
+	}
+}
+
+// pollPublication returns the version answered by a single long-poll request, or none once its timeout expired.
+func (s *ServerDownloader) pollPublication(ctx context.Context, pollUrl string) core.Result[core.Option[PackageVersion], core.Error] {
+	responseResult := s.openWatchRequest(ctx, pollUrl, "application/json")
+
+	if responseResult.IsErr() {
+		return core.Err[core.Option[PackageVersion], core.Error](responseResult.UnwrapErr())
+	}
+
+	response := responseResult.Unwrap()
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(response.Body)
+
+	if response.StatusCode == http.StatusNoContent {
+		return core.Ok[core.Option[PackageVersion], core.Error](core.None[PackageVersion]())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return core.Err[core.Option[PackageVersion], core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("received an unexpected status code %d", response.StatusCode)))
+	}
+
+	var version PackageVersion
+
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxPackageVersionSize)).Decode(&version); err != nil {
+		return core.Err[core.Option[PackageVersion], core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to decode published configuration package: %s", err)))
+	}
+
+	return core.Ok[core.Option[PackageVersion], core.Error](core.Some(version))
+}
+
+// openWatchRequest requests the url with the access token, refreshing it once if the server rejects it. Unlike
+// downloads, the request has no timeout of its own since it's kept open until the context is done.
+func (s *ServerDownloader) openWatchRequest(ctx context.Context, url string, accept string) core.Result[*http.Response, core.Error] {
+	tokenResult := s.tokenSource.Token()
+
+	if tokenResult.IsErr() {
+		return core.Err[*http.Response, core.Error](tokenResult.UnwrapErr())
+	}
+
+	responseResult := s.sendWatchRequest(ctx, url, accept, tokenResult.Unwrap())
+
+	if responseResult.IsErr() || responseResult.Unwrap().StatusCode != http.StatusUnauthorized {
+		return responseResult
+	}
+
+	_ = responseResult.Unwrap().Body.Close()
+	s.logger.Info("Access token has been rejected, refreshing it.", zap.String("url", url))
+	tokenResult = s.tokenSource.Refresh()
+
+	if tokenResult.IsErr() {
+		return core.Err[*http.Response, core.Error](tokenResult.UnwrapErr())
+	}
+
+	return s.sendWatchRequest(ctx, url, accept, tokenResult.Unwrap())
+}
+
+func (s *ServerDownloader) sendWatchRequest(ctx context.Context, url string, accept string, accessToken string) core.Result[*http.Response, core.Error] {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+
+	if err != nil {
+		return core.Err[*http.Response, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to watch config publications: %s", err)))
+	}
+
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("Accept", accept)
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		return core.Err[*http.Response, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to make GET request: %s", err)))
+	}
+
+	return core.Ok[*http.Response, core.Error](response)
+}
+
+// readEvents reads the server-sent events until the stream is closed, ignoring comments and any event other
+// than PackagePublishedEvent.
+func (s *ServerDownloader) readEvents(body io.Reader, onPublished func(PackageVersion)) core.Result[core.Empty, core.Error] {
+	scanner := bufio.NewScanner(body)
+	event := ""
+	dataLines := make([]string, 0)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if len(line) == 0 {
+			if event == PackagePublishedEvent {
+				var version PackageVersion
+
+				if err := json.Unmarshal([]byte(strings.Join(dataLines, "\n")), &version); err != nil {
+					s.logger.Warn("Failed to json unmarshal published configuration package.", zap.String("err", err.Error()))
+				} else {
+					onPublished(version)
+				}
+			}
+
+			event = ""
+			dataLines = dataLines[:0]
+
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			dataLines = append(dataLines, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to read config publications: %s", err)))
+	}
+
+	return core.Err[core.Empty, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "config publications' stream has been closed"))
+}
+
+func (s *ServerDownloader) getPackageUrl(host string, stage string, environment string, component string) string {
+	return s.withTenant(fmt.Sprintf("%s/config?stage=%s&environment=%s&component=%s", host, stage, environment, component))
+}
+
+func (s *ServerDownloader) getVersionUrl(host string, stage string, environment string, component string, version string) string {
+	packageUrl := s.getPackageUrl(host, stage, environment, component)
+
+	if len(version) > 0 {
+		packageUrl += "&version=" + url.QueryEscape(version)
+	}
+
+	return packageUrl
+}
+
+func (s *ServerDownloader) withTenant(requestUrl string) string {
+	if len(s.tenant) == 0 {
+		return requestUrl
+	}
+
+	return requestUrl + "&tenant=" + url.QueryEscape(s.tenant)
+}
+
+func getVersionsUrl(host string, stage string, environment string, component string) string {
+	return fmt.Sprintf("%s/config/versions?stage=%s&environment=%s&component=%s", host, stage, environment, component)
+}
+
+func getPollUrl(host string, stage string, environment string, component string, digest string, timeout time.Duration) string {
+	// The server's timeout is in whole seconds, so it's rounded up.
+	seconds := int((timeout + time.Second - 1) / time.Second)
+
+	return fmt.Sprintf("%s/config/poll?stage=%s&environment=%s&component=%s&digest=%s&timeout=%d", host, stage, environment, component, url.QueryEscape(digest), seconds)
+}
+
+func getEventsUrl(host string, stage string, environment string, component string) string {
+	return fmt.Sprintf("%s/config/events?stage=%s&environment=%s&component=%s", host, stage, environment, component)
+}
+
+// download requests the package from the url, retrying transient failures, and reads the successful response's
+// body through readPackage. Conditional downloads send the headers of the url's last conditional download.
+func download[T any](s *ServerDownloader, url string, conditional bool, readPackage func(url string, response *http.Response) downloadAttempt[T]) core.Result[T, core.Error] {
+	if s.circuitBreaker != nil && !s.circuitBreaker.Allow() {
+		s.logger.Warn("Configuration download short-circuited.", zap.String("url", url))
+
+		return core.Err[T, core.Error](*core.NewError(core.CircuitOpen, fmt.Sprintf("too many failures downloading from '%s', circuit is open", url)))
+	}
+
+	tokenResult := s.tokenSource.Token()
+	refreshedToken := false
+
+	for attempt := 1; ; attempt++ {
+		if tokenResult.IsErr() {
+			if s.circuitBreaker != nil {
+				s.circuitBreaker.RecordFailure()
+			}
+
+			return core.Err[T, core.Error](tokenResult.UnwrapErr())
+		}
+
+		downloadAttempt := downloadOnce(s, url, conditional, tokenResult.Unwrap(), readPackage)
+
+		if downloadAttempt.unauthorized && !refreshedToken {
+			s.logger.Info("Access token has been rejected, refreshing it.", zap.String("url", url))
+			tokenResult = s.tokenSource.Refresh()
+			refreshedToken = true
+
+			// The refreshed access token's request does not count as another attempt.
+			attempt--
+
+			continue
+		}
+
+		if downloadAttempt.result.IsOk() {
+			// A rejected package's success is recorded once it's verified, see ReportVerification. Meanwhile, its
+			// half open trial is released, since nothing may ever report its verification.
+			if s.circuitBreaker != nil && !s.isRejected(url) {
+				s.circuitBreaker.RecordSuccess()
+			} else if s.circuitBreaker != nil {
+				s.circuitBreaker.Release()
+			}
+
+			return downloadAttempt.result
+		}
+
+		if !downloadAttempt.retryable || !s.retryPolicy.ShouldRetry(attempt) {
+			if s.circuitBreaker != nil {
+				s.recordCircuitBreakerResult(downloadAttempt.retryable)
+			}
+
+			return downloadAttempt.result
+		}
+
+		delay := s.retryPolicy.Delay(attempt)
+
+		if downloadAttempt.retryAfter > s.maxRetryAfter() {
+			s.logger.Warn("Configuration server asked to retry too late, giving up.",
+				zap.String("url", url),
+				zap.Duration("retryAfter", downloadAttempt.retryAfter),
+				zap.Duration("maxRetryAfter", s.maxRetryAfter()))
+
+			if s.circuitBreaker != nil {
+				s.recordCircuitBreakerResult(downloadAttempt.retryable)
+			}
+
+			return downloadAttempt.result
+		}
+
+		if downloadAttempt.retryAfter > delay {
+			delay = downloadAttempt.retryAfter
+		}
+
+		s.logger.Warn("Retrying configuration download.",
+			zap.String("url", url),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.String("err", downloadAttempt.result.UnwrapErr().Message))
+
+		time.Sleep(delay)
+	}
+}
+
+// maxRetryAfter is the longest 'Retry-After' the ServerDownloader waits for: the retry policy's MaxDelay, or
+// defaultMaxRetryAfter when the policy's delay is not capped. Longer ones fail the download right away instead of
+// blocking the caller.
+func (s *ServerDownloader) maxRetryAfter() time.Duration {
+	if s.retryPolicy.MaxDelay > 0 {
+		return s.retryPolicy.MaxDelay
+	}
+
+	return defaultMaxRetryAfter
+}
+
+// recordCircuitBreakerResult only counts transient failures, since the rest are not a sign of an unhealthy server.
+func (s *ServerDownloader) recordCircuitBreakerResult(retryable bool) {
+	if retryable {
+		s.circuitBreaker.RecordFailure()
+
+		if s.circuitBreaker.State() == core.CircuitOpened {
+			s.logger.Warn("Configuration download circuit opened.")
+		}
+	} else {
+		s.circuitBreaker.RecordSuccess()
+	}
+}
 
+func downloadOnce[T any](s *ServerDownloader, url string, conditional bool, accessToken string, readPackage func(url string, response *http.Response) downloadAttempt[T]) downloadAttempt[T] {
 	ctx, cancel := context.WithTimeout(context.Background(), s.downloadTimeout)
 	defer cancel()
 
-	request, err := http.NewRequest("GET", url, nil)
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 
 	if err != nil {
-		return core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to get config from server: %s", err)))
+		return downloadAttempt[T]{result: core.Err[T, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to get config from server: %s", err)))}
 	}
 
-	request.WithContext(ctx)
-	request.Header.Set("Authorization", "Bearer "+s.accessToken)
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+
+	if conditional {
+		s.setConditionalHeaders(url, request)
+	}
 
 	client := http.Client{
 		Timeout: s.downloadTimeout,
@@ -48,7 +569,10 @@ func (s ServerDownloader) Download(host string, stage string, environment string
 	response, err := client.Do(request)
 
 	if err != nil {
-		return core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to make GET request: %s", err)))
+		return downloadAttempt[T]{
+			result:    core.Err[T, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to make GET request: %s", err))),
+			retryable: true,
+		}
 	}
 
 	defer func(Body io.ReadCloser) {
@@ -58,15 +582,175 @@ func (s ServerDownloader) Download(host string, stage string, environment string
 		}
 	}(response.Body)
 
+	if response.StatusCode == http.StatusNotModified {
+		return downloadAttempt[T]{result: core.Err[T, core.Error](*core.NewError(core.NotModified, "configuration package has not been modified"))}
+	}
+
 	if response.StatusCode != http.StatusOK {
-		return core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("received an unexpected status code %d", response.StatusCode)))
+		return downloadAttempt[T]{
+			result:       core.Err[T, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("received an unexpected status code %d", response.StatusCode))),
+			retryable:    isRetryableStatusCode(response.StatusCode),
+			retryAfter:   parseRetryAfter(response.Header.Get("Retry-After")),
+			unauthorized: response.StatusCode == http.StatusUnauthorized,
+		}
 	}
 
-	body, err := io.ReadAll(response.Body)
+	attempt := readPackage(url, response)
 
-	if err != nil {
-		return core.Err[[]byte, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to read response's body: %s", err)))
+	if conditional && attempt.result.IsOk() {
+		s.storeValidators(url, response)
+	}
+
+	return attempt
+}
+
+func (s *ServerDownloader) readPackage(url string, response *http.Response) downloadAttempt[[]byte] {
+	bodyResult := readAllWithinLimit(response.Body, s.spoolLimits.maxPackageSize())
+
+	if bodyResult.IsErr() {
+		return downloadAttempt[[]byte]{
+			result:    bodyResult,
+			retryable: bodyResult.UnwrapErr().ErrorKind != core.PackageLimitExceeded,
+		}
+	}
+
+	s.storeSignature(url, response)
+
+	return downloadAttempt[[]byte]{result: bodyResult}
+}
+
+func (s *ServerDownloader) readVersionedPackage(url string, response *http.Response) downloadAttempt[VersionedPackage] {
+	attempt := s.readPackage(url, response)
+
+	if attempt.result.IsErr() {
+		return downloadAttempt[VersionedPackage]{result: core.Err[VersionedPackage, core.Error](attempt.result.UnwrapErr()), retryable: attempt.retryable}
+	}
+
+	versionedPackage := VersionedPackage{
+		Version: response.Header.Get(PackageVersionHeader),
+		Data:    attempt.result.Unwrap(),
+	}
+
+	return downloadAttempt[VersionedPackage]{result: core.Ok[VersionedPackage, core.Error](versionedPackage)}
+}
+
+func (s *ServerDownloader) readVersions(url string, response *http.Response) downloadAttempt[[]PackageVersion] {
+	bodyResult := readAllWithinLimit(response.Body, maxVersionsSize)
+
+	if bodyResult.IsErr() {
+		return downloadAttempt[[]PackageVersion]{result: core.Err[[]PackageVersion, core.Error](bodyResult.UnwrapErr())}
+	}
+
+	var versions []PackageVersion
+
+	if err := json.Unmarshal(bodyResult.Unwrap(), &versions); err != nil {
+		return downloadAttempt[[]PackageVersion]{result: core.Err[[]PackageVersion, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to decode package versions: %s", err)))}
+	}
+
+	return downloadAttempt[[]PackageVersion]{result: core.Ok[[]PackageVersion, core.Error](versions)}
+}
+
+func (s *ServerDownloader) spoolPackage(url string, response *http.Response) downloadAttempt[*SpooledPackage] {
+	spoolResult := SpoolPackageWithLimits(response.Body, s.spoolDirectory, s.spoolLimits)
+
+	if spoolResult.IsErr() && spoolResult.UnwrapErr().ErrorKind == core.PackageLimitExceeded {
+		return downloadAttempt[*SpooledPackage]{result: spoolResult}
+	}
+
+	if spoolResult.IsErr() {
+		return downloadAttempt[*SpooledPackage]{
+			result:    core.Err[*SpooledPackage, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to read response's body: %s", spoolResult.UnwrapErr().Message))),
+			retryable: true,
+		}
+	}
+
+	spooledPackage := spoolResult.Unwrap()
+	spooledPackage.version = response.Header.Get(PackageVersionHeader)
+
+	s.storeSignature(url, response)
+
+	return downloadAttempt[*SpooledPackage]{result: core.Ok[*SpooledPackage, core.Error](spooledPackage)}
+}
+
+// storeSignature keeps the digest and signature sent along with the package downloaded from the url, under the url
+// of the package's latest version, so PackageSignature finds them whichever version has been downloaded.
+func (s *ServerDownloader) storeSignature(url string, response *http.Response) {
+	s.signaturesMutex.Lock()
+	defer s.signaturesMutex.Unlock()
+
+	s.signatures[getPackageUrlOf(url)] = PackageSignature{
+		Digest:    response.Header.Get(PackageDigestHeader),
+		Signature: response.Header.Get(PackageSignatureHeader),
+	}
+}
+
+func (s *ServerDownloader) isRejected(url string) bool {
+	s.signaturesMutex.Lock()
+	defer s.signaturesMutex.Unlock()
+
+	return s.rejectedPackages[getPackageUrlOf(url)]
+}
+
+// getPackageUrlOf removes the version from a version's url, see getVersionUrl, which leaves the package's url.
+func getPackageUrlOf(versionUrl string) string {
+	packageUrl, _, _ := strings.Cut(versionUrl, "&version=")
+
+	return packageUrl
+}
+
+func (s *ServerDownloader) setConditionalHeaders(url string, request *http.Request) {
+	s.validatorsMutex.Lock()
+	defer s.validatorsMutex.Unlock()
+
+	validators, exists := s.validators[url]
+
+	if !exists {
+		return
+	}
+
+	if len(validators.etag) > 0 {
+		request.Header.Set("If-None-Match", validators.etag)
+	}
+
+	if len(validators.lastModified) > 0 {
+		request.Header.Set("If-Modified-Since", validators.lastModified)
+	}
+}
+
+func (s *ServerDownloader) storeValidators(url string, response *http.Response) {
+	validators := packageValidators{
+		etag:         response.Header.Get("ETag"),
+		lastModified: response.Header.Get("Last-Modified"),
+	}
+
+	s.validatorsMutex.Lock()
+	defer s.validatorsMutex.Unlock()
+
+	if len(validators.etag) == 0 && len(validators.lastModified) == 0 {
+		delete(s.validators, url)
+		return
+	}
+
+	s.validators[url] = validators
+}
+
+func isRetryableStatusCode(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || (statusCode >= 500 && statusCode != http.StatusNotImplemented)
+}
+
+// parseRetryAfter reads the 'Retry-After' header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(header string) time.Duration {
+	if len(header) == 0 {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date)
 	}
 
-	return core.Ok[[]byte, core.Error](body)
+	return 0
 }
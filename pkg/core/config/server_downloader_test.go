@@ -1,15 +1,22 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/simpleg-eu/cuplan_core/pkg/core"
 	"github.com/simpleg-eu/cuplan_core/pkg/core/secret"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -69,3 +76,434 @@ func (s *ServerDownloaderTestSuite) TestServerDownloader_Download_Fail_NonExisti
 	assert.False(s.T(), result.IsOk())
 	assert.Equal(s.T(), core.ConfigurationRetrievalFailure, result.UnwrapErr().ErrorKind)
 }
+
+type LocalServerDownloaderTestSuite struct {
+	suite.Suite
+	Requests   atomic.Int32
+	Responses  []int
+	RetryAfter string
+	Server     *httptest.Server
+	Downloader *ServerDownloader
+}
+
+func TestLocalServerDownloaderTestSuite(t *testing.T) {
+	suite.Run(t, new(LocalServerDownloaderTestSuite))
+}
+
+func (l *LocalServerDownloaderTestSuite) SetupTest() {
+	l.Requests.Store(0)
+	l.Responses = []int{http.StatusOK}
+	l.RetryAfter = "1"
+	l.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := int(l.Requests.Add(1)) - 1
+		statusCode := l.Responses[len(l.Responses)-1]
+
+		if request < len(l.Responses) {
+			statusCode = l.Responses[request]
+		}
+
+		if statusCode == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", l.RetryAfter)
+		}
+
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte("package"))
+	}))
+	logger, _ := zap.NewDevelopment()
+	l.Downloader = NewServerDownloader(logger, "token", time.Second)
+}
+
+func (l *LocalServerDownloaderTestSuite) TearDownTest() {
+	l.Server.Close()
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_NoRetryPolicy_SingleRequest() {
+	l.Responses = []int{http.StatusServiceUnavailable, http.StatusOK}
+
+	result := l.Downloader.Download(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), core.ConfigurationRetrievalFailure, result.UnwrapErr().ErrorKind)
+	assert.Equal(l.T(), int32(1), l.Requests.Load())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_TransientFailure_Retries() {
+	l.Responses = []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}
+	l.Downloader.SetRetryPolicy(core.NewRetryPolicy(3, time.Millisecond, 10*time.Millisecond))
+
+	result := l.Downloader.Download(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsOk())
+	assert.Equal(l.T(), []byte("package"), result.Unwrap())
+	assert.Equal(l.T(), int32(3), l.Requests.Load())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_NonTransientFailure_DoesNotRetry() {
+	l.Responses = []int{http.StatusNotFound, http.StatusOK}
+	l.Downloader.SetRetryPolicy(core.NewRetryPolicy(3, time.Millisecond, 10*time.Millisecond))
+
+	result := l.Downloader.Download(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), int32(1), l.Requests.Load())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_TooManyRequests_HonorsRetryAfter() {
+	l.Responses = []int{http.StatusTooManyRequests, http.StatusOK}
+	l.Downloader.SetRetryPolicy(core.NewRetryPolicy(2, time.Millisecond, 2*time.Second))
+	start := time.Now()
+
+	result := l.Downloader.Download(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsOk())
+	assert.True(l.T(), time.Since(start) >= time.Second, "Retry-After header has not been honored.")
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_RetryAfterBeyondMaxDelay_FailsFast() {
+	l.Responses = []int{http.StatusTooManyRequests, http.StatusOK}
+	l.RetryAfter = "86400"
+	l.Downloader.SetRetryPolicy(core.NewRetryPolicy(2, time.Millisecond, 10*time.Millisecond))
+	start := time.Now()
+
+	result := l.Downloader.Download(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), int32(1), l.Requests.Load())
+	assert.True(l.T(), time.Since(start) < time.Second, "Retry-After header has not been capped.")
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_RepeatedFailures_OpensCircuit() {
+	l.Responses = []int{http.StatusInternalServerError}
+	l.Downloader.SetCircuitBreaker(core.NewCircuitBreaker(2, time.Hour))
+
+	_ = l.Downloader.Download(l.Server.URL, stage, environment, component)
+	_ = l.Downloader.Download(l.Server.URL, stage, environment, component)
+	result := l.Downloader.Download(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), core.CircuitOpen, result.UnwrapErr().ErrorKind)
+	assert.Equal(l.T(), int32(2), l.Requests.Load())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestParseRetryAfter_HttpDate_ReturnsDelay() {
+	delay := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+
+	assert.True(l.T(), delay > 58*time.Second && delay <= time.Minute, delay)
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_DownloadIfModified_UnchangedPackage_NotModified() {
+	const etag = "\"v1\""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+
+	firstResult := l.Downloader.DownloadIfModified(server.URL, stage, environment, component)
+	secondResult := l.Downloader.DownloadIfModified(server.URL, stage, environment, component)
+	l.Downloader.ResetConditions(server.URL, stage, environment, component)
+	thirdResult := l.Downloader.DownloadIfModified(server.URL, stage, environment, component)
+
+	assert.True(l.T(), firstResult.IsOk())
+	assert.True(l.T(), secondResult.IsErr())
+	assert.Equal(l.T(), core.NotModified, secondResult.UnwrapErr().ErrorKind)
+	assert.True(l.T(), thirdResult.IsOk())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_UnchangedPackage_ReturnsPackage() {
+	const etag = "\"v1\""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+
+	_ = l.Downloader.DownloadIfModified(server.URL, stage, environment, component)
+	firstResult := l.Downloader.Download(server.URL, stage, environment, component)
+	secondResult := l.Downloader.Download(server.URL, stage, environment, component)
+
+	assert.Equal(l.T(), []byte("package"), firstResult.Unwrap())
+	assert.Equal(l.T(), []byte("package"), secondResult.Unwrap())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_ResetConditions_KeepsOtherPackagesConditions() {
+	const etag = "\"v1\""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+	_ = l.Downloader.DownloadIfModified(server.URL, stage, environment, component)
+	_ = l.Downloader.DownloadIfModified(server.URL, stage, environment, "other")
+
+	l.Downloader.ResetConditions(server.URL, stage, environment, component)
+	resetResult := l.Downloader.DownloadIfModified(server.URL, stage, environment, component)
+	otherResult := l.Downloader.DownloadIfModified(server.URL, stage, environment, "other")
+
+	assert.True(l.T(), resetResult.IsOk())
+	assert.True(l.T(), otherResult.IsErr())
+	assert.Equal(l.T(), core.NotModified, otherResult.UnwrapErr().ErrorKind)
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_DownloadIfModified_LastModified_SendsIfModifiedSince() {
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	var receivedHeader atomic.Value
+	receivedHeader.Store("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeader.Store(r.Header.Get("If-Modified-Since"))
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+
+	_ = l.Downloader.DownloadIfModified(server.URL, stage, environment, component)
+	_ = l.Downloader.DownloadIfModified(server.URL, stage, environment, component)
+
+	assert.Equal(l.T(), lastModified, receivedHeader.Load())
+}
+
+type sequenceTokenSource struct {
+	tokens    []string
+	refreshes int
+}
+
+func (s *sequenceTokenSource) Token() core.Result[string, core.Error] {
+	return core.Ok[string, core.Error](s.tokens[s.refreshes])
+}
+
+func (s *sequenceTokenSource) Refresh() core.Result[string, core.Error] {
+	s.refreshes++
+	return s.Token()
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_RejectedToken_RefreshesAndRetriesOnce() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Requests.Add(1)
+
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+	logger, _ := zap.NewDevelopment()
+	tokenSource := &sequenceTokenSource{tokens: []string{"expired", "fresh"}}
+	downloader := NewServerDownloaderWithTokenSource(logger, tokenSource, time.Second)
+
+	result := downloader.Download(server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsOk())
+	assert.Equal(l.T(), 1, tokenSource.refreshes)
+	assert.Equal(l.T(), int32(2), l.Requests.Load())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_RejectedRefreshedToken_Error() {
+	l.Responses = []int{http.StatusUnauthorized}
+	logger, _ := zap.NewDevelopment()
+	tokenSource := &sequenceTokenSource{tokens: []string{"expired", "revoked", "unused"}}
+	downloader := NewServerDownloaderWithTokenSource(logger, tokenSource, time.Second)
+
+	result := downloader.Download(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), 1, tokenSource.refreshes)
+	assert.Equal(l.T(), int32(2), l.Requests.Load())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_PackageSignature_ReturnsHeadersOfLastDownload() {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(PackageDigestHeader, ComputePackageDigest([]byte("package")))
+		w.Header().Set(PackageSignatureHeader, SignPackage(privateKey, []byte("package")))
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+
+	before := l.Downloader.PackageSignature(server.URL, stage, environment, component)
+	_ = l.Downloader.DownloadVersion(server.URL, stage, environment, component, "1.0.0")
+	after := l.Downloader.PackageSignature(server.URL, stage, environment, component)
+
+	assert.False(l.T(), before.IsSome())
+	assert.True(l.T(), after.IsSome())
+	assert.Equal(l.T(), PackageSignature{
+		Digest:    ComputePackageDigest([]byte("package")),
+		Signature: SignPackage(privateKey, []byte("package")),
+	}, after.Unwrap())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_ReportVerification_RejectedPackages_OpenCircuit() {
+	l.Downloader.SetCircuitBreaker(core.NewCircuitBreaker(2, time.Hour))
+
+	first := l.Downloader.DownloadIfModified(l.Server.URL, stage, environment, component)
+	l.Downloader.ReportVerification(l.Server.URL, stage, environment, component, false)
+	second := l.Downloader.DownloadIfModified(l.Server.URL, stage, environment, component)
+	l.Downloader.ReportVerification(l.Server.URL, stage, environment, component, false)
+	third := l.Downloader.DownloadIfModified(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), first.IsOk())
+	assert.True(l.T(), second.IsOk())
+	assert.True(l.T(), third.IsErr())
+	assert.Equal(l.T(), core.CircuitOpen, third.UnwrapErr().ErrorKind)
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_HalfOpenRejectedPackage_WithoutReport_ReleasesTrial() {
+	l.Downloader.SetCircuitBreaker(core.NewCircuitBreaker(1, time.Millisecond))
+	_ = l.Downloader.DownloadIfModified(l.Server.URL, stage, environment, component)
+	l.Downloader.ReportVerification(l.Server.URL, stage, environment, component, false)
+	time.Sleep(5 * time.Millisecond)
+
+	trial := l.Downloader.Download(l.Server.URL, stage, environment, component)
+	result := l.Downloader.Download(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), trial.IsOk())
+	assert.True(l.T(), result.IsOk())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_DownloadStream_ReturnsSpooledPackage() {
+	spoolDirectory := uuid.New().String()
+	_ = os.MkdirAll(spoolDirectory, os.ModePerm)
+	defer os.RemoveAll(spoolDirectory)
+	l.Downloader.SetSpoolDirectory(spoolDirectory)
+
+	result := l.Downloader.DownloadStream(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsOk())
+	spooledPackage := result.Unwrap()
+	data := spooledPackage.Bytes().Unwrap()
+	spooledFiles, _ := os.ReadDir(spoolDirectory)
+	spooledPackage.Close()
+	remainingFiles, _ := os.ReadDir(spoolDirectory)
+	assert.Equal(l.T(), []byte("package"), data)
+	assert.Equal(l.T(), ComputePackageDigest([]byte("package")), spooledPackage.Checksum())
+	assert.Len(l.T(), spooledFiles, 1)
+	assert.Empty(l.T(), remainingFiles)
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_DownloadStream_OversizedPackage_PackageLimitExceeded() {
+	l.Downloader.SetRetryPolicy(core.NewRetryPolicy(3, time.Millisecond, time.Millisecond))
+	l.Downloader.SetSpoolLimits(ExtractionLimits{MaxFiles: 1, MaxTotalSize: 1})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Requests.Add(1)
+		_, _ = w.Write(make([]byte, 3*maxEntryOverhead))
+	}))
+	defer server.Close()
+
+	result := l.Downloader.DownloadStream(server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+	assert.Equal(l.T(), int32(1), l.Requests.Load())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_OversizedPackage_PackageLimitExceeded() {
+	l.Downloader.SetRetryPolicy(core.NewRetryPolicy(3, time.Millisecond, time.Millisecond))
+	l.Downloader.SetSpoolLimits(ExtractionLimits{MaxFiles: 1, MaxTotalSize: 1})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Requests.Add(1)
+		_, _ = w.Write(make([]byte, 3*maxEntryOverhead))
+	}))
+	defer server.Close()
+
+	result := l.Downloader.Download(server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+	assert.Equal(l.T(), int32(1), l.Requests.Load())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_DownloadVersion_RequestsVersion() {
+	var requestedVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedVersion = r.URL.Query().Get("version")
+		w.Header().Set(PackageVersionHeader, requestedVersion)
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+
+	result := l.Downloader.DownloadVersion(server.URL, stage, environment, component, "1.0.0+build")
+
+	assert.True(l.T(), result.IsOk())
+	assert.Equal(l.T(), "1.0.0+build", requestedVersion)
+	assert.Equal(l.T(), "1.0.0+build", result.Unwrap().Version)
+	assert.Equal(l.T(), []byte("package"), result.Unwrap().Data)
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_DownloadStream_ReportsVersion() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(PackageVersionHeader, "2.0.0")
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+
+	result := l.Downloader.DownloadStream(server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsOk())
+	defer result.Unwrap().Close()
+	assert.Equal(l.T(), "2.0.0", result.Unwrap().Version())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_ListVersions_ReturnsVersions() {
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		_, _ = w.Write([]byte(`[{"version":"2.0.0","digest":"abc","size":7},{"version":"1.0.0","digest":"def","size":8}]`))
+	}))
+	defer server.Close()
+
+	result := l.Downloader.ListVersions(server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsOk())
+	assert.Equal(l.T(), "/config/versions", requestedPath)
+	assert.Equal(l.T(), []PackageVersion{{Version: "2.0.0", Digest: "abc", Size: 7}, {Version: "1.0.0", Digest: "def", Size: 8}}, result.Unwrap())
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_ListVersions_TooBig_PackageLimitExceeded() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"version":"` + strings.Repeat("1", maxVersionsSize) + `"}]`))
+	}))
+	defer server.Close()
+
+	result := l.Downloader.ListVersions(server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_ListVersions_InvalidResponse_SerializationFailure() {
+	result := l.Downloader.ListVersions(l.Server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsErr())
+	assert.Equal(l.T(), core.SerializationFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (l *LocalServerDownloaderTestSuite) TestServerDownloader_Download_Tenant_SendsTenant() {
+	var requestedTenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedTenant = r.URL.Query().Get("tenant")
+		_, _ = w.Write([]byte("package"))
+	}))
+	defer server.Close()
+	l.Downloader.SetTenant("acme corp")
+
+	result := l.Downloader.Download(server.URL, stage, environment, component)
+
+	assert.True(l.T(), result.IsOk())
+	assert.Equal(l.T(), "acme corp", requestedTenant)
+}
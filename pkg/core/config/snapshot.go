@@ -0,0 +1,163 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const snapshotPackageFileName = "package.bin"
+const snapshotMetadataFileName = "metadata.json"
+
+// Snapshot is the last configuration package which was successfully downloaded.
+type Snapshot struct {
+	PackageData  []byte
+	Checksum     string
+	DownloadedAt time.Time
+}
+
+// SnapshotStore
+// Interface which provides a facility to persist the last known good configuration package.
+type SnapshotStore interface {
+	// Save
+	// Persists the configuration package as the last known good one.
+	Save(packageData []byte) core.Result[core.Empty, core.Error]
+
+	// Load
+	// Loads the last known good configuration package, verifying its checksum.
+	Load() core.Result[Snapshot, core.Error]
+}
+
+// StreamSnapshotStore
+// Interface implemented by the SnapshotStores which can persist a configuration package without holding it in memory.
+type StreamSnapshotStore interface {
+	SnapshotStore
+
+	// SaveStream
+	// Persists the configuration package read from the reader as the last known good one.
+	SaveStream(reader io.Reader) core.Result[core.Empty, core.Error]
+}
+
+type snapshotMetadata struct {
+	Checksum     string    `json:"checksum"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// FileSnapshotStore persists the last known good configuration package within a directory.
+type FileSnapshotStore struct {
+	directory string
+}
+
+// NewFileSnapshotStore creates an instance of FileSnapshotStore which persists the snapshot within the specified directory.
+// The directory must be durable, so it must not be the Client's working path.
+func NewFileSnapshotStore(directory string) *FileSnapshotStore {
+	store := new(FileSnapshotStore)
+	store.directory = directory
+
+	return store
+}
+
+func (f *FileSnapshotStore) Save(packageData []byte) core.Result[core.Empty, core.Error] {
+	return f.SaveStream(bytes.NewReader(packageData))
+}
+
+func (f *FileSnapshotStore) SaveStream(reader io.Reader) core.Result[core.Empty, core.Error] {
+	err := os.MkdirAll(f.directory, os.ModePerm)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to create snapshot directory '%s': %s", f.directory, err)))
+	}
+
+	// The package is written before the metadata, so a partially written snapshot never passes the checksum verification.
+	hash := sha256.New()
+	result := writeStreamAtomically(filepath.Join(f.directory, snapshotPackageFileName), io.TeeReader(reader, hash))
+
+	if result.IsErr() {
+		return result
+	}
+
+	metadata, err := json.Marshal(snapshotMetadata{Checksum: hex.EncodeToString(hash.Sum(nil)), DownloadedAt: time.Now().UTC()})
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to serialize snapshot metadata: %s", err)))
+	}
+
+	return writeFileAtomically(filepath.Join(f.directory, snapshotMetadataFileName), metadata)
+}
+
+func (f *FileSnapshotStore) Load() core.Result[Snapshot, core.Error] {
+	metadataContent, err := os.ReadFile(filepath.Join(f.directory, snapshotMetadataFileName))
+
+	if os.IsNotExist(err) {
+		return core.Err[Snapshot, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("there is no snapshot within '%s'", f.directory)))
+	}
+
+	if err != nil {
+		return core.Err[Snapshot, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read snapshot metadata: %s", err)))
+	}
+
+	var metadata snapshotMetadata
+
+	if err := json.Unmarshal(metadataContent, &metadata); err != nil {
+		return core.Err[Snapshot, core.Error](*core.NewError(core.SerializationFailure, fmt.Sprintf("failed to deserialize snapshot metadata: %s", err)))
+	}
+
+	packageData, err := os.ReadFile(filepath.Join(f.directory, snapshotPackageFileName))
+
+	if err != nil {
+		return core.Err[Snapshot, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read snapshot package: %s", err)))
+	}
+
+	if computeChecksum(packageData) != metadata.Checksum {
+		return core.Err[Snapshot, core.Error](*core.NewError(core.InvalidCache, "snapshot package does not match its checksum"))
+	}
+
+	return core.Ok[Snapshot, core.Error](Snapshot{PackageData: packageData, Checksum: metadata.Checksum, DownloadedAt: metadata.DownloadedAt})
+}
+
+func computeChecksum(data []byte) string {
+	checksum := sha256.Sum256(data)
+
+	return hex.EncodeToString(checksum[:])
+}
+
+func writeFileAtomically(filePath string, data []byte) core.Result[core.Empty, core.Error] {
+	return writeStreamAtomically(filePath, bytes.NewReader(data))
+}
+
+func writeStreamAtomically(filePath string, reader io.Reader) core.Result[core.Empty, core.Error] {
+	temporaryFilePath := filePath + ".tmp"
+	file, err := os.OpenFile(temporaryFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to write file '%s': %s", temporaryFilePath, err)))
+	}
+
+	_, err = io.Copy(file, reader)
+	closeErr := file.Close()
+
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(temporaryFilePath)
+
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to write file '%s': %s", temporaryFilePath, err)))
+	}
+
+	if err := os.Rename(temporaryFilePath, filePath); err != nil {
+		_ = os.Remove(temporaryFilePath)
+
+		return core.Err[core.Empty, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to replace file '%s': %s", filePath, err)))
+	}
+
+	return core.Ok[core.Empty, core.Error](core.Empty{})
+}
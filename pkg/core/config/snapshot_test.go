@@ -0,0 +1,59 @@
+package config
+
+import (
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type FileSnapshotStoreTestSuite struct {
+	suite.Suite
+	Directory string
+	Store     *FileSnapshotStore
+}
+
+func TestFileSnapshotStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(FileSnapshotStoreTestSuite))
+}
+
+func (f *FileSnapshotStoreTestSuite) SetupTest() {
+	f.Directory = uuid.New().String()
+	f.Store = NewFileSnapshotStore(f.Directory)
+}
+
+func (f *FileSnapshotStoreTestSuite) TearDownTest() {
+	_ = os.RemoveAll(f.Directory)
+}
+
+func (f *FileSnapshotStoreTestSuite) TestFileSnapshotStore_Load_ReturnsSavedPackage() {
+	packageData := []byte("package")
+	f.Store.Save(packageData)
+
+	result := f.Store.Load()
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), packageData, result.Unwrap().PackageData)
+	assert.Equal(f.T(), computeChecksum(packageData), result.Unwrap().Checksum)
+	assert.False(f.T(), result.Unwrap().DownloadedAt.IsZero())
+}
+
+func (f *FileSnapshotStoreTestSuite) TestFileSnapshotStore_Load_NoSnapshot_NotFound() {
+	result := f.Store.Load()
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (f *FileSnapshotStoreTestSuite) TestFileSnapshotStore_Load_TamperedPackage_InvalidCache() {
+	f.Store.Save([]byte("package"))
+	_ = os.WriteFile(filepath.Join(f.Directory, snapshotPackageFileName), []byte("tampered"), 0600)
+
+	result := f.Store.Load()
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.InvalidCache, result.UnwrapErr().ErrorKind)
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"io"
+	"os"
+)
+
+const spooledPackageFilePattern = "cuplan-config-package-*"
+
+// SpooledPackage is a configuration package which has been spooled into a temporary file, so it can be processed
+// without holding it in memory. The temporary file is deleted by Close.
+type SpooledPackage struct {
+	file   *os.File
+	size   int64
+	digest []byte
+	// version is the package's version, when the Downloader knows it.
+	version string
+}
+
+// SpoolPackage copies the reader's content into a temporary file created within the directory, computing its
+// SHA-256 digest on the way. An empty directory means the operating system's temporary directory.
+// It applies the DefaultExtractionLimits, see SpoolPackageWithLimits.
+func SpoolPackage(reader io.Reader, directory string) core.Result[*SpooledPackage, core.Error] {
+	return SpoolPackageWithLimits(reader, directory, DefaultExtractionLimits())
+}
+
+// SpoolPackageWithLimits works like SpoolPackage, but stops spooling with a 'package_limit_exceeded' error as soon
+// as the package is bigger than any package which could be extracted within the limits.
+func SpoolPackageWithLimits(reader io.Reader, directory string, limits ExtractionLimits) core.Result[*SpooledPackage, core.Error] {
+	file, err := os.CreateTemp(directory, spooledPackageFilePattern)
+
+	if err != nil {
+		return core.Err[*SpooledPackage, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to create spool file: %s", err)))
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), &limitedReader{reader: reader, remaining: limits.maxPackageSize()})
+
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+
+		if errors.Is(err, errLimitExceeded) {
+			return core.Err[*SpooledPackage, core.Error](*core.NewError(core.PackageLimitExceeded, fmt.Sprintf("package is bigger than %d bytes", limits.maxPackageSize())))
+		}
+
+		return core.Err[*SpooledPackage, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to spool package data: %s", err)))
+	}
+
+	spooledPackage := new(SpooledPackage)
+	spooledPackage.file = file
+	spooledPackage.size = size
+	spooledPackage.digest = hash.Sum(nil)
+
+	return core.Ok[*SpooledPackage, core.Error](spooledPackage)
+}
+
+// ReadAt reads the spooled package at the specified offset.
+func (s *SpooledPackage) ReadAt(p []byte, offset int64) (int, error) {
+	return s.file.ReadAt(p, offset)
+}
+
+// Reader returns a reader of the whole spooled package.
+func (s *SpooledPackage) Reader() io.Reader {
+	return io.NewSectionReader(s.file, 0, s.size)
+}
+
+// Size returns the spooled package's size in bytes.
+func (s *SpooledPackage) Size() int64 {
+	return s.size
+}
+
+// Digest returns the spooled package's SHA-256 digest.
+func (s *SpooledPackage) Digest() []byte {
+	return s.digest
+}
+
+// Checksum returns the spooled package's SHA-256 digest encoded as hexadecimal.
+func (s *SpooledPackage) Checksum() string {
+	return hex.EncodeToString(s.digest)
+}
+
+// Version returns the spooled package's version, or an empty string if it's unknown.
+func (s *SpooledPackage) Version() string {
+	return s.version
+}
+
+// Bytes reads the whole spooled package into memory.
+func (s *SpooledPackage) Bytes() core.Result[[]byte, core.Error] {
+	data, err := io.ReadAll(s.Reader())
+
+	if err != nil {
+		return core.Err[[]byte, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read spooled package: %s", err)))
+	}
+
+	return core.Ok[[]byte, core.Error](data)
+}
+
+// Close deletes the spooled package's temporary file.
+func (s *SpooledPackage) Close() {
+	_ = s.file.Close()
+	_ = os.Remove(s.file.Name())
+}
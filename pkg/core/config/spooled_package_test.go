@@ -0,0 +1,72 @@
+package config
+
+import (
+	"bytes"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"os"
+	"testing"
+)
+
+type SpooledPackageTestSuite struct {
+	suite.Suite
+}
+
+func TestSpooledPackageTestSuite(t *testing.T) {
+	suite.Run(t, new(SpooledPackageTestSuite))
+}
+
+func (s *SpooledPackageTestSuite) TestSpoolPackage_ReturnsContentSizeAndChecksum() {
+	packageData := bytes.Repeat([]byte("package"), 1024)
+
+	result := SpoolPackage(bytes.NewReader(packageData), "")
+
+	assert.True(s.T(), result.IsOk())
+	spooledPackage := result.Unwrap()
+	defer spooledPackage.Close()
+	content, err := io.ReadAll(spooledPackage.Reader())
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), packageData, content)
+	assert.Equal(s.T(), int64(len(packageData)), spooledPackage.Size())
+	assert.Equal(s.T(), ComputePackageDigest(packageData), spooledPackage.Checksum())
+}
+
+func (s *SpooledPackageTestSuite) TestSpooledPackage_Close_RemovesTemporaryFile() {
+	spooledPackage := SpoolPackage(bytes.NewReader([]byte("package")), "").Unwrap()
+	fileName := spooledPackage.file.Name()
+
+	spooledPackage.Close()
+
+	_, err := os.Stat(fileName)
+	assert.True(s.T(), os.IsNotExist(err))
+}
+
+func (s *SpooledPackageTestSuite) TestSpoolPackage_NonExistingDirectory_IOFailure() {
+	result := SpoolPackage(bytes.NewReader([]byte("package")), "non-existing-directory/sub-directory")
+
+	assert.True(s.T(), result.IsErr())
+	assert.Equal(s.T(), core.IOFailure, result.UnwrapErr().ErrorKind)
+}
+
+func (s *SpooledPackageTestSuite) TestSpoolPackageWithLimits_OversizedPackage_PackageLimitExceeded() {
+	directory := s.T().TempDir()
+	limits := ExtractionLimits{MaxFiles: 1, MaxTotalSize: 1024}
+
+	result := SpoolPackageWithLimits(bytes.NewReader(make([]byte, limits.maxPackageSize()+1)), directory, limits)
+
+	spooledFiles, _ := os.ReadDir(directory)
+	assert.True(s.T(), result.IsErr())
+	assert.Equal(s.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+	assert.Empty(s.T(), spooledFiles)
+}
+
+func (s *SpooledPackageTestSuite) TestSpoolPackageWithLimits_PackageWithinLimits_Ok() {
+	limits := ExtractionLimits{MaxFiles: 1, MaxTotalSize: 1024}
+
+	result := SpoolPackageWithLimits(bytes.NewReader(make([]byte, limits.maxPackageSize())), "", limits)
+
+	assert.True(s.T(), result.IsOk())
+	result.Unwrap().Close()
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"go.uber.org/zap"
+	"io"
+	"os"
+)
+
+// TarCompression is the compression applied on top of a tar configuration package.
+type TarCompression string
+
+const (
+	NoCompression   TarCompression = "none"
+	GzipCompression TarCompression = "gzip"
+	ZstdCompression TarCompression = "zstd"
+)
+
+// TarExtractor extracts tar configuration packages, optionally compressed with gzip or zstd. It applies the same
+// rules as the ZipExtractor: unsafe entries are rejected with an 'unsafe_package_entry' error, while packages
+// exceeding the ExtractionLimits are rejected with a 'package_limit_exceeded' error. Since tar entries are not
+// compressed individually, the compression ratio is checked against the whole package.
+type TarExtractor struct {
+	logger      *zap.Logger
+	compression TarCompression
+	limits      ExtractionLimits
+}
+
+// NewTarExtractor creates a TarExtractor which applies the DefaultExtractionLimits.
+func NewTarExtractor(logger *zap.Logger, compression TarCompression) *TarExtractor {
+	return NewTarExtractorWithLimits(logger, compression, DefaultExtractionLimits())
+}
+
+// NewTarExtractorWithLimits creates a TarExtractor which applies the specified limits.
+func NewTarExtractorWithLimits(logger *zap.Logger, compression TarCompression, limits ExtractionLimits) *TarExtractor {
+	extractor := new(TarExtractor)
+	extractor.logger = logger
+	extractor.compression = compression
+	extractor.limits = limits
+
+	return extractor
+}
+
+// Extract extracts the package into a staging directory which atomically replaces the targetPath once the
+// extraction has succeeded. The replaced content is kept, so it can be restored through Rollback.
+func (t TarExtractor) Extract(packageData []byte, targetPath string) core.Result[core.Empty, core.Error] {
+	return extractAtomically(targetPath, func(stagingPath string) core.Result[core.Empty, core.Error] {
+		return t.extract(bytes.NewReader(packageData), int64(len(packageData)), stagingPath)
+	})
+}
+
+// ExtractStream works like Extract, but reads the package from the reader instead of memory.
+func (t TarExtractor) ExtractStream(reader io.ReaderAt, size int64, targetPath string) core.Result[core.Empty, core.Error] {
+	return extractAtomically(targetPath, func(stagingPath string) core.Result[core.Empty, core.Error] {
+		return t.extract(io.NewSectionReader(reader, 0, size), size, stagingPath)
+	})
+}
+
+// Rollback restores the content which was replaced by the last extraction into the targetPath.
+func (t TarExtractor) Rollback(targetPath string) core.Result[core.Empty, core.Error] {
+	return RollbackExtraction(targetPath)
+}
+
+func (t TarExtractor) extract(packageReader io.Reader, size int64, targetPath string) core.Result[core.Empty, core.Error] {
+	err := os.MkdirAll(targetPath, os.ModePerm)
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create target directory: %s", err)))
+	}
+
+	readerResult := t.openPackage(packageReader, size)
+
+	if readerResult.IsErr() {
+		return core.Err[core.Empty, core.Error](readerResult.UnwrapErr())
+	}
+
+	reader := readerResult.Unwrap()
+
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			t.logger.Warn("Failed to close package data's reader.", zap.String("err", err.Error()))
+		}
+	}(reader)
+
+	guardResult := newExtractionGuard(targetPath, t.limits)
+
+	if guardResult.IsErr() {
+		return core.Err[core.Empty, core.Error](guardResult.UnwrapErr())
+	}
+
+	guard := guardResult.Unwrap()
+	tarReader := tar.NewReader(reader)
+
+	for {
+		header, err := tarReader.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if errors.Is(err, errLimitExceeded) {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.PackageLimitExceeded, fmt.Sprintf("package exceeds the compression ratio of %v", t.limits.MaxCompressionRatio)))
+		}
+
+		if err != nil {
+			return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to read tar package data: %s", err)))
+		}
+
+		result := t.extractEntry(guard, header, tarReader)
+
+		if result.IsErr() {
+			t.logger.Warn("Rejected configuration package.", zap.String("entry", header.Name), zap.String("err", result.UnwrapErr().Message))
+
+			return result
+		}
+	}
+
+	result := guard.createSymlinks()
+
+	if result.IsErr() {
+		t.logger.Warn("Rejected configuration package.", zap.String("err", result.UnwrapErr().Message))
+	}
+
+	return result
+}
+
+func (t TarExtractor) extractEntry(guard *extractionGuard, header *tar.Header, content io.Reader) core.Result[core.Empty, core.Error] {
+	// PAX global headers carry no file.
+	if header.Typeflag == tar.TypeXGlobalHeader {
+		return core.Ok[core.Empty, core.Error](core.Empty{})
+	}
+
+	pathResult := guard.resolvePath(header.Name)
+
+	if pathResult.IsErr() {
+		return core.Err[core.Empty, core.Error](pathResult.UnwrapErr())
+	}
+
+	path := pathResult.Unwrap()
+	mode := header.FileInfo().Mode()
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		limitResult := guard.addEntry(header.Name, 0, 0)
+
+		if limitResult.IsErr() {
+			return limitResult
+		}
+
+		return guard.createDirectory(path, mode)
+	case tar.TypeReg:
+		limitResult := guard.addEntry(header.Name, 0, header.Size)
+
+		if limitResult.IsErr() {
+			return limitResult
+		}
+
+		return guard.writeFile(header.Name, path, content, mode)
+	case tar.TypeSymlink:
+		limitResult := guard.addEntry(header.Name, 0, 0)
+
+		if limitResult.IsErr() {
+			return limitResult
+		}
+
+		return guard.addSymlink(header.Name, path, header.Linkname)
+	default:
+		return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("entry '%s' has an unsupported type '%c'", header.Name, header.Typeflag)))
+	}
+}
+
+// openPackage returns the package's decompressed tar stream. When a compression ratio limit is set, reading more
+// than the package's size times the ratio fails with errLimitExceeded.
+func (t TarExtractor) openPackage(packageReader io.Reader, size int64) core.Result[io.ReadCloser, core.Error] {
+	var reader io.ReadCloser
+
+	switch t.compression {
+	case NoCompression:
+		return core.Ok[io.ReadCloser, core.Error](io.NopCloser(packageReader))
+	case GzipCompression:
+		gzipReader, err := gzip.NewReader(packageReader)
+
+		if err != nil {
+			return core.Err[io.ReadCloser, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to decompress gzip package data: %s", err)))
+		}
+
+		reader = gzipReader
+	case ZstdCompression:
+		zstdReader, err := zstd.NewReader(packageReader)
+
+		if err != nil {
+			return core.Err[io.ReadCloser, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to decompress zstd package data: %s", err)))
+		}
+
+		reader = zstdReader.IOReadCloser()
+	default:
+		return core.Err[io.ReadCloser, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("unsupported tar compression '%s'", t.compression)))
+	}
+
+	if t.limits.MaxCompressionRatio <= 0 {
+		return core.Ok[io.ReadCloser, core.Error](reader)
+	}
+
+	maxDecompressedSize := int64(float64(size) * t.limits.MaxCompressionRatio)
+
+	return core.Ok[io.ReadCloser, core.Error](&limitedReadCloser{
+		limitedReader: limitedReader{reader: reader, remaining: maxDecompressedSize},
+		closer:        reader,
+	})
+}
+
+type limitedReadCloser struct {
+	limitedReader
+	closer io.Closer
+}
+
+func (l *limitedReadCloser) Close() error {
+	return l.closer.Close()
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type TarExtractorTestSuite struct {
+	suite.Suite
+	TargetPath string
+	Logger     *zap.Logger
+}
+
+func TestTarExtractorTestSuite(t *testing.T) {
+	suite.Run(t, new(TarExtractorTestSuite))
+}
+
+func (t *TarExtractorTestSuite) SetupTest() {
+	t.Logger, _ = zap.NewDevelopment()
+	t.TargetPath = uuid.New().String()
+}
+
+func (t *TarExtractorTestSuite) TearDownTest() {
+	_ = RemoveExtraction(t.TargetPath)
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_EveryCompression_ExtractsExpectedFiles() {
+	entries := []tarEntry{
+		{Name: "config/", Mode: 0755, Type: tar.TypeDir},
+		{Name: "config/application.yaml", Content: "Value: 1", Mode: 0644, Type: tar.TypeReg},
+		{Name: "cp-config", Content: "#!/bin/sh", Mode: 0755, Type: tar.TypeReg},
+	}
+
+	for _, compression := range []TarCompression{NoCompression, GzipCompression, ZstdCompression} {
+		extractor := NewTarExtractor(t.Logger, compression)
+
+		result := extractor.Extract(createTar(entries, compression), t.TargetPath)
+
+		content, contentErr := os.ReadFile(fmt.Sprintf("%s/config/application.yaml", t.TargetPath))
+		executableInfo, executableErr := os.Stat(fmt.Sprintf("%s/cp-config", t.TargetPath))
+		assert.True(t.T(), result.IsOk(), string(compression))
+		assert.Nil(t.T(), contentErr)
+		assert.Equal(t.T(), "Value: 1", string(content))
+		assert.Nil(t.T(), executableErr)
+		assert.Equal(t.T(), os.FileMode(0755), executableInfo.Mode().Perm())
+	}
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_ParentDirectoryEntry_ReturnsUnsafePackageEntry() {
+	extractor := NewTarExtractor(t.Logger, GzipCompression)
+	packageData := createTar([]tarEntry{{Name: "../escaped.yaml", Content: "Value: 1", Mode: 0644, Type: tar.TypeReg}}, GzipCompression)
+
+	result := extractor.Extract(packageData, t.TargetPath)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+	assert.False(t.T(), doesDirectoryExist(t.TargetPath))
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_SymlinkOutsideTargetPath_ReturnsUnsafePackageEntry() {
+	extractor := NewTarExtractor(t.Logger, NoCompression)
+	packageData := createTar([]tarEntry{{Name: "link", Linkname: "/etc/passwd", Mode: 0777, Type: tar.TypeSymlink}}, NoCompression)
+
+	result := extractor.Extract(packageData, t.TargetPath)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_ChainedSymlinks_ReturnsUnsafePackageEntry() {
+	extractor := NewTarExtractor(t.Logger, NoCompression)
+	targetPath := filepath.Join(t.TargetPath, "a", "b", "c", "target")
+	packageData := createTar([]tarEntry{
+		{Name: "a/b/c", Linkname: "../..", Mode: 0777, Type: tar.TypeSymlink},
+		{Name: "a/b/c/x", Linkname: "../../..", Mode: 0777, Type: tar.TypeSymlink},
+		{Name: "a/b/c/x/escaped.txt", Content: "escaped", Mode: 0644, Type: tar.TypeReg},
+	}, NoCompression)
+
+	result := extractor.Extract(packageData, targetPath)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+	assert.Empty(t.T(), findFiles(t.TargetPath, "escaped.txt", targetPath))
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_HardLink_ReturnsUnsafePackageEntry() {
+	extractor := NewTarExtractor(t.Logger, NoCompression)
+	packageData := createTar([]tarEntry{
+		{Name: "application.yaml", Content: "Value: 1", Mode: 0644, Type: tar.TypeReg},
+		{Name: "link", Linkname: "application.yaml", Mode: 0644, Type: tar.TypeLink},
+	}, NoCompression)
+
+	result := extractor.Extract(packageData, t.TargetPath)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_CompressionRatioTooHigh_ReturnsPackageLimitExceeded() {
+	extractor := NewTarExtractorWithLimits(t.Logger, ZstdCompression, ExtractionLimits{MaxCompressionRatio: 10})
+	packageData := createTar([]tarEntry{{Name: "bomb.yaml", Content: strings.Repeat("0", 1<<20), Mode: 0644, Type: tar.TypeReg}}, ZstdCompression)
+
+	result := extractor.Extract(packageData, t.TargetPath)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_TooManyDirectories_ReturnsPackageLimitExceeded() {
+	extractor := NewTarExtractorWithLimits(t.Logger, NoCompression, ExtractionLimits{MaxFiles: 2})
+	packageData := createTar([]tarEntry{
+		{Name: "a/", Mode: 0755, Type: tar.TypeDir},
+		{Name: "b/", Mode: 0755, Type: tar.TypeDir},
+		{Name: "c/", Mode: 0755, Type: tar.TypeDir},
+	}, NoCompression)
+
+	result := extractor.Extract(packageData, t.TargetPath)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_FileTooBig_ReturnsPackageLimitExceeded() {
+	extractor := NewTarExtractorWithLimits(t.Logger, GzipCompression, ExtractionLimits{MaxFileSize: 16})
+	packageData := createTar([]tarEntry{{Name: "big.yaml", Content: strings.Repeat("A", 32), Mode: 0644, Type: tar.TypeReg}}, GzipCompression)
+
+	result := extractor.Extract(packageData, t.TargetPath)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TarExtractorTestSuite) TestTarExtractor_Extract_CorruptedPackage_ReturnsExtractionFailure() {
+	extractor := NewTarExtractor(t.Logger, GzipCompression)
+
+	result := extractor.Extract([]byte("not a gzip package"), t.TargetPath)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.ExtractionFailure, result.UnwrapErr().ErrorKind)
+}
+
+type tarEntry struct {
+	Name     string
+	Content  string
+	Linkname string
+	Mode     int64
+	Type     byte
+}
+
+func createTar(entries []tarEntry, compression TarCompression) []byte {
+	tarBuffer := new(bytes.Buffer)
+	writer := tar.NewWriter(tarBuffer)
+
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.Name,
+			Linkname: entry.Linkname,
+			Mode:     entry.Mode,
+			Size:     int64(len(entry.Content)),
+			Typeflag: entry.Type,
+			Format:   tar.FormatPAX,
+		}
+
+		if err := writer.WriteHeader(header); err != nil {
+			panic(err)
+		}
+
+		_, _ = writer.Write([]byte(entry.Content))
+	}
+
+	_ = writer.Close()
+
+	switch compression {
+	case GzipCompression:
+		buffer := new(bytes.Buffer)
+		gzipWriter := gzip.NewWriter(buffer)
+		_, _ = gzipWriter.Write(tarBuffer.Bytes())
+		_ = gzipWriter.Close()
+
+		return buffer.Bytes()
+	case ZstdCompression:
+		buffer := new(bytes.Buffer)
+		zstdWriter, _ := zstd.NewWriter(buffer)
+		_, _ = zstdWriter.Write(tarBuffer.Bytes())
+		_ = zstdWriter.Close()
+
+		return buffer.Bytes()
+	default:
+		return tarBuffer.Bytes()
+	}
+}
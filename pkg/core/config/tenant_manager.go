@@ -0,0 +1,275 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"go.uber.org/zap"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// tenantPattern restricts tenants to names which are safe to use as a directory's name.
+var tenantPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// TenantClientFactory creates the Client of the tenant, which must use the specified working path. The tenant is
+// usually passed on to the Downloader, i.e. through ServerDownloader.SetTenant. The Downloaders without tenants,
+// such as the DirectoryDownloader, GitDownloader or S3Downloader, are scoped through a host of the tenant's own.
+type TenantClientFactory func(tenant string, workingPath string) *Client
+
+// TenantManager lazily creates a Client per tenant, each one within its own working path '<workingPath>/<tenant>',
+// and closes the Clients of the tenants which have been idle for longer than the idle timeout.
+type TenantManager struct {
+	logger        *zap.Logger
+	workingPath   string
+	clientFactory TenantClientFactory
+	idleTimeout   time.Duration
+	tenants       map[string]*tenantEntry
+	// closing contains the tenants whose evicted Client is being closed, whose channel is closed once it's done.
+	// A tenant gets no new Client meanwhile, since closing the evicted one deletes the shared working path.
+	closing map[string]chan struct{}
+	// creating contains the tenants whose Client is being created, whose channel is closed once it's done. The
+	// Client is created without holding the mutex, since creating it does I/O.
+	creating     map[string]chan struct{}
+	closed       bool
+	tenantsMutex sync.Mutex
+	stopChannel  chan struct{}
+	stopOnce     sync.Once
+}
+
+type tenantEntry struct {
+	client   *Client
+	lastUsed time.Time
+	// users is the number of calls to Get which are using the Client, which cannot be evicted meanwhile.
+	users int
+}
+
+// NewTenantManager creates an instance of TenantManager. A zero idleTimeout disables the eviction.
+func NewTenantManager(logger *zap.Logger, workingPath string, clientFactory TenantClientFactory, idleTimeout time.Duration) *TenantManager {
+	manager := new(TenantManager)
+
+	manager.logger = logger
+	manager.workingPath = workingPath
+	manager.clientFactory = clientFactory
+	manager.idleTimeout = idleTimeout
+	manager.tenants = make(map[string]*tenantEntry)
+	manager.closing = make(map[string]chan struct{})
+	manager.creating = make(map[string]chan struct{})
+	manager.stopChannel = make(chan struct{})
+
+	return manager
+}
+
+// Get retrieves the tenant's configuration located within the specified file and at the specified key.
+// See Client.Get for the key's syntax.
+func (m *TenantManager) Get(tenant string, filePath string, key string) core.Result[any, core.Error] {
+	entryResult := m.acquire(tenant)
+
+	if entryResult.IsErr() {
+		return core.Err[any, core.Error](entryResult.UnwrapErr())
+	}
+
+	entry := entryResult.Unwrap()
+	defer m.release(entry)
+
+	return entry.client.Get(filePath, key)
+}
+
+// Client returns the tenant's Client, creating it if needed. The Client may be closed once the tenant has been
+// idle for longer than the idle timeout, so it should not be kept around; use Get whenever possible.
+func (m *TenantManager) Client(tenant string) core.Result[*Client, core.Error] {
+	entryResult := m.acquire(tenant)
+
+	if entryResult.IsErr() {
+		return core.Err[*Client, core.Error](entryResult.UnwrapErr())
+	}
+
+	entry := entryResult.Unwrap()
+	m.release(entry)
+
+	return core.Ok[*Client, core.Error](entry.client)
+}
+
+// Tenants returns the sorted tenants whose Client is currently cached.
+func (m *TenantManager) Tenants() []string {
+	m.tenantsMutex.Lock()
+	defer m.tenantsMutex.Unlock()
+
+	tenants := make([]string, 0, len(m.tenants))
+
+	for tenant := range m.tenants {
+		tenants = append(tenants, tenant)
+	}
+
+	sort.Strings(tenants)
+
+	return tenants
+}
+
+// EvictIdle closes the Clients of the tenants which have been idle for longer than the idle timeout, deleting their
+// working paths, and returns how many were evicted. Evicted tenants get a new Client on their next use.
+func (m *TenantManager) EvictIdle() int {
+	if m.idleTimeout <= 0 {
+		return 0
+	}
+
+	m.tenantsMutex.Lock()
+	evicted := make(map[string]*Client)
+
+	for tenant, entry := range m.tenants {
+		if entry.users == 0 && time.Since(entry.lastUsed) > m.idleTimeout {
+			evicted[tenant] = entry.client
+			delete(m.tenants, tenant)
+			m.closing[tenant] = make(chan struct{})
+		}
+	}
+
+	m.tenantsMutex.Unlock()
+
+	for tenant, client := range evicted {
+		m.logger.Info("Evicting idle tenant's configuration.", zap.String("tenant", tenant))
+		m.closeClient(tenant, client)
+	}
+
+	return len(evicted)
+}
+
+// StartEviction calls EvictIdle in the background, every interval, until Close is called.
+func (m *TenantManager) StartEviction(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopChannel:
+				return
+			case <-ticker.C:
+				m.EvictIdle()
+			}
+		}
+	}()
+}
+
+// Close stops the background eviction and closes every tenant's Client, including those being created. Once
+// closed, the TenantManager creates no more Clients.
+func (m *TenantManager) Close() {
+	m.stopOnce.Do(func() {
+		close(m.stopChannel)
+	})
+
+	m.tenantsMutex.Lock()
+	m.closed = true
+	tenants := m.tenants
+	m.tenants = make(map[string]*tenantEntry)
+
+	for tenant := range tenants {
+		m.closing[tenant] = make(chan struct{})
+	}
+
+	creating := make([]chan struct{}, 0, len(m.creating))
+
+	for _, created := range m.creating {
+		creating = append(creating, created)
+	}
+
+	m.tenantsMutex.Unlock()
+
+	for tenant, entry := range tenants {
+		m.closeClient(tenant, entry.client)
+	}
+
+	for _, created := range creating {
+		<-created
+	}
+}
+
+// closeClient closes the tenant's Client, which must have been added to the closing tenants, and lets the waiting
+// calls create a new Client for the tenant.
+func (m *TenantManager) closeClient(tenant string, client *Client) {
+	client.Close()
+
+	m.tenantsMutex.Lock()
+	closed := m.closing[tenant]
+	delete(m.closing, tenant)
+	m.tenantsMutex.Unlock()
+
+	close(closed)
+}
+
+func (m *TenantManager) acquire(tenant string) core.Result[*tenantEntry, core.Error] {
+	if !tenantPattern.MatchString(tenant) {
+		return core.Err[*tenantEntry, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("invalid tenant '%s'", tenant)))
+	}
+
+	m.tenantsMutex.Lock()
+
+	for {
+		if m.closed {
+			m.tenantsMutex.Unlock()
+
+			return closedTenantManagerError()
+		}
+
+		if entry, exists := m.tenants[tenant]; exists {
+			entry.users++
+			entry.lastUsed = time.Now()
+			m.tenantsMutex.Unlock()
+
+			return core.Ok[*tenantEntry, core.Error](entry)
+		}
+
+		pending, isPending := m.closing[tenant]
+
+		if !isPending {
+			pending, isPending = m.creating[tenant]
+		}
+
+		if !isPending {
+			break
+		}
+
+		m.tenantsMutex.Unlock()
+		<-pending
+		m.tenantsMutex.Lock()
+	}
+
+	created := make(chan struct{})
+	m.creating[tenant] = created
+	m.tenantsMutex.Unlock()
+
+	m.logger.Info("Creating tenant's configuration client.", zap.String("tenant", tenant))
+	client := m.clientFactory(tenant, filepath.Join(m.workingPath, tenant))
+
+	m.tenantsMutex.Lock()
+	delete(m.creating, tenant)
+
+	if m.closed {
+		m.tenantsMutex.Unlock()
+		client.Close()
+		close(created)
+
+		return closedTenantManagerError()
+	}
+
+	entry := &tenantEntry{client: client, users: 1, lastUsed: time.Now()}
+	m.tenants[tenant] = entry
+	m.tenantsMutex.Unlock()
+	close(created)
+
+	return core.Ok[*tenantEntry, core.Error](entry)
+}
+
+func (m *TenantManager) release(entry *tenantEntry) {
+	m.tenantsMutex.Lock()
+	defer m.tenantsMutex.Unlock()
+
+	entry.users--
+	entry.lastUsed = time.Now()
+}
+
+func closedTenantManagerError() core.Result[*tenantEntry, core.Error] {
+	return core.Err[*tenantEntry, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, "tenant manager has been closed"))
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type TenantManagerTestSuite struct {
+	suite.Suite
+	WorkingPath     string
+	CreatedTenants  []string
+	ProviderTenants map[string]*MockProvider
+	Manager         *TenantManager
+}
+
+func TestTenantManagerTestSuite(t *testing.T) {
+	suite.Run(t, new(TenantManagerTestSuite))
+}
+
+func (t *TenantManagerTestSuite) SetupTest() {
+	t.WorkingPath = uuid.New().String()
+	t.CreatedTenants = make([]string, 0)
+	logger, _ := zap.NewDevelopment()
+	t.Manager = NewTenantManager(logger, t.WorkingPath, func(tenant string, workingPath string) *Client {
+		t.CreatedTenants = append(t.CreatedTenants, tenant)
+		downloader := new(MockDownloader)
+		downloader.On("Download", host, stage, environment, component).Return(core.Ok[[]byte, core.Error]([]byte(tenant)))
+		extractor := new(MockExtractor)
+		extractor.On("Extract", []byte(tenant), stagingPathOf(workingPath)).Return()
+		provider := new(MockProvider)
+		provider.On("Get", filePath, configKey).Return(tenant)
+		provider.On("CleanCache").Return()
+
+		return NewClient(logger, host, stage, environment, component, workingPath, downloader, extractor, provider)
+	}, 10*time.Millisecond)
+}
+
+func (t *TenantManagerTestSuite) TearDownTest() {
+	t.Manager.Close()
+	_ = os.RemoveAll(t.WorkingPath)
+}
+
+func (t *TenantManagerTestSuite) TestGet_IsolatesTenants() {
+	first := t.Manager.Get("first", filePath, configKey)
+	second := t.Manager.Get("second", filePath, configKey)
+
+	assert.Equal(t.T(), "first", first.Unwrap())
+	assert.Equal(t.T(), "second", second.Unwrap())
+	assert.True(t.T(), doesDirectoryExist(filepath.Join(t.WorkingPath, "first")))
+	assert.True(t.T(), doesDirectoryExist(filepath.Join(t.WorkingPath, "second")))
+	assert.Equal(t.T(), []string{"first", "second"}, t.Manager.Tenants())
+}
+
+func (t *TenantManagerTestSuite) TestGet_SameTenant_ReusesClient() {
+	_ = t.Manager.Get("first", filePath, configKey)
+	_ = t.Manager.Get("first", filePath, configKey)
+
+	assert.Equal(t.T(), []string{"first"}, t.CreatedTenants)
+}
+
+func (t *TenantManagerTestSuite) TestGet_UnsafeTenant_InvalidInput() {
+	result := t.Manager.Get("../other", filePath, configKey)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+	assert.Empty(t.T(), t.CreatedTenants)
+}
+
+func (t *TenantManagerTestSuite) TestEvictIdle_ClosesIdleTenants() {
+	_ = t.Manager.Get("first", filePath, configKey)
+	time.Sleep(20 * time.Millisecond)
+	_ = t.Manager.Get("second", filePath, configKey)
+
+	evicted := t.Manager.EvictIdle()
+	result := t.Manager.Get("first", filePath, configKey)
+
+	assert.Equal(t.T(), 1, evicted)
+	assert.Equal(t.T(), "first", result.Unwrap())
+	assert.Equal(t.T(), []string{"first", "second", "first"}, t.CreatedTenants)
+}
+
+func (t *TenantManagerTestSuite) TestEvictIdle_TenantInUse_IsKept() {
+	entry := t.Manager.acquire("first").Unwrap()
+	time.Sleep(20 * time.Millisecond)
+
+	evicted := t.Manager.EvictIdle()
+	t.Manager.release(entry)
+
+	assert.Equal(t.T(), 0, evicted)
+	assert.Equal(t.T(), []string{"first"}, t.Manager.Tenants())
+}
+
+func (t *TenantManagerTestSuite) TestGet_TenantBeingClosed_WaitsForClose() {
+	closed := make(chan struct{})
+	t.Manager.tenantsMutex.Lock()
+	t.Manager.closing["first"] = closed
+	t.Manager.tenantsMutex.Unlock()
+	results := make(chan core.Result[any, core.Error], 1)
+
+	go func() {
+		results <- t.Manager.Get("first", filePath, configKey)
+	}()
+
+	select {
+	case <-results:
+		t.Fail("tenant's client was created while the previous one was being closed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	t.Manager.tenantsMutex.Lock()
+	delete(t.Manager.closing, "first")
+	t.Manager.tenantsMutex.Unlock()
+	close(closed)
+
+	select {
+	case result := <-results:
+		assert.Equal(t.T(), "first", result.Unwrap())
+		assert.True(t.T(), doesDirectoryExist(filepath.Join(t.WorkingPath, "first")))
+	case <-time.After(time.Second):
+		t.Fail("tenant's client was not created once the previous one was closed")
+	}
+}
+
+func (t *TenantManagerTestSuite) TestGet_TenantBeingCreated_DoesNotBlockOtherTenants() {
+	factory := t.Manager.clientFactory
+	release := make(chan struct{})
+	t.Manager.clientFactory = func(tenant string, workingPath string) *Client {
+		if tenant == "slow" {
+			<-release
+		}
+
+		return factory(tenant, workingPath)
+	}
+	results := make(chan core.Result[any, core.Error], 1)
+
+	go func() {
+		results <- t.Manager.Get("slow", filePath, configKey)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	result := t.Manager.Get("first", filePath, configKey)
+	close(release)
+
+	assert.Equal(t.T(), "first", result.Unwrap())
+
+	select {
+	case slowResult := <-results:
+		assert.Equal(t.T(), "slow", slowResult.Unwrap())
+	case <-time.After(time.Second):
+		t.Fail("tenant's client was not created once the factory returned")
+	}
+}
+
+func (t *TenantManagerTestSuite) TestGet_ClosedManager_Error() {
+	t.Manager.Close()
+
+	result := t.Manager.Get("first", filePath, configKey)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.ConfigurationRetrievalFailure, result.UnwrapErr().ErrorKind)
+	assert.Empty(t.T(), t.CreatedTenants)
+	assert.Empty(t.T(), t.Manager.Tenants())
+}
+
+func (t *TenantManagerTestSuite) TestClose_RemovesWorkingPaths() {
+	_ = t.Manager.Get("first", filePath, configKey)
+
+	t.Manager.Close()
+
+	assert.False(t.T(), doesDirectoryExist(filepath.Join(t.WorkingPath, "first")))
+	assert.Empty(t.T(), t.Manager.Tenants())
+}
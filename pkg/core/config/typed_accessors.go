@@ -0,0 +1,278 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var byteSizePattern = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*([a-zA-Z]*)\s*$`)
+
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ValueGetter is implemented by every source of configuration values, such as any Provider or the Client, whose
+// values are interpolated. The typed accessors, i.e. GetDuration, read their values from a ValueGetter.
+type ValueGetter interface {
+	Get(filePath string, key string) core.Result[any, core.Error]
+}
+
+// GetString returns the value located at the key within the file as a string.
+func GetString(getter ValueGetter, filePath string, key string) core.Result[string, core.Error] {
+	return getTypedValue(getter, filePath, key, "a string", toString)
+}
+
+// GetStringWithDefault works like GetString, but returns the default value if the key does not exist or is null.
+func GetStringWithDefault(getter ValueGetter, filePath string, key string, defaultValue string) core.Result[string, core.Error] {
+	return getTypedValueWithDefault(getter, filePath, key, "a string", toString, defaultValue)
+}
+
+// GetInt returns the value located at the key within the file as an int. Floating point values are only accepted
+// when they have no fractional part.
+func GetInt(getter ValueGetter, filePath string, key string) core.Result[int, core.Error] {
+	return getTypedValue(getter, filePath, key, "an integer", toInt)
+}
+
+// GetIntWithDefault works like GetInt, but returns the default value if the key does not exist or is null.
+func GetIntWithDefault(getter ValueGetter, filePath string, key string, defaultValue int) core.Result[int, core.Error] {
+	return getTypedValueWithDefault(getter, filePath, key, "an integer", toInt, defaultValue)
+}
+
+// GetBool returns the value located at the key within the file as a bool.
+func GetBool(getter ValueGetter, filePath string, key string) core.Result[bool, core.Error] {
+	return getTypedValue(getter, filePath, key, "a boolean", toBool)
+}
+
+// GetBoolWithDefault works like GetBool, but returns the default value if the key does not exist or is null.
+func GetBoolWithDefault(getter ValueGetter, filePath string, key string, defaultValue bool) core.Result[bool, core.Error] {
+	return getTypedValueWithDefault(getter, filePath, key, "a boolean", toBool, defaultValue)
+}
+
+// GetDuration returns the value located at the key within the file as a time.Duration. The value must be a
+// string as accepted by time.ParseDuration, i.e. '30s' or '1h30m'; plain numbers are rejected since their unit
+// would be ambiguous.
+func GetDuration(getter ValueGetter, filePath string, key string) core.Result[time.Duration, core.Error] {
+	return getTypedValue(getter, filePath, key, "a duration such as '30s'", toDuration)
+}
+
+// GetDurationWithDefault works like GetDuration, but returns the default value if the key does not exist or is null.
+func GetDurationWithDefault(getter ValueGetter, filePath string, key string, defaultValue time.Duration) core.Result[time.Duration, core.Error] {
+	return getTypedValueWithDefault(getter, filePath, key, "a duration such as '30s'", toDuration, defaultValue)
+}
+
+// GetByteSize returns the value located at the key within the file as a number of bytes. The value is either an
+// integer number of bytes or a string with a decimal (kB, MB, GB, TB) or binary (KiB, MiB, GiB, TiB) unit,
+// i.e. '10MiB' or '1.5 GB'. Units are case-insensitive.
+func GetByteSize(getter ValueGetter, filePath string, key string) core.Result[int64, core.Error] {
+	return getTypedValue(getter, filePath, key, "a byte size such as '10MiB'", toByteSize)
+}
+
+// GetByteSizeWithDefault works like GetByteSize, but returns the default value if the key does not exist or is null.
+func GetByteSizeWithDefault(getter ValueGetter, filePath string, key string, defaultValue int64) core.Result[int64, core.Error] {
+	return getTypedValueWithDefault(getter, filePath, key, "a byte size such as '10MiB'", toByteSize, defaultValue)
+}
+
+// GetStringSlice returns the value located at the key within the file as a slice of strings.
+func GetStringSlice(getter ValueGetter, filePath string, key string) core.Result[[]string, core.Error] {
+	return getTypedValue(getter, filePath, key, "a list of strings", toStringSlice)
+}
+
+// GetStringSliceWithDefault works like GetStringSlice, but returns the default value if the key does not exist or is null.
+func GetStringSliceWithDefault(getter ValueGetter, filePath string, key string, defaultValue []string) core.Result[[]string, core.Error] {
+	return getTypedValueWithDefault(getter, filePath, key, "a list of strings", toStringSlice, defaultValue)
+}
+
+// GetURL returns the value located at the key within the file as an absolute url.
+func GetURL(getter ValueGetter, filePath string, key string) core.Result[*url.URL, core.Error] {
+	return getTypedValue(getter, filePath, key, "an absolute url", toURL)
+}
+
+// GetURLWithDefault works like GetURL, but returns the default value if the key does not exist or is null.
+func GetURLWithDefault(getter ValueGetter, filePath string, key string, defaultValue *url.URL) core.Result[*url.URL, core.Error] {
+	return getTypedValueWithDefault(getter, filePath, key, "an absolute url", toURL, defaultValue)
+}
+
+// getTypedValue converts the value located at the key within the file, failing with an 'invalid_configuration' error
+// which names the key, the expected type and the found value whenever it cannot be converted.
+func getTypedValue[T any](getter ValueGetter, filePath string, key string, expected string, convert func(any) (T, bool)) core.Result[T, core.Error] {
+	valueResult := getter.Get(filePath, key)
+
+	if valueResult.IsErr() {
+		return core.Err[T, core.Error](valueResult.UnwrapErr())
+	}
+
+	value := valueResult.Unwrap()
+
+	if value == nil {
+		return core.Err[T, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("key '%s' of file '%s' is null", key, filePath)))
+	}
+
+	return convertValue(filePath, key, value, expected, convert)
+}
+
+// getTypedValueWithDefault works like getTypedValue, but returns the default value if the key does not exist or is
+// null. A missing file still fails with 'file_not_found', so a mistyped file path is not hidden behind defaults.
+func getTypedValueWithDefault[T any](getter ValueGetter, filePath string, key string, expected string, convert func(any) (T, bool), defaultValue T) core.Result[T, core.Error] {
+	valueResult := getter.Get(filePath, key)
+
+	if valueResult.IsErr() {
+		if valueResult.UnwrapErr().ErrorKind == core.NotFound {
+			return core.Ok[T, core.Error](defaultValue)
+		}
+
+		return core.Err[T, core.Error](valueResult.UnwrapErr())
+	}
+
+	value := valueResult.Unwrap()
+
+	if value == nil {
+		return core.Ok[T, core.Error](defaultValue)
+	}
+
+	return convertValue(filePath, key, value, expected, convert)
+}
+
+func convertValue[T any](filePath string, key string, value any, expected string, convert func(any) (T, bool)) core.Result[T, core.Error] {
+	convertedValue, ok := convert(value)
+
+	if !ok {
+		return core.Err[T, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("expected key '%s' of file '%s' to be %s, found '%T' value '%v'", key, filePath, expected, value, value)))
+	}
+
+	return core.Ok[T, core.Error](convertedValue)
+}
+
+func toString(value any) (string, bool) {
+	stringValue, ok := value.(string)
+
+	return stringValue, ok
+}
+
+func toInt(value any) (int, bool) {
+	switch typedValue := value.(type) {
+	case int:
+		return typedValue, true
+	case int64:
+		return int(typedValue), typedValue >= math.MinInt && typedValue <= math.MaxInt
+	case uint64:
+		return int(typedValue), typedValue <= math.MaxInt
+	case float64:
+		// math.MaxInt rounds up to a power of two as a float64, so the upper bound is exclusive.
+		if typedValue != math.Trunc(typedValue) || typedValue < math.MinInt || typedValue >= math.MaxInt+1 {
+			return 0, false
+		}
+
+		return int(typedValue), true
+	default:
+		return 0, false
+	}
+}
+
+func toBool(value any) (bool, bool) {
+	boolValue, ok := value.(bool)
+
+	return boolValue, ok
+}
+
+func toDuration(value any) (time.Duration, bool) {
+	stringValue, ok := value.(string)
+
+	if !ok {
+		return 0, false
+	}
+
+	duration, err := time.ParseDuration(strings.TrimSpace(stringValue))
+
+	return duration, err == nil
+}
+
+func toByteSize(value any) (int64, bool) {
+	if intValue, ok := toInt(value); ok {
+		return int64(intValue), intValue >= 0
+	}
+
+	stringValue, ok := value.(string)
+
+	if !ok {
+		return 0, false
+	}
+
+	matches := byteSizePattern.FindStringSubmatch(stringValue)
+
+	if matches == nil {
+		return 0, false
+	}
+
+	multiplier, ok := byteSizeUnits[strings.ToLower(matches[2])]
+
+	if !ok {
+		return 0, false
+	}
+
+	number, err := strconv.ParseFloat(matches[1], 64)
+
+	if err != nil {
+		return 0, false
+	}
+
+	size := math.Round(number * multiplier)
+
+	if size >= 1<<63 {
+		return 0, false
+	}
+
+	return int64(size), true
+}
+
+func toStringSlice(value any) ([]string, bool) {
+	items, ok := value.([]any)
+
+	if !ok {
+		return nil, false
+	}
+
+	stringItems := make([]string, 0, len(items))
+
+	for _, item := range items {
+		stringItem, ok := item.(string)
+
+		if !ok {
+			return nil, false
+		}
+
+		stringItems = append(stringItems, stringItem)
+	}
+
+	return stringItems, true
+}
+
+func toURL(value any) (*url.URL, bool) {
+	stringValue, ok := value.(string)
+
+	if !ok {
+		return nil, false
+	}
+
+	parsedUrl, err := url.Parse(stringValue)
+
+	if err != nil || len(parsedUrl.Scheme) == 0 || len(parsedUrl.Host) == 0 {
+		return nil, false
+	}
+
+	return parsedUrl, true
+}
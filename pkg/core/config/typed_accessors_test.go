@@ -0,0 +1,151 @@
+package config
+
+import (
+	"github.com/google/uuid"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const typedFile = "typed.yaml"
+
+const typedContent = `String: text
+Int: 42
+WholeFloat: 3.0
+Float: 3.5
+OverflowFloat: 9.223372036854775808e18
+Bool: true
+Duration: 1m30s
+Seconds: 30
+Size: 10MiB
+DecimalSize: 1.5 GB
+BytesSize: 512
+InvalidSize: 10 parsecs
+OverflowSize: 8388608 TiB
+Slice: [a, b]
+MixedSlice: [a, 1]
+Url: https://simpleg.eu/config
+RelativeUrl: /config
+Null: null
+`
+
+type TypedAccessorsTestSuite struct {
+	suite.Suite
+	Path     string
+	Provider *FileProvider
+}
+
+func TestTypedAccessorsTestSuite(t *testing.T) {
+	suite.Run(t, new(TypedAccessorsTestSuite))
+}
+
+func (t *TypedAccessorsTestSuite) SetupTest() {
+	t.Path = uuid.New().String()
+	_ = os.MkdirAll(t.Path, os.ModePerm)
+	_ = os.WriteFile(filepath.Join(t.Path, typedFile), []byte(typedContent), 0644)
+	t.Provider = NewFileProvider(t.Path, core.NewCache(time.Hour), time.Hour)
+}
+
+func (t *TypedAccessorsTestSuite) TearDownTest() {
+	_ = os.RemoveAll(t.Path)
+}
+
+func (t *TypedAccessorsTestSuite) TestGetString_ReturnsString() {
+	assert.Equal(t.T(), "text", GetString(t.Provider, typedFile, "String").Unwrap())
+}
+
+func (t *TypedAccessorsTestSuite) TestGetString_WrongType_InvalidConfigurationNamingTypes() {
+	result := GetString(t.Provider, typedFile, "Int")
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+	assert.Contains(t.T(), result.UnwrapErr().Message, "'Int'")
+	assert.Contains(t.T(), result.UnwrapErr().Message, "a string")
+	assert.Contains(t.T(), result.UnwrapErr().Message, "'int' value '42'")
+}
+
+func (t *TypedAccessorsTestSuite) TestGetInt_AcceptsWholeNumbersOnly() {
+	assert.Equal(t.T(), 42, GetInt(t.Provider, typedFile, "Int").Unwrap())
+	assert.Equal(t.T(), 3, GetInt(t.Provider, typedFile, "WholeFloat").Unwrap())
+	assert.True(t.T(), GetInt(t.Provider, typedFile, "Float").IsErr())
+	assert.True(t.T(), GetInt(t.Provider, typedFile, "OverflowFloat").IsErr())
+}
+
+func (t *TypedAccessorsTestSuite) TestGetBool_ReturnsBool() {
+	assert.True(t.T(), GetBool(t.Provider, typedFile, "Bool").Unwrap())
+	assert.True(t.T(), GetBool(t.Provider, typedFile, "String").IsErr())
+}
+
+func (t *TypedAccessorsTestSuite) TestGetDuration_ParsesDurations() {
+	assert.Equal(t.T(), 90*time.Second, GetDuration(t.Provider, typedFile, "Duration").Unwrap())
+}
+
+func (t *TypedAccessorsTestSuite) TestGetDuration_PlainNumber_InvalidConfiguration() {
+	result := GetDuration(t.Provider, typedFile, "Seconds")
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TypedAccessorsTestSuite) TestGetByteSize_ParsesUnits() {
+	assert.Equal(t.T(), int64(10*1024*1024), GetByteSize(t.Provider, typedFile, "Size").Unwrap())
+	assert.Equal(t.T(), int64(1500000000), GetByteSize(t.Provider, typedFile, "DecimalSize").Unwrap())
+	assert.Equal(t.T(), int64(512), GetByteSize(t.Provider, typedFile, "BytesSize").Unwrap())
+	assert.True(t.T(), GetByteSize(t.Provider, typedFile, "InvalidSize").IsErr())
+	assert.True(t.T(), GetByteSize(t.Provider, typedFile, "OverflowSize").IsErr())
+	assert.True(t.T(), GetByteSize(t.Provider, typedFile, "OverflowFloat").IsErr())
+}
+
+func (t *TypedAccessorsTestSuite) TestGetStringSlice_RequiresStrings() {
+	assert.Equal(t.T(), []string{"a", "b"}, GetStringSlice(t.Provider, typedFile, "Slice").Unwrap())
+	assert.True(t.T(), GetStringSlice(t.Provider, typedFile, "MixedSlice").IsErr())
+}
+
+func (t *TypedAccessorsTestSuite) TestGetURL_RequiresAbsoluteUrl() {
+	assert.Equal(t.T(), "simpleg.eu", GetURL(t.Provider, typedFile, "Url").Unwrap().Host)
+	assert.True(t.T(), GetURL(t.Provider, typedFile, "RelativeUrl").IsErr())
+}
+
+func (t *TypedAccessorsTestSuite) TestWithDefault_MissingOrNullKey_ReturnsDefault() {
+	defaultUrl, _ := url.Parse("https://default.eu")
+
+	assert.Equal(t.T(), "default", GetStringWithDefault(t.Provider, typedFile, "Missing", "default").Unwrap())
+	assert.Equal(t.T(), 7, GetIntWithDefault(t.Provider, typedFile, "Null", 7).Unwrap())
+	assert.Equal(t.T(), true, GetBoolWithDefault(t.Provider, typedFile, "Parent:Missing", true).Unwrap())
+	assert.Equal(t.T(), time.Second, GetDurationWithDefault(t.Provider, typedFile, "Missing", time.Second).Unwrap())
+	assert.Equal(t.T(), int64(1), GetByteSizeWithDefault(t.Provider, typedFile, "Missing", 1).Unwrap())
+	assert.Equal(t.T(), []string{"x"}, GetStringSliceWithDefault(t.Provider, typedFile, "Missing", []string{"x"}).Unwrap())
+	assert.Equal(t.T(), defaultUrl, GetURLWithDefault(t.Provider, typedFile, "Missing", defaultUrl).Unwrap())
+}
+
+func (t *TypedAccessorsTestSuite) TestWithDefault_MissingFile_FileNotFound() {
+	result := GetStringWithDefault(t.Provider, "missing.yaml", "String", "default")
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.FileNotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TypedAccessorsTestSuite) TestWithDefault_WrongType_InvalidConfiguration() {
+	result := GetIntWithDefault(t.Provider, typedFile, "String", 7)
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TypedAccessorsTestSuite) TestGet_NullKey_NotFound() {
+	result := GetString(t.Provider, typedFile, "Null")
+
+	assert.True(t.T(), result.IsErr())
+	assert.Equal(t.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (t *TypedAccessorsTestSuite) TestGetDuration_MemoryProvider_ParsesDurations() {
+	provider := NewMemoryProvider(map[string]any{typedFile: map[string]any{"Duration": "1m30s"}}).Unwrap()
+
+	assert.Equal(t.T(), 90*time.Second, GetDuration(provider, typedFile, "Duration").Unwrap())
+}
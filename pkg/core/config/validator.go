@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"strings"
+)
+
+// Validator
+// Interface which provides a facility to validate an extracted configuration package.
+type Validator interface {
+	// Validate
+	// Validates the configuration package extracted within the packagePath.
+	//
+	// * packagePath - Path where the configuration package has been extracted into.
+	//
+	// Returns: An 'invalid_configuration' error listing every violation if the package is not valid.
+	Validate(packagePath string) core.Result[core.Empty, core.Error]
+}
+
+// Violation describes a single rule which is not satisfied by a configuration package.
+type Violation struct {
+	FilePath string
+	KeyPath  string
+	Message  string
+}
+
+func (v Violation) String() string {
+	if len(v.KeyPath) == 0 {
+		return fmt.Sprintf("%s: %s", v.FilePath, v.Message)
+	}
+
+	return fmt.Sprintf("%s '%s': %s", v.FilePath, v.KeyPath, v.Message)
+}
+
+// NewViolationsError creates an error which reports every one of the specified violations.
+func NewViolationsError(violations []Violation) *core.Error {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("configuration package has %d violation(s):", len(violations)))
+
+	for _, violation := range violations {
+		builder.WriteString("\n- ")
+		builder.WriteString(violation.String())
+	}
+
+	return core.NewError(core.InvalidConfiguration, builder.String())
+}
@@ -8,78 +8,142 @@ import (
 	"go.uber.org/zap"
 	"io"
 	"os"
-	"path/filepath"
 )
 
+// ZipExtractor extracts zip configuration packages. Entries which escape the target path, absolute paths and
+// symbolic links pointing outside of the target path are rejected with an 'unsafe_package_entry' error, while
+// packages exceeding the ExtractionLimits are rejected with a 'package_limit_exceeded' error.
 type ZipExtractor struct {
 	logger *zap.Logger
+	limits ExtractionLimits
 }
 
+// NewZipExtractor creates a ZipExtractor which applies the DefaultExtractionLimits.
 func NewZipExtractor(logger *zap.Logger) *ZipExtractor {
+	return NewZipExtractorWithLimits(logger, DefaultExtractionLimits())
+}
+
+// NewZipExtractorWithLimits creates a ZipExtractor which applies the specified limits.
+func NewZipExtractorWithLimits(logger *zap.Logger, limits ExtractionLimits) *ZipExtractor {
 	extractor := new(ZipExtractor)
 	extractor.logger = logger
+	extractor.limits = limits
 
 	return extractor
 }
 
+// Extract extracts the package into a staging directory which atomically replaces the targetPath once the
+// extraction has succeeded. The replaced content is kept, so it can be restored through Rollback.
 func (z ZipExtractor) Extract(packageData []byte, targetPath string) core.Result[core.Empty, core.Error] {
+	return extractAtomically(targetPath, func(stagingPath string) core.Result[core.Empty, core.Error] {
+		return z.extract(bytes.NewReader(packageData), int64(len(packageData)), stagingPath)
+	})
+}
+
+// ExtractStream works like Extract, but reads the package from the reader instead of memory.
+func (z ZipExtractor) ExtractStream(reader io.ReaderAt, size int64, targetPath string) core.Result[core.Empty, core.Error] {
+	return extractAtomically(targetPath, func(stagingPath string) core.Result[core.Empty, core.Error] {
+		return z.extract(reader, size, stagingPath)
+	})
+}
+
+// Rollback restores the content which was replaced by the last extraction into the targetPath.
+func (z ZipExtractor) Rollback(targetPath string) core.Result[core.Empty, core.Error] {
+	return RollbackExtraction(targetPath)
+}
+
+func (z ZipExtractor) extract(reader io.ReaderAt, size int64, targetPath string) core.Result[core.Empty, core.Error] {
 	err := os.MkdirAll(targetPath, os.ModePerm)
 
 	if err != nil {
 		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create target directory: %s", err)))
 	}
 
-	reader := bytes.NewReader(packageData)
-	zipReader, err := zip.NewReader(reader, int64(len(packageData)))
+	zipReader, err := zip.NewReader(reader, size)
 
 	if err != nil {
 		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to unzip package data: %s", err)))
 	}
 
+	guardResult := newExtractionGuard(targetPath, z.limits)
+
+	if guardResult.IsErr() {
+		return core.Err[core.Empty, core.Error](guardResult.UnwrapErr())
+	}
+
+	guard := guardResult.Unwrap()
+
 	for _, file := range zipReader.File {
-		rc, err := file.Open()
+		result := z.extractFile(guard, file)
 
-		if err != nil {
-			return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to open file within package data: %s", err)))
+		if result.IsErr() {
+			z.logger.Warn("Rejected configuration package.", zap.String("entry", file.Name), zap.String("err", result.UnwrapErr().Message))
+
+			return result
 		}
+	}
 
-		defer func(rc io.ReadCloser) {
-			err := rc.Close()
-			if err != nil {
-				z.logger.Warn("Failed to close previously opened file.", zap.String("err", err.Error()))
-			}
-		}(rc)
+	result := guard.createSymlinks()
 
-		if file.FileInfo().IsDir() {
-			continue
-		}
+	if result.IsErr() {
+		z.logger.Warn("Rejected configuration package.", zap.String("err", result.UnwrapErr().Message))
+	}
 
-		extractedFilePath := filepath.Join(targetPath, file.Name)
+	return result
+}
 
-		err = os.MkdirAll(filepath.Dir(extractedFilePath), os.ModePerm)
+func (z ZipExtractor) extractFile(guard *extractionGuard, file *zip.File) core.Result[core.Empty, core.Error] {
+	pathResult := guard.resolvePath(file.Name)
 
-		if err != nil {
-			return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create sub-directory for package data's extraction: %s", err)))
+	if pathResult.IsErr() {
+		return core.Err[core.Empty, core.Error](pathResult.UnwrapErr())
+	}
+
+	path := pathResult.Unwrap()
+	mode := file.Mode()
+
+	if mode.IsDir() {
+		limitResult := guard.addEntry(file.Name, 0, 0)
+
+		if limitResult.IsErr() {
+			return limitResult
 		}
 
-		extractedFile, err := os.Create(extractedFilePath)
+		return guard.createDirectory(path, mode)
+	}
+
+	limitResult := guard.addEntry(file.Name, int64(file.CompressedSize64), int64(file.UncompressedSize64))
 
+	if limitResult.IsErr() {
+		return limitResult
+	}
+
+	if !mode.IsRegular() && mode&os.ModeSymlink == 0 {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("entry '%s' has an unsupported type '%s'", file.Name, mode.Type())))
+	}
+
+	rc, err := file.Open()
+
+	if err != nil {
+		return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to open file within package data: %s", err)))
+	}
+
+	defer func(rc io.ReadCloser) {
+		err := rc.Close()
 		if err != nil {
-			return core.Err[core.Empty, core.Error](*core.NewError(core.ExtractionFailure, fmt.Sprintf("failed to create extracted file: %s", err)))
+			z.logger.Warn("Failed to close previously opened file.", zap.String("err", err.Error()))
 		}
-		defer func(extractedFile *os.File) {
-			err := extractedFile.Close()
-			if err != nil {
-				z.logger.Warn("Failed to close extracted file.", zap.String("err", err.Error()))
-			}
-		}(extractedFile)
+	}(rc)
 
-		_, err = io.Copy(extractedFile, rc)
+	if mode&os.ModeSymlink != 0 {
+		linkTarget, err := io.ReadAll(&limitedReader{reader: rc, remaining: maxSymlinkTargetLength})
 
 		if err != nil {
-			return core.Err[core.Empty, core.Error](*core.NewError(core.ConfigurationRetrievalFailure, fmt.Sprintf("failed to copy package data's file content: %s", err)))
+			return core.Err[core.Empty, core.Error](*core.NewError(core.UnsafePackageEntry, fmt.Sprintf("failed to read symbolic link '%s': %s", file.Name, err)))
 		}
+
+		return guard.addSymlink(file.Name, path, string(linkTarget))
 	}
 
-	return core.Ok[core.Empty, core.Error](core.Empty{})
+	return guard.writeFile(file.Name, path, rc, mode)
 }
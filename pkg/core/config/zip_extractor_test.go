@@ -1,14 +1,19 @@
 package config
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/simpleg-eu/cuplan_core/pkg/core"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -43,7 +48,7 @@ func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_ValidZip_ExtractsExpect
 	_, configFileErr := os.Stat(fmt.Sprintf("%s/config/config.yaml", targetPath))
 	_, logConfigFileErr := os.Stat(fmt.Sprintf("%s/config/log4rs.yaml", targetPath))
 	_, anotherFileErr := os.Stat(fmt.Sprintf("%s/config/subfolder/another.yaml", targetPath))
-	_ = os.RemoveAll(targetPath)
+	_ = RemoveExtraction(targetPath)
 	assert.True(z.T(), result.IsOk())
 	assert.True(z.T(), testDataPathResult)
 	assert.Equal(z.T(), nil, executableErr, "Expected executable file does not exist.")
@@ -52,6 +57,181 @@ func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_ValidZip_ExtractsExpect
 	assert.Equal(z.T(), nil, anotherFileErr, "Expected another file does not exist.")
 }
 
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_ParentDirectoryEntry_ReturnsUnsafePackageEntry() {
+	targetPath := uuid.New().String()
+	packageData := createZip(map[string]string{"config/../../escaped.yaml": "Value: 1"})
+
+	result := z.Extractor.Extract(packageData, targetPath)
+
+	_, escapedFileErr := os.Stat("escaped.yaml")
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+	assert.True(z.T(), os.IsNotExist(escapedFileErr))
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_AbsoluteEntry_ReturnsUnsafePackageEntry() {
+	targetPath := uuid.New().String()
+	packageData := createZip(map[string]string{"/tmp/absolute.yaml": "Value: 1"})
+
+	result := z.Extractor.Extract(packageData, targetPath)
+
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_SymlinkOutsideTargetPath_ReturnsUnsafePackageEntry() {
+	targetPath := uuid.New().String()
+	packageData := createZipWithHeaders([]zipEntry{{Name: "config/link", Content: "../../outside", Mode: os.ModeSymlink | 0777}})
+
+	result := z.Extractor.Extract(packageData, targetPath)
+
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_ChainedSymlinks_ReturnsUnsafePackageEntry() {
+	rootPath := uuid.New().String()
+	targetPath := filepath.Join(rootPath, "a", "b", "c", "target")
+	packageData := createZipWithHeaders([]zipEntry{
+		{Name: "a/b/c", Content: "../..", Mode: os.ModeSymlink | 0777},
+		{Name: "a/b/c/x", Content: "../../..", Mode: os.ModeSymlink | 0777},
+		{Name: "a/b/c/x/escaped.txt", Content: "escaped", Mode: 0644},
+	})
+
+	result := z.Extractor.Extract(packageData, targetPath)
+
+	escapedFiles := findFiles(rootPath, "escaped.txt", targetPath)
+	_ = os.RemoveAll(rootPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+	assert.Empty(z.T(), escapedFiles)
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_SymlinksEscapingThroughEachOther_ReturnsUnsafePackageEntry() {
+	rootPath := uuid.New().String()
+	targetPath := filepath.Join(rootPath, "target")
+	packageData := createZipWithHeaders([]zipEntry{
+		{Name: "d/l", Content: "x/../..", Mode: os.ModeSymlink | 0777},
+		{Name: "d/x", Content: ".", Mode: os.ModeSymlink | 0777},
+	})
+
+	result := z.Extractor.Extract(packageData, targetPath)
+
+	_ = os.RemoveAll(rootPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.UnsafePackageEntry, result.UnwrapErr().ErrorKind)
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_SymlinkWithinTargetPath_CreatesSymlink() {
+	targetPath := uuid.New().String()
+	packageData := createZipWithHeaders([]zipEntry{
+		{Name: "config/application.yaml", Content: "Value: 1", Mode: 0644},
+		{Name: "application.yaml", Content: "config/application.yaml", Mode: os.ModeSymlink | 0777},
+	})
+
+	result := z.Extractor.Extract(packageData, targetPath)
+
+	linkTarget, linkErr := os.Readlink(fmt.Sprintf("%s/application.yaml", targetPath))
+	content, contentErr := os.ReadFile(fmt.Sprintf("%s/application.yaml", targetPath))
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsOk())
+	assert.Nil(z.T(), linkErr)
+	assert.Equal(z.T(), "config/application.yaml", linkTarget)
+	assert.Nil(z.T(), contentErr)
+	assert.Equal(z.T(), "Value: 1", string(content))
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_ExecutableFile_PreservesPermissions() {
+	targetPath := uuid.New().String()
+	packageData := createZipWithHeaders([]zipEntry{
+		{Name: "cp-config", Content: "#!/bin/sh", Mode: 0755},
+		{Name: "secret.yaml", Content: "Value: 1", Mode: 0600},
+	})
+
+	result := z.Extractor.Extract(packageData, targetPath)
+
+	executableInfo, executableErr := os.Stat(fmt.Sprintf("%s/cp-config", targetPath))
+	secretInfo, secretErr := os.Stat(fmt.Sprintf("%s/secret.yaml", targetPath))
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsOk())
+	assert.Nil(z.T(), executableErr)
+	assert.Nil(z.T(), secretErr)
+	assert.Equal(z.T(), os.FileMode(0755), executableInfo.Mode().Perm())
+	assert.Equal(z.T(), os.FileMode(0600), secretInfo.Mode().Perm())
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_TooManyFiles_ReturnsPackageLimitExceeded() {
+	targetPath := uuid.New().String()
+	logger, _ := zap.NewDevelopment()
+	extractor := NewZipExtractorWithLimits(logger, ExtractionLimits{MaxFiles: 2})
+	packageData := createZip(map[string]string{"a.yaml": "A: 1", "b.yaml": "B: 1", "c.yaml": "C: 1"})
+
+	result := extractor.Extract(packageData, targetPath)
+
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_TooManyDirectories_ReturnsPackageLimitExceeded() {
+	targetPath := uuid.New().String()
+	logger, _ := zap.NewDevelopment()
+	extractor := NewZipExtractorWithLimits(logger, ExtractionLimits{MaxFiles: 2})
+	packageData := createZipWithHeaders([]zipEntry{
+		{Name: "a/", Mode: os.ModeDir | 0755},
+		{Name: "b/", Mode: os.ModeDir | 0755},
+		{Name: "c/", Mode: os.ModeDir | 0755},
+	})
+
+	result := extractor.Extract(packageData, targetPath)
+
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_FileTooBig_ReturnsPackageLimitExceeded() {
+	targetPath := uuid.New().String()
+	logger, _ := zap.NewDevelopment()
+	extractor := NewZipExtractorWithLimits(logger, ExtractionLimits{MaxFileSize: 16})
+	packageData := createZip(map[string]string{"big.yaml": strings.Repeat("Value: 1\n", 10)})
+
+	result := extractor.Extract(packageData, targetPath)
+
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_TotalSizeTooBig_ReturnsPackageLimitExceeded() {
+	targetPath := uuid.New().String()
+	logger, _ := zap.NewDevelopment()
+	extractor := NewZipExtractorWithLimits(logger, ExtractionLimits{MaxTotalSize: 100})
+	packageData := createZip(map[string]string{"a.yaml": strings.Repeat("A", 60), "b.yaml": strings.Repeat("B", 60)})
+
+	result := extractor.Extract(packageData, targetPath)
+
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
+func (z *ZipExtractorTestSuite) TestZipExtractor_Extract_CompressionRatioTooHigh_ReturnsPackageLimitExceeded() {
+	targetPath := uuid.New().String()
+	logger, _ := zap.NewDevelopment()
+	extractor := NewZipExtractorWithLimits(logger, ExtractionLimits{MaxCompressionRatio: 10})
+	packageData := createZip(map[string]string{"bomb.yaml": strings.Repeat("0", 1<<20)})
+
+	result := extractor.Extract(packageData, targetPath)
+
+	_ = RemoveExtraction(targetPath)
+	assert.True(z.T(), result.IsErr())
+	assert.Equal(z.T(), core.PackageLimitExceeded, result.UnwrapErr().ErrorKind)
+}
+
 func doesDirectoryExist(directory string) bool {
 	_, err := os.Stat(directory)
 
@@ -65,3 +245,64 @@ func doesDirectoryExist(directory string) bool {
 
 	return false
 }
+
+func createZip(files map[string]string) []byte {
+	buffer := new(bytes.Buffer)
+	writer := zip.NewWriter(buffer)
+
+	for name, content := range files {
+		fileWriter, err := writer.Create(name)
+
+		if err != nil {
+			panic(err)
+		}
+
+		_, _ = fileWriter.Write([]byte(content))
+	}
+
+	_ = writer.Close()
+
+	return buffer.Bytes()
+}
+
+type zipEntry struct {
+	Name    string
+	Content string
+	Mode    os.FileMode
+}
+
+func createZipWithHeaders(entries []zipEntry) []byte {
+	buffer := new(bytes.Buffer)
+	writer := zip.NewWriter(buffer)
+
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.Name, Method: zip.Deflate}
+		header.SetMode(entry.Mode)
+		fileWriter, err := writer.CreateHeader(header)
+
+		if err != nil {
+			panic(err)
+		}
+
+		_, _ = fileWriter.Write([]byte(entry.Content))
+	}
+
+	_ = writer.Close()
+
+	return buffer.Bytes()
+}
+
+// findFiles returns the paths of the files with the name under the root path, except for those within the excluded path.
+func findFiles(rootPath string, name string, excludedPath string) []string {
+	paths := make([]string, 0)
+
+	_ = filepath.WalkDir(rootPath, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.Name() == name && !isWithinPath(excludedPath, path) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+
+	return paths
+}
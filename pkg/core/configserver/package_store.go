@@ -0,0 +1,302 @@
+package configserver
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const packageExtension = ".zip"
+
+// tenantsDirectory contains the tenants' packages; it cannot be mistaken for a stage, which starts with a letter or digit.
+const tenantsDirectory = ".tenants"
+
+// namePattern restricts the stages, environments, components and versions to names which cannot escape the root.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// PackageStore stores the versioned history of every configuration package within a directory, with the layout
+// '<root>/<stage>/<environment>/<component>/<version>.zip'. The latest version is the most recently created one.
+// The tenants' packages are stored apart, see ForTenant.
+type PackageStore struct {
+	root string
+	// packageStoreState is shared with the stores of the tenants.
+	*packageStoreState
+}
+
+type packageStoreState struct {
+	// published is closed, and replaced, whenever a version is published through Publish.
+	published      chan struct{}
+	publishedMutex sync.Mutex
+	// digests caches the digest of every version file by path, so the files are only read again once they change.
+	digests      map[string]cachedDigest
+	digestsMutex sync.Mutex
+}
+
+// cachedDigest is the digest of a version file along with the modification time and size it was computed for.
+type cachedDigest struct {
+	modTime time.Time
+	size    int64
+	digest  string
+}
+
+func NewPackageStore(root string) *PackageStore {
+	store := new(PackageStore)
+	store.root = root
+	store.packageStoreState = new(packageStoreState)
+	store.published = make(chan struct{})
+	store.digests = make(map[string]cachedDigest)
+
+	return store
+}
+
+// ForTenant returns the store of the tenant's packages, with the layout
+// '<root>/.tenants/<tenant>/<stage>/<environment>/<component>/<version>.zip'. A tenant only gets its own packages,
+// never the ones shared by every client which does not specify a tenant.
+func (p *PackageStore) ForTenant(tenant string) core.Result[*PackageStore, core.Error] {
+	if !namePattern.MatchString(tenant) {
+		return core.Err[*PackageStore, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("invalid tenant '%s'", tenant)))
+	}
+
+	store := new(PackageStore)
+	store.root = filepath.Join(p.root, tenantsDirectory, tenant)
+	store.packageStoreState = p.packageStoreState
+
+	return core.Ok[*PackageStore, core.Error](store)
+}
+
+// ListVersions returns the versions of the configuration package, from the latest to the oldest.
+func (p *PackageStore) ListVersions(stage string, environment string, component string) core.Result[[]config.PackageVersion, core.Error] {
+	directoryResult := p.getPackageDirectory(stage, environment, component)
+
+	if directoryResult.IsErr() {
+		return core.Err[[]config.PackageVersion, core.Error](directoryResult.UnwrapErr())
+	}
+
+	directory := directoryResult.Unwrap()
+	entries, err := os.ReadDir(directory)
+
+	if os.IsNotExist(err) {
+		return core.Err[[]config.PackageVersion, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find configuration package '%s/%s/%s'", stage, environment, component)))
+	}
+
+	if err != nil {
+		return core.Err[[]config.PackageVersion, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read package directory: %s", err)))
+	}
+
+	versions := make([]config.PackageVersion, 0, len(entries))
+
+	for _, entry := range entries {
+		version := strings.TrimSuffix(entry.Name(), packageExtension)
+
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), packageExtension) || !namePattern.MatchString(version) {
+			continue
+		}
+
+		versionResult := p.describeVersion(filepath.Join(directory, entry.Name()), version)
+
+		if versionResult.IsErr() {
+			return core.Err[[]config.PackageVersion, core.Error](versionResult.UnwrapErr())
+		}
+
+		versions = append(versions, versionResult.Unwrap())
+	}
+
+	if len(versions) == 0 {
+		return core.Err[[]config.PackageVersion, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("configuration package '%s/%s/%s' has no versions", stage, environment, component)))
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].CreatedAt.Equal(versions[j].CreatedAt) {
+			return versions[i].Version > versions[j].Version
+		}
+
+		return versions[i].CreatedAt.After(versions[j].CreatedAt)
+	})
+
+	return core.Ok[[]config.PackageVersion, core.Error](versions)
+}
+
+// Read returns the specified version of the configuration package. An empty version means the latest one.
+func (p *PackageStore) Read(stage string, environment string, component string, version string) core.Result[StoredPackage, core.Error] {
+	if len(version) == 0 {
+		versionsResult := p.ListVersions(stage, environment, component)
+
+		if versionsResult.IsErr() {
+			return core.Err[StoredPackage, core.Error](versionsResult.UnwrapErr())
+		}
+
+		version = versionsResult.Unwrap()[0].Version
+	}
+
+	pathResult := p.getVersionPath(stage, environment, component, version)
+
+	if pathResult.IsErr() {
+		return core.Err[StoredPackage, core.Error](pathResult.UnwrapErr())
+	}
+
+	data, err := os.ReadFile(pathResult.Unwrap())
+
+	if os.IsNotExist(err) {
+		return core.Err[StoredPackage, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find version '%s' of configuration package '%s/%s/%s'", version, stage, environment, component)))
+	}
+
+	if err != nil {
+		return core.Err[StoredPackage, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read configuration package: %s", err)))
+	}
+
+	info, err := os.Stat(pathResult.Unwrap())
+
+	if err != nil {
+		return core.Err[StoredPackage, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read configuration package: %s", err)))
+	}
+
+	return core.Ok[StoredPackage, core.Error](StoredPackage{
+		PackageVersion: config.PackageVersion{Version: version, Digest: config.ComputePackageDigest(data), Size: int64(len(data)), CreatedAt: info.ModTime().UTC()},
+		Data:           data,
+	})
+}
+
+// Publish stores a new version of the configuration package. Versions are immutable, so publishing an existing
+// version fails.
+func (p *PackageStore) Publish(stage string, environment string, component string, version string, data []byte) core.Result[config.PackageVersion, core.Error] {
+	pathResult := p.getVersionPath(stage, environment, component, version)
+
+	if pathResult.IsErr() {
+		return core.Err[config.PackageVersion, core.Error](pathResult.UnwrapErr())
+	}
+
+	path := pathResult.Unwrap()
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return core.Err[config.PackageVersion, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to create package directory: %s", err)))
+	}
+
+	temporaryPath := path + ".tmp"
+
+	if err := os.WriteFile(temporaryPath, data, 0644); err != nil {
+		return core.Err[config.PackageVersion, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to write configuration package: %s", err)))
+	}
+
+	// Link fails if the version already exists, unlike Rename.
+	err := os.Link(temporaryPath, path)
+	_ = os.Remove(temporaryPath)
+
+	if os.IsExist(err) {
+		return core.Err[config.PackageVersion, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("version '%s' of configuration package '%s/%s/%s' already exists", version, stage, environment, component)))
+	}
+
+	if err != nil {
+		return core.Err[config.PackageVersion, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to write configuration package: %s", err)))
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return core.Err[config.PackageVersion, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read configuration package: %s", err)))
+	}
+
+	digest := config.ComputePackageDigest(data)
+	p.setDigest(path, info, digest)
+	p.notifyPublished()
+
+	return core.Ok[config.PackageVersion, core.Error](config.PackageVersion{Version: version, Digest: digest, Size: info.Size(), CreatedAt: info.ModTime().UTC()})
+}
+
+// Published returns a channel which is closed as soon as a version of any configuration package is published
+// through Publish. Versions copied into the root by other means are not notified.
+func (p *PackageStore) Published() <-chan struct{} {
+	p.publishedMutex.Lock()
+	defer p.publishedMutex.Unlock()
+
+	return p.published
+}
+
+func (p *PackageStore) notifyPublished() {
+	p.publishedMutex.Lock()
+	defer p.publishedMutex.Unlock()
+
+	close(p.published)
+	p.published = make(chan struct{})
+}
+
+// StoredPackage is a version of a configuration package along with its data.
+type StoredPackage struct {
+	config.PackageVersion
+	Data []byte
+}
+
+// describeVersion describes the version file. Its digest is cached and only computed again once the file's
+// modification time or size changes, because ListVersions runs on every poll of every client.
+func (p *PackageStore) describeVersion(path string, version string) core.Result[config.PackageVersion, core.Error] {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return core.Err[config.PackageVersion, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read configuration package: %s", err)))
+	}
+
+	digest, cached := p.getDigest(path, info)
+
+	if !cached {
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			return core.Err[config.PackageVersion, core.Error](*core.NewError(core.IOFailure, fmt.Sprintf("failed to read configuration package: %s", err)))
+		}
+
+		digest = config.ComputePackageDigest(data)
+		p.setDigest(path, info, digest)
+	}
+
+	return core.Ok[config.PackageVersion, core.Error](config.PackageVersion{Version: version, Digest: digest, Size: info.Size(), CreatedAt: info.ModTime().UTC()})
+}
+
+func (p *PackageStore) getDigest(path string, info os.FileInfo) (string, bool) {
+	p.digestsMutex.Lock()
+	defer p.digestsMutex.Unlock()
+
+	entry, exists := p.digests[path]
+
+	if !exists || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		return "", false
+	}
+
+	return entry.digest, true
+}
+
+func (p *PackageStore) setDigest(path string, info os.FileInfo, digest string) {
+	p.digestsMutex.Lock()
+	defer p.digestsMutex.Unlock()
+
+	p.digests[path] = cachedDigest{modTime: info.ModTime(), size: info.Size(), digest: digest}
+}
+
+func (p *PackageStore) getPackageDirectory(stage string, environment string, component string) core.Result[string, core.Error] {
+	for _, name := range []string{stage, environment, component} {
+		if !namePattern.MatchString(name) {
+			return core.Err[string, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("invalid name '%s'", name)))
+		}
+	}
+
+	return core.Ok[string, core.Error](filepath.Join(p.root, stage, environment, component))
+}
+
+func (p *PackageStore) getVersionPath(stage string, environment string, component string, version string) core.Result[string, core.Error] {
+	if !namePattern.MatchString(version) {
+		return core.Err[string, core.Error](*core.NewError(core.InvalidInput, fmt.Sprintf("invalid version '%s'", version)))
+	}
+
+	directoryResult := p.getPackageDirectory(stage, environment, component)
+
+	if directoryResult.IsErr() {
+		return directoryResult
+	}
+
+	return core.Ok[string, core.Error](filepath.Join(directoryResult.Unwrap(), version+packageExtension))
+}
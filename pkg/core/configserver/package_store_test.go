@@ -0,0 +1,135 @@
+package configserver
+
+import (
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const stage = "dummy"
+const environment = "development"
+const component = "dummy"
+
+type PackageStoreTestSuite struct {
+	suite.Suite
+	Root  string
+	Store *PackageStore
+}
+
+func TestPackageStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(PackageStoreTestSuite))
+}
+
+func (p *PackageStoreTestSuite) SetupTest() {
+	p.Root = p.T().TempDir()
+	p.Store = NewPackageStore(p.Root)
+}
+
+func (p *PackageStoreTestSuite) TestListVersions_ReturnsLatestFirst() {
+	publishVersion(p.Store, "1.0.0", []byte("first"), time.Now().Add(-time.Hour))
+	publishVersion(p.Store, "1.1.0", []byte("second"), time.Now())
+
+	result := p.Store.ListVersions(stage, environment, component)
+
+	assert.True(p.T(), result.IsOk())
+	versions := result.Unwrap()
+	assert.Len(p.T(), versions, 2)
+	assert.Equal(p.T(), "1.1.0", versions[0].Version)
+	assert.Equal(p.T(), config.ComputePackageDigest([]byte("second")), versions[0].Digest)
+	assert.Equal(p.T(), "1.0.0", versions[1].Version)
+}
+
+func (p *PackageStoreTestSuite) TestRead_NoVersion_ReturnsLatest() {
+	publishVersion(p.Store, "1.0.0", []byte("first"), time.Now().Add(-time.Hour))
+	publishVersion(p.Store, "1.1.0", []byte("second"), time.Now())
+
+	result := p.Store.Read(stage, environment, component, "")
+
+	assert.True(p.T(), result.IsOk())
+	assert.Equal(p.T(), "1.1.0", result.Unwrap().Version)
+	assert.Equal(p.T(), []byte("second"), result.Unwrap().Data)
+}
+
+func (p *PackageStoreTestSuite) TestRead_SpecificVersion_ReturnsVersion() {
+	publishVersion(p.Store, "1.0.0", []byte("first"), time.Now().Add(-time.Hour))
+	publishVersion(p.Store, "1.1.0", []byte("second"), time.Now())
+
+	result := p.Store.Read(stage, environment, component, "1.0.0")
+
+	assert.True(p.T(), result.IsOk())
+	assert.Equal(p.T(), []byte("first"), result.Unwrap().Data)
+}
+
+func (p *PackageStoreTestSuite) TestRead_MissingPackage_NotFound() {
+	result := p.Store.Read(stage, environment, component, "")
+
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+}
+
+func (p *PackageStoreTestSuite) TestRead_EscapingName_InvalidInput() {
+	result := p.Store.Read("..", environment, component, "")
+
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func (p *PackageStoreTestSuite) TestPublish_ExistingVersion_InvalidInput() {
+	p.Store.Publish(stage, environment, component, "1.0.0", []byte("first"))
+
+	result := p.Store.Publish(stage, environment, component, "1.0.0", []byte("second"))
+
+	content, _ := os.ReadFile(filepath.Join(p.Root, stage, environment, component, "1.0.0.zip"))
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+	assert.Equal(p.T(), []byte("first"), content)
+}
+
+func (p *PackageStoreTestSuite) TestListVersions_ReplacedFile_RecomputesDigest() {
+	publishVersion(p.Store, "1.0.0", []byte("first"), time.Now().Add(-time.Hour))
+	_ = p.Store.ListVersions(stage, environment, component)
+	publishVersion(p.Store, "1.1.0", []byte("second"), time.Now())
+	path := p.Store.getVersionPath(stage, environment, component, "1.0.0").Unwrap()
+	_ = os.WriteFile(path, []byte("replaced"), 0644)
+
+	result := p.Store.ListVersions(stage, environment, component)
+
+	assert.True(p.T(), result.IsOk())
+	assert.Equal(p.T(), "1.0.0", result.Unwrap()[0].Version)
+	assert.Equal(p.T(), config.ComputePackageDigest([]byte("replaced")), result.Unwrap()[0].Digest)
+	assert.Equal(p.T(), config.ComputePackageDigest([]byte("second")), result.Unwrap()[1].Digest)
+}
+
+// publishVersion publishes a version with the specified creation time, so the versions' order is deterministic.
+func (p *PackageStoreTestSuite) TestForTenant_KeepsTenantsApart() {
+	publishVersion(p.Store, "1.0.0", []byte("shared"), time.Now())
+	tenant := p.Store.ForTenant("acme").Unwrap()
+	tenant.Publish(stage, environment, component, "1.0.0", []byte("acme"))
+
+	sharedResult := p.Store.Read(stage, environment, component, "")
+	tenantResult := tenant.Read(stage, environment, component, "")
+	otherResult := p.Store.ForTenant("other").Unwrap().Read(stage, environment, component, "")
+
+	assert.Equal(p.T(), []byte("shared"), sharedResult.Unwrap().Data)
+	assert.Equal(p.T(), []byte("acme"), tenantResult.Unwrap().Data)
+	assert.True(p.T(), otherResult.IsErr())
+	assert.Equal(p.T(), core.NotFound, otherResult.UnwrapErr().ErrorKind)
+}
+
+func (p *PackageStoreTestSuite) TestForTenant_PathTraversal_InvalidInput() {
+	result := p.Store.ForTenant("../acme")
+
+	assert.True(p.T(), result.IsErr())
+	assert.Equal(p.T(), core.InvalidInput, result.UnwrapErr().ErrorKind)
+}
+
+func publishVersion(store *PackageStore, version string, data []byte, createdAt time.Time) {
+	store.Publish(stage, environment, component, version, data).Unwrap()
+	path := store.getVersionPath(stage, environment, component, version).Unwrap()
+	_ = os.Chtimes(path, createdAt, createdAt)
+}
@@ -0,0 +1,355 @@
+package configserver
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/middleware"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ReadPermission is the permission required by default to download configuration packages.
+const ReadPermission = "read:config"
+
+// DefaultEventPollInterval is how often the event streams look for versions copied into the store's root.
+const DefaultEventPollInterval = 5 * time.Second
+
+// MaxLongPollTimeout is the longest a long-poll request is held open, whatever timeout it asks for.
+const MaxLongPollTimeout = time.Minute
+
+// Server serves the configuration packages of a PackageStore to the config.ServerDownloader.
+//
+// Every request may specify a 'tenant' to be served the tenant's packages instead, see PackageStore.ForTenant.
+//
+// * 'GET /config?stage=&environment=&component=[&version=]' - returns the latest or the specified version of
+// the package, along with its 'ETag', digest, signature and version headers. Conditional requests are supported.
+//
+// * 'GET /config/versions?stage=&environment=&component=' - returns the package's versions as JSON, from the latest
+// to the oldest.
+//
+// * 'GET /config/events?stage=&environment=&component=' - streams the package's publications as server-sent events,
+// starting with the latest version. See config.ServerDownloader.WatchPublications.
+//
+// * 'GET /config/poll?stage=&environment=&component=&digest=&timeout=' - long-polls the package's publications: it
+// returns the latest version as JSON as soon as its digest differs from the specified one, or '204 No Content'
+// once the timeout, in seconds, expires. See config.ServerDownloader.SetLongPollTimeout.
+type Server struct {
+	logger *zap.Logger
+	store  *PackageStore
+	// authorization is optional; when set, requests require a valid bearer token with the permission.
+	authorization *middleware.Authorization
+	permission    string
+	// signingKey is optional; when set, packages are served along with their Ed25519 signature.
+	signingKey ed25519.PrivateKey
+	// eventPollInterval is how often the event streams look for new versions, besides the ones published through the
+	// PackageStore; it's also the interval of their keep-alive comments.
+	eventPollInterval time.Duration
+}
+
+func NewServer(logger *zap.Logger, store *PackageStore) *Server {
+	s := new(Server)
+	s.logger = logger
+	s.store = store
+	s.permission = ReadPermission
+	s.eventPollInterval = DefaultEventPollInterval
+
+	return s
+}
+
+// SetAuthorization makes the Server require a bearer token, validated by the authorization, which contains the permission.
+// An empty permission only requires a valid token.
+func (s *Server) SetAuthorization(authorization *middleware.Authorization, permission string) {
+	s.authorization = authorization
+	s.permission = permission
+}
+
+// SetSigningKey makes the Server sign the packages it serves, so they can be verified by a config.PackageVerifier.
+func (s *Server) SetSigningKey(signingKey ed25519.PrivateKey) {
+	s.signingKey = signingKey
+}
+
+// SetEventPollInterval sets how often the event streams look for versions copied into the store's root by other
+// means than PackageStore.Publish, whose versions are streamed right away.
+func (s *Server) SetEventPollInterval(eventPollInterval time.Duration) {
+	s.eventPollInterval = eventPollInterval
+}
+
+// Router returns the router which serves the configuration packages.
+func (s *Server) Router() chi.Router {
+	router := chi.NewRouter()
+
+	router.Group(func(r chi.Router) {
+		if s.authorization != nil {
+			r.Use(s.authorization.Authorize)
+		}
+
+		r.Get("/config", s.getPackage)
+		r.Get("/config/versions", s.getVersions)
+		r.Get("/config/events", s.getEvents)
+		r.Get("/config/poll", s.getPoll)
+	})
+
+	return router
+}
+
+func (s *Server) getPackage(w http.ResponseWriter, r *http.Request) {
+	if !s.hasPermission(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	storeResult := s.getStore(query)
+
+	if storeResult.IsErr() {
+		s.writeError(w, storeResult.UnwrapErr())
+		return
+	}
+
+	packageResult := storeResult.Unwrap().Read(query.Get("stage"), query.Get("environment"), query.Get("component"), query.Get("version"))
+
+	if packageResult.IsErr() {
+		s.writeError(w, packageResult.UnwrapErr())
+		return
+	}
+
+	storedPackage := packageResult.Unwrap()
+
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", storedPackage.Digest))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set(config.PackageDigestHeader, storedPackage.Digest)
+	w.Header().Set(config.PackageVersionHeader, storedPackage.Version)
+
+	if s.signingKey != nil {
+		w.Header().Set(config.PackageSignatureHeader, config.SignPackage(s.signingKey, storedPackage.Data))
+	}
+
+	// ServeContent answers the conditional requests based on the 'ETag' and the modification time.
+	http.ServeContent(w, r, storedPackage.Version, storedPackage.CreatedAt, bytes.NewReader(storedPackage.Data))
+}
+
+func (s *Server) getVersions(w http.ResponseWriter, r *http.Request) {
+	if !s.hasPermission(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	storeResult := s.getStore(query)
+
+	if storeResult.IsErr() {
+		s.writeError(w, storeResult.UnwrapErr())
+		return
+	}
+
+	versionsResult := storeResult.Unwrap().ListVersions(query.Get("stage"), query.Get("environment"), query.Get("component"))
+
+	if versionsResult.IsErr() {
+		s.writeError(w, versionsResult.UnwrapErr())
+		return
+	}
+
+	s.writeJson(w, http.StatusOK, versionsResult.Unwrap())
+}
+
+func (s *Server) getEvents(w http.ResponseWriter, r *http.Request) {
+	if !s.hasPermission(w, r) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		s.writeError(w, *core.NewError(core.InvalidConfiguration, "response writer does not support streaming"))
+		return
+	}
+
+	query := r.URL.Query()
+	stage, environment, component := query.Get("stage"), query.Get("environment"), query.Get("component")
+	storeResult := s.getStore(query)
+
+	if storeResult.IsErr() {
+		s.writeError(w, storeResult.UnwrapErr())
+		return
+	}
+
+	store := storeResult.Unwrap()
+
+	// A package without versions yet is streamed anyway, so its first version is pushed once published.
+	if versionsResult := store.ListVersions(stage, environment, component); versionsResult.IsErr() && versionsResult.UnwrapErr().ErrorKind != core.NotFound {
+		s.writeError(w, versionsResult.UnwrapErr())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(s.eventPollInterval)
+	defer ticker.Stop()
+
+	lastDigest := ""
+	polled := false
+
+	for {
+		// The channel is taken before looking for a new version, so a publication in between is not missed.
+		published := store.Published()
+		versionsResult := store.ListVersions(stage, environment, component)
+		var err error
+
+		if versionsResult.IsOk() && versionsResult.Unwrap()[0].Digest != lastDigest {
+			latest := versionsResult.Unwrap()[0]
+			lastDigest = latest.Digest
+			err = s.writeEvent(w, config.PackagePublishedEvent, latest)
+		} else if polled {
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+
+		if err != nil {
+			s.logger.Info("Event stream closed.", zap.String("err", err.Error()))
+			return
+		}
+
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-published:
+			polled = false
+		case <-ticker.C:
+			polled = true
+		}
+	}
+}
+
+func (s *Server) getPoll(w http.ResponseWriter, r *http.Request) {
+	if !s.hasPermission(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	stage, environment, component, digest := query.Get("stage"), query.Get("environment"), query.Get("component"), query.Get("digest")
+	storeResult := s.getStore(query)
+
+	if storeResult.IsErr() {
+		s.writeError(w, storeResult.UnwrapErr())
+		return
+	}
+
+	store := storeResult.Unwrap()
+	timeout := MaxLongPollTimeout
+
+	if len(query.Get("timeout")) > 0 {
+		seconds, err := strconv.Atoi(query.Get("timeout"))
+
+		if err != nil || seconds <= 0 {
+			s.writeError(w, *core.NewError(core.InvalidInput, fmt.Sprintf("invalid timeout '%s'", query.Get("timeout"))))
+			return
+		}
+
+		timeout = min(time.Duration(seconds)*time.Second, MaxLongPollTimeout)
+	}
+
+	// A package without versions yet is polled anyway, so its first version is returned once published.
+	if versionsResult := store.ListVersions(stage, environment, component); versionsResult.IsErr() && versionsResult.UnwrapErr().ErrorKind != core.NotFound {
+		s.writeError(w, versionsResult.UnwrapErr())
+		return
+	}
+
+	expired := time.NewTimer(timeout)
+	defer expired.Stop()
+	ticker := time.NewTicker(s.eventPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// The channel is taken before looking for a new version, so a publication in between is not missed.
+		published := store.Published()
+		versionsResult := store.ListVersions(stage, environment, component)
+
+		if versionsResult.IsOk() && versionsResult.Unwrap()[0].Digest != digest {
+			s.writeJson(w, http.StatusOK, versionsResult.Unwrap()[0])
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expired.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-published:
+		case <-ticker.C:
+		}
+	}
+}
+
+// getStore returns the store of the request's tenant, if any.
+func (s *Server) getStore(query url.Values) core.Result[*PackageStore, core.Error] {
+	tenant := query.Get("tenant")
+
+	if len(tenant) == 0 {
+		return core.Ok[*PackageStore, core.Error](s.store)
+	}
+
+	return s.store.ForTenant(tenant)
+}
+
+func (s *Server) writeEvent(w http.ResponseWriter, event string, value any) error {
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+
+	return err
+}
+
+func (s *Server) hasPermission(w http.ResponseWriter, r *http.Request) bool {
+	if s.authorization == nil || len(s.permission) == 0 {
+		return true
+	}
+
+	return middleware.HasRequestPermissionTo(w, r, s.logger, s.permission)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err core.Error) {
+	statusCode := http.StatusInternalServerError
+
+	switch err.ErrorKind {
+	case core.InvalidInput:
+		statusCode = http.StatusBadRequest
+	case core.NotFound:
+		statusCode = http.StatusNotFound
+	default:
+		s.logger.Warn("Failed to serve configuration package.", zap.String("err", err.Message))
+	}
+
+	s.writeJson(w, statusCode, err)
+}
+
+func (s *Server) writeJson(w http.ResponseWriter, statusCode int, value any) {
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		s.logger.Warn("Failed to json marshal response.", zap.String("err", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(data); err != nil {
+		s.logger.Warn("Failed to write response.", zap.String("err", err.Error()))
+	}
+}
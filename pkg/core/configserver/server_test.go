@@ -0,0 +1,322 @@
+package configserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const issuer = "https://issuer.simpleg.eu/"
+const audience = "https://config.simpleg.eu"
+
+type ServerTestSuite struct {
+	suite.Suite
+	Root       string
+	Store      *PackageStore
+	SigningKey jwk.Key
+	HttpServer *httptest.Server
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.Root = s.T().TempDir()
+	s.Store = NewPackageStore(s.Root)
+	publishVersion(s.Store, "1.0.0", []byte("first"), time.Now().Add(-time.Hour))
+	publishVersion(s.Store, "1.1.0", []byte("second"), time.Now().Add(-time.Minute))
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	s.SigningKey, _ = jwk.New(rsaKey)
+	_ = s.SigningKey.Set(jwk.KeyIDKey, "test")
+	publicKey, _ := jwk.New(&rsaKey.PublicKey)
+	_ = publicKey.Set(jwk.KeyIDKey, "test")
+	_ = publicKey.Set(jwk.AlgorithmKey, jwa.RS256)
+	jwks := jwk.NewSet()
+	jwks.Add(publicKey)
+
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, s.Store)
+	server.SetAuthorization(middleware.NewAuthorization(logger, &jwks, audience, issuer), ReadPermission)
+	s.HttpServer = httptest.NewServer(server.Router())
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.HttpServer.Close()
+}
+
+func (s *ServerTestSuite) TestGetPackage_ValidToken_ReturnsLatestPackage() {
+	response := s.get("/config", ReadPermission, "")
+
+	assert.Equal(s.T(), http.StatusOK, response.StatusCode)
+	assert.Equal(s.T(), "1.1.0", response.Header.Get(config.PackageVersionHeader))
+	assert.Equal(s.T(), config.ComputePackageDigest([]byte("second")), response.Header.Get(config.PackageDigestHeader))
+	assert.Equal(s.T(), fmt.Sprintf("\"%s\"", config.ComputePackageDigest([]byte("second"))), response.Header.Get("ETag"))
+}
+
+func (s *ServerTestSuite) TestGetPackage_MatchingETag_NotModified() {
+	response := s.get("/config", ReadPermission, fmt.Sprintf("\"%s\"", config.ComputePackageDigest([]byte("second"))))
+
+	assert.Equal(s.T(), http.StatusNotModified, response.StatusCode)
+}
+
+func (s *ServerTestSuite) TestGetPackage_MissingToken_Unauthorized() {
+	response, _ := http.Get(s.HttpServer.URL + s.getQuery("/config"))
+
+	assert.Equal(s.T(), http.StatusUnauthorized, response.StatusCode)
+}
+
+func (s *ServerTestSuite) TestGetPackage_MissingPermission_Unauthorized() {
+	response := s.get("/config", "write:config", "")
+
+	var errorResponse core.Error
+	_ = json.NewDecoder(response.Body).Decode(&errorResponse)
+	assert.Equal(s.T(), http.StatusUnauthorized, response.StatusCode)
+	assert.Equal(s.T(), core.MissingPermission, errorResponse.ErrorKind)
+}
+
+func (s *ServerTestSuite) TestGetPackage_MissingComponent_NotFound() {
+	request, _ := http.NewRequest("GET", fmt.Sprintf("%s/config?stage=%s&environment=%s&component=missing", s.HttpServer.URL, stage, environment), nil)
+	request.Header.Set("Authorization", "Bearer "+s.createToken(ReadPermission))
+
+	response, _ := http.DefaultClient.Do(request)
+
+	assert.Equal(s.T(), http.StatusNotFound, response.StatusCode)
+}
+
+func (s *ServerTestSuite) TestGetVersions_ReturnsVersions() {
+	response := s.get("/config/versions", ReadPermission, "")
+
+	var versions []config.PackageVersion
+	err := json.NewDecoder(response.Body).Decode(&versions)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), http.StatusOK, response.StatusCode)
+	assert.Len(s.T(), versions, 2)
+	assert.Equal(s.T(), "1.1.0", versions[0].Version)
+	assert.Equal(s.T(), "1.0.0", versions[1].Version)
+}
+
+func (s *ServerTestSuite) TestServerDownloader_SignedPackage_DownloadsAndDetectsChanges() {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, s.Store)
+	server.SetSigningKey(privateKey)
+	httpServer := httptest.NewServer(server.Router())
+	defer httpServer.Close()
+	downloader := config.NewServerDownloader(logger, "token", time.Second)
+	verifier := config.NewPackageVerifier([]ed25519.PublicKey{publicKey}, true)
+
+	first := downloader.DownloadIfModified(httpServer.URL, stage, environment, component)
+	signature := downloader.PackageSignature(httpServer.URL, stage, environment, component).Unwrap()
+	second := downloader.DownloadIfModified(httpServer.URL, stage, environment, component)
+	s.Store.Publish(stage, environment, component, "1.2.0", []byte("third"))
+	third := downloader.DownloadIfModified(httpServer.URL, stage, environment, component)
+
+	assert.True(s.T(), first.IsOk())
+	assert.True(s.T(), verifier.Verify(first.Unwrap(), signature.Digest, signature.Signature).IsOk())
+	assert.Equal(s.T(), []byte("second"), first.Unwrap())
+	assert.True(s.T(), second.IsErr())
+	assert.Equal(s.T(), core.NotModified, second.UnwrapErr().ErrorKind)
+	assert.True(s.T(), third.IsOk())
+	assert.Equal(s.T(), []byte("third"), third.Unwrap())
+}
+
+func (s *ServerTestSuite) TestServerDownloader_Tenants_DownloadTheirOwnPackages() {
+	for _, tenant := range []string{"first", "second"} {
+		s.Store.ForTenant(tenant).Unwrap().Publish(stage, environment, component, "1.0.0", []byte(tenant))
+	}
+
+	logger, _ := zap.NewDevelopment()
+	httpServer := httptest.NewServer(NewServer(logger, s.Store).Router())
+	defer httpServer.Close()
+	packages := make(map[string][]byte)
+
+	for _, tenant := range []string{"first", "second", "missing"} {
+		downloader := config.NewServerDownloader(logger, "token", time.Second)
+		downloader.SetTenant(tenant)
+		result := downloader.Download(httpServer.URL, stage, environment, component)
+
+		if result.IsOk() {
+			packages[tenant] = result.Unwrap()
+		}
+	}
+
+	assert.Equal(s.T(), map[string][]byte{"first": []byte("first"), "second": []byte("second")}, packages)
+}
+
+func (s *ServerTestSuite) TestGetPackage_InvalidTenant_BadRequest() {
+	request, _ := http.NewRequest("GET", s.HttpServer.URL+s.getQuery("/config")+"&tenant=..", nil)
+	request.Header.Set("Authorization", "Bearer "+s.createToken(ReadPermission))
+
+	response, _ := http.DefaultClient.Do(request)
+
+	assert.Equal(s.T(), http.StatusBadRequest, response.StatusCode)
+}
+
+func (s *ServerTestSuite) TestServerDownloader_Versions_ListsAndDownloadsVersion() {
+	logger, _ := zap.NewDevelopment()
+	httpServer := httptest.NewServer(NewServer(logger, s.Store).Router())
+	defer httpServer.Close()
+	downloader := config.NewServerDownloader(logger, "token", time.Second)
+
+	versions := downloader.ListVersions(httpServer.URL, stage, environment, component)
+	versionedPackage := downloader.DownloadVersion(httpServer.URL, stage, environment, component, "1.0.0")
+
+	assert.True(s.T(), versions.IsOk())
+	assert.Equal(s.T(), "1.1.0", versions.Unwrap()[0].Version)
+	assert.True(s.T(), versionedPackage.IsOk())
+	assert.Equal(s.T(), "1.0.0", versionedPackage.Unwrap().Version)
+	assert.Equal(s.T(), []byte("first"), versionedPackage.Unwrap().Data)
+}
+
+func (s *ServerTestSuite) TestServerDownloader_WatchPublications_PushesLatestAndPublishedVersions() {
+	logger, _ := zap.NewDevelopment()
+	httpServer := httptest.NewServer(NewServer(logger, s.Store).Router())
+	// Registered before the stream, so the stream is closed first; Close waits for it.
+	s.T().Cleanup(httpServer.Close)
+	versions := s.watchPublications(httpServer.URL, 0)
+
+	latest := s.receiveVersion(versions)
+	s.Store.Publish(stage, environment, component, "1.2.0", []byte("third"))
+	published := s.receiveVersion(versions)
+
+	assert.Equal(s.T(), "1.1.0", latest.Version)
+	assert.Equal(s.T(), "1.2.0", published.Version)
+	assert.Equal(s.T(), config.ComputePackageDigest([]byte("third")), published.Digest)
+}
+
+func (s *ServerTestSuite) TestServerDownloader_WatchPublications_PushesCopiedVersions() {
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, s.Store)
+	server.SetEventPollInterval(10 * time.Millisecond)
+	httpServer := httptest.NewServer(server.Router())
+	s.T().Cleanup(httpServer.Close)
+	versions := s.watchPublications(httpServer.URL, 0)
+
+	_ = s.receiveVersion(versions)
+	_ = os.WriteFile(filepath.Join(s.Root, stage, environment, component, "2.0.0.zip"), []byte("copied"), 0644)
+	copied := s.receiveVersion(versions)
+
+	assert.Equal(s.T(), "2.0.0", copied.Version)
+}
+
+func (s *ServerTestSuite) TestServerDownloader_LongPollPublications_PushesLatestAndPublishedVersions() {
+	logger, _ := zap.NewDevelopment()
+	httpServer := httptest.NewServer(NewServer(logger, s.Store).Router())
+	s.T().Cleanup(httpServer.Close)
+	versions := s.watchPublications(httpServer.URL, time.Second)
+
+	latest := s.receiveVersion(versions)
+	s.Store.Publish(stage, environment, component, "1.2.0", []byte("third"))
+	published := s.receiveVersion(versions)
+
+	assert.Equal(s.T(), "1.1.0", latest.Version)
+	assert.Equal(s.T(), "1.2.0", published.Version)
+	assert.Equal(s.T(), config.ComputePackageDigest([]byte("third")), published.Digest)
+}
+
+func (s *ServerTestSuite) TestGetPoll_CurrentDigest_NoContentOnceExpired() {
+	query := fmt.Sprintf("/config/poll?stage=%s&environment=%s&component=%s&digest=%s&timeout=1", stage, environment, component, config.ComputePackageDigest([]byte("second")))
+	request, _ := http.NewRequest("GET", s.HttpServer.URL+query, nil)
+	request.Header.Set("Authorization", "Bearer "+s.createToken(ReadPermission))
+
+	response, err := http.DefaultClient.Do(request)
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), http.StatusNoContent, response.StatusCode)
+}
+
+func (s *ServerTestSuite) TestGetPoll_MissingToken_Unauthorized() {
+	response, _ := http.Get(s.HttpServer.URL + s.getQuery("/config/poll"))
+
+	assert.Equal(s.T(), http.StatusUnauthorized, response.StatusCode)
+}
+
+func (s *ServerTestSuite) TestGetEvents_MissingToken_Unauthorized() {
+	response, _ := http.Get(s.HttpServer.URL + s.getQuery("/config/events"))
+
+	assert.Equal(s.T(), http.StatusUnauthorized, response.StatusCode)
+}
+
+// watchPublications streams the publications into the returned channel until the test finishes. A long-poll
+// timeout makes the downloader long-poll them instead.
+func (s *ServerTestSuite) watchPublications(url string, longPollTimeout time.Duration) chan config.PackageVersion {
+	logger, _ := zap.NewDevelopment()
+	downloader := config.NewServerDownloader(logger, "token", time.Second)
+	downloader.SetLongPollTimeout(longPollTimeout)
+	versions := make(chan config.PackageVersion, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.T().Cleanup(cancel)
+
+	go downloader.WatchPublications(ctx, url, stage, environment, component, func(version config.PackageVersion) {
+		versions <- version
+	})
+
+	return versions
+}
+
+func (s *ServerTestSuite) receiveVersion(versions chan config.PackageVersion) config.PackageVersion {
+	select {
+	case version := <-versions:
+		return version
+	case <-time.After(2 * time.Second):
+		s.FailNow("no version has been pushed")
+		return config.PackageVersion{}
+	}
+}
+
+func (s *ServerTestSuite) get(path string, permission string, etag string) *http.Response {
+	request, _ := http.NewRequest("GET", s.HttpServer.URL+s.getQuery(path), nil)
+	request.Header.Set("Authorization", "Bearer "+s.createToken(permission))
+
+	if len(etag) > 0 {
+		request.Header.Set("If-None-Match", etag)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+
+	return response
+}
+
+func (s *ServerTestSuite) getQuery(path string) string {
+	return fmt.Sprintf("%s?stage=%s&environment=%s&component=%s", path, stage, environment, component)
+}
+
+func (s *ServerTestSuite) createToken(permission string) string {
+	token := jwt.New()
+	_ = token.Set(jwt.IssuerKey, issuer)
+	_ = token.Set(jwt.AudienceKey, audience)
+	_ = token.Set(jwt.ExpirationKey, time.Now().Add(time.Hour))
+	_ = token.Set("permissions", []string{permission})
+
+	signedToken, err := jwt.Sign(token, jwa.RS256, s.SigningKey)
+
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+
+	return string(signedToken)
+}
@@ -7,7 +7,17 @@ const ConfigurationRetrievalFailure string = "configuration_retrieval_failure"
 const ExtractionFailure string = "extraction_failure"
 const InvalidCache string = "invalid_cache"
 const NotFound string = "not_found"
+const FileNotFound string = "file_not_found"
 const IOFailure string = "io_failure"
 const InvalidInput string = "invalid_input"
 const InvalidToken string = "invalid_token"
 const MissingPermission string = "missing_permission"
+const InvalidConfiguration string = "invalid_configuration"
+const CircuitOpen string = "circuit_open"
+const NotModified string = "not_modified"
+const TokenRetrievalFailure string = "token_retrieval_failure"
+const IntegrityFailure string = "integrity_failure"
+const DecryptionFailure string = "decryption_failure"
+const EncryptionFailure string = "encryption_failure"
+const UnsafePackageEntry string = "unsafe_package_entry"
+const PackageLimitExceeded string = "package_limit_exceeded"
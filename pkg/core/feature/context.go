@@ -0,0 +1,39 @@
+package feature
+
+import (
+	"context"
+	"fmt"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+)
+
+// Context describes who a flag is being evaluated for.
+type Context struct {
+	// Key identifies the user or tenant; percentage rollouts hash it, so the same key always gets the same variant.
+	Key string
+	// Claims are matched against the flag's targeting rules.
+	Claims map[string]any
+}
+
+// NewContext creates a Context for the key without any claims.
+func NewContext(key string) Context {
+	return Context{Key: key, Claims: make(map[string]any)}
+}
+
+// NewContextFromToken creates a Context whose claims are the token's claims and whose key is the value of the
+// keyClaim, i.e. 'sub' for users or a tenant claim for tenants.
+func NewContextFromToken(token jwt.Token, keyClaim string) core.Result[Context, core.Error] {
+	claims, err := token.AsMap(context.Background())
+
+	if err != nil {
+		return core.Err[Context, core.Error](*core.NewError(core.InvalidToken, fmt.Sprintf("failed to read token's claims: %s", err)))
+	}
+
+	key, exists := claims[keyClaim]
+
+	if !exists {
+		return core.Err[Context, core.Error](*core.NewError(core.InvalidToken, fmt.Sprintf("token is missing the '%s' claim", keyClaim)))
+	}
+
+	return core.Ok[Context, core.Error](Context{Key: fmt.Sprint(key), Claims: claims})
+}
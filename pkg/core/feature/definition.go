@@ -0,0 +1,227 @@
+package feature
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"gopkg.in/yaml.v3"
+	"hash/fnv"
+	"reflect"
+	"sort"
+)
+
+const onVariant = "on"
+const offVariant = "off"
+
+// rolloutBuckets is the number of buckets the keys are hashed into, so rollouts have a 0.01% granularity.
+const rolloutBuckets = 10000
+
+// definition is a flag as written within the configuration, i.e.:
+//
+//	NewCheckout:
+//	  Enabled: true
+//	  DefaultVariant: off
+//	  Variants:
+//	    on: true
+//	    off: false
+//	  Rules:
+//	    - Claims:
+//	        plan: [premium, enterprise]
+//	      Variant: on
+//	    - Rollout:
+//	        on: 25
+//
+// A flag may also be a plain boolean, i.e. 'NewCheckout: true'. Without Variants, a flag is boolean: its variants
+// are 'on' and 'off', and its default variant is 'off'. A flag which is explicitly enabled has to specify its
+// DefaultVariant, since 'Enabled: true' on its own would otherwise leave the flag off.
+type definition struct {
+	Enabled        *bool          `yaml:"Enabled"`
+	DefaultVariant string         `yaml:"DefaultVariant"`
+	Variants       map[string]any `yaml:"Variants"`
+	Rules          []rule         `yaml:"Rules"`
+}
+
+// rule targets the contexts whose claims match every one of its Claims, which are either a value or a list of
+// accepted values. Matching contexts get either the Variant or, for a Rollout, a variant picked by the percentages
+// assigned to each variant; a context which falls outside the Rollout's percentages moves on to the next rule.
+// Every rule sets exactly one of Variant or Rollout.
+type rule struct {
+	Claims  map[string]any     `yaml:"Claims"`
+	Variant string             `yaml:"Variant"`
+	Rollout map[string]float64 `yaml:"Rollout"`
+}
+
+func parseDefinition(name string, value any) core.Result[definition, core.Error] {
+	if enabled, ok := value.(bool); ok {
+		defaultVariant := offVariant
+
+		if enabled {
+			defaultVariant = onVariant
+		}
+
+		return core.Ok[definition, core.Error](definition{DefaultVariant: defaultVariant, Variants: defaultVariants()})
+	}
+
+	content, err := yaml.Marshal(value)
+
+	if err != nil {
+		return core.Err[definition, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("failed to read flag '%s': %s", name, err)))
+	}
+
+	var flag definition
+
+	if err := yaml.Unmarshal(content, &flag); err != nil {
+		return core.Err[definition, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("failed to read flag '%s': %s", name, err)))
+	}
+
+	if len(flag.Variants) == 0 {
+		flag.Variants = defaultVariants()
+	}
+
+	if flag.Enabled != nil && *flag.Enabled && len(flag.DefaultVariant) == 0 {
+		return core.Err[definition, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("flag '%s' is enabled without a DefaultVariant", name)))
+	}
+
+	if len(flag.DefaultVariant) == 0 {
+		flag.DefaultVariant = offVariant
+	}
+
+	return flag.validate(name)
+}
+
+func (d definition) validate(name string) core.Result[definition, core.Error] {
+	variants := []string{d.DefaultVariant}
+	percentages := make([]float64, 0)
+
+	for i, rule := range d.Rules {
+		if len(rule.Variant) == 0 && len(rule.Rollout) == 0 {
+			return core.Err[definition, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("rule %d of flag '%s' has neither a Variant nor a Rollout", i, name)))
+		}
+
+		if len(rule.Variant) > 0 && len(rule.Rollout) > 0 {
+			return core.Err[definition, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("rule %d of flag '%s' has both a Variant and a Rollout", i, name)))
+		}
+
+		if len(rule.Variant) > 0 {
+			variants = append(variants, rule.Variant)
+		}
+
+		total := 0.0
+
+		for variant, percentage := range rule.Rollout {
+			variants = append(variants, variant)
+			percentages = append(percentages, percentage)
+			total += percentage
+		}
+
+		percentages = append(percentages, total)
+	}
+
+	for _, variant := range variants {
+		if _, exists := d.Variants[variant]; !exists {
+			return core.Err[definition, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("flag '%s' uses the undefined variant '%s'", name, variant)))
+		}
+	}
+
+	for _, percentage := range percentages {
+		if percentage < 0 || percentage > 100 {
+			return core.Err[definition, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("flag '%s' has a rollout outside of 0-100%%", name)))
+		}
+	}
+
+	return core.Ok[definition, core.Error](d)
+}
+
+func (d definition) isEnabled() bool {
+	return d.Enabled == nil || *d.Enabled
+}
+
+// matches returns whether every one of the rule's claims is satisfied by the context's claims.
+func (r rule) matches(ctx Context) bool {
+	for claim, expected := range r.Claims {
+		if !claimMatches(ctx.Claims[claim], expected) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pickRolloutVariant returns the variant whose percentage range contains the key's bucket, if any.
+func (r rule) pickRolloutVariant(flagName string, key string) core.Option[string] {
+	if len(key) == 0 {
+		return core.None[string]()
+	}
+
+	variants := make([]string, 0, len(r.Rollout))
+
+	for variant := range r.Rollout {
+		variants = append(variants, variant)
+	}
+
+	// Variants are sorted so every instance assigns the same buckets to the same variants.
+	sort.Strings(variants)
+
+	bucket := getRolloutBucket(flagName, key)
+	threshold := 0.0
+
+	for _, variant := range variants {
+		threshold += r.Rollout[variant] * rolloutBuckets / 100
+
+		if float64(bucket) < threshold {
+			return core.Some[string](variant)
+		}
+	}
+
+	return core.None[string]()
+}
+
+// getRolloutBucket hashes the key along with the flag's name, so each flag rolls out to a different set of keys.
+func getRolloutBucket(flagName string, key string) uint32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(flagName + ":" + key))
+
+	return hash.Sum32() % rolloutBuckets
+}
+
+// claimMatches returns whether the claim, or any of its values if it's a list, equals the expected value or any
+// of the expected values if it's a list.
+func claimMatches(claim any, expected any) bool {
+	if claim == nil {
+		return false
+	}
+
+	for _, value := range toList(claim) {
+		for _, expectedValue := range toList(expected) {
+			if fmt.Sprint(value) == fmt.Sprint(expectedValue) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// toList returns the values of a slice of any type, i.e. the '[]string' claims of a token, or the value itself.
+func toList(value any) []any {
+	if list, ok := value.([]any); ok {
+		return list
+	}
+
+	reflected := reflect.ValueOf(value)
+
+	if reflected.Kind() != reflect.Slice && reflected.Kind() != reflect.Array {
+		return []any{value}
+	}
+
+	list := make([]any, reflected.Len())
+
+	for i := range list {
+		list[i] = reflected.Index(i).Interface()
+	}
+
+	return list
+}
+
+func defaultVariants() map[string]any {
+	return map[string]any{onVariant: true, offVariant: false}
+}
@@ -0,0 +1,214 @@
+package feature
+
+import (
+	"fmt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"go.uber.org/zap"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Reason explains why an Evaluation resulted in its variant.
+type Reason string
+
+const (
+	// Disabled means the flag is disabled, so its default variant is used.
+	Disabled Reason = "disabled"
+	// Targeted means a rule targeting the context's claims picked the variant.
+	Targeted Reason = "targeted"
+	// RolledOut means a percentage rollout picked the variant.
+	RolledOut Reason = "rolled_out"
+	// Default means no rule matched, so the flag's default variant is used.
+	Default Reason = "default"
+)
+
+// Evaluation is the outcome of evaluating a flag for a Context.
+type Evaluation struct {
+	Flag    string
+	Variant string
+	Value   any
+	Reason  Reason
+}
+
+// Flags evaluates the feature flags stored within a configuration file, under the specified key, where each
+// flag is a key of its own. Flags are read through the Provider on every evaluation, so a reload of the
+// configuration package applies right away; the decoded flags are cached until the Provider returns a different
+// set of flags, which happens once its cache is cleaned by a reload.
+type Flags struct {
+	logger   *zap.Logger
+	provider config.Provider
+	filePath string
+	key      string
+	// source is the set of flags the definitions have been decoded from.
+	source          map[string]any
+	definitions     map[string]definition
+	definitionMutex sync.Mutex
+}
+
+// NewFlags creates an instance of Flags which reads the flags located at the key within the file, i.e.
+// 'features.yaml' and 'Flags'.
+func NewFlags(logger *zap.Logger, provider config.Provider, filePath string, key string) *Flags {
+	flags := new(Flags)
+
+	flags.logger = logger
+	flags.provider = provider
+	flags.filePath = filePath
+	flags.key = key
+	flags.definitions = make(map[string]definition)
+
+	return flags
+}
+
+// Evaluate picks the flag's variant for the context: a disabled flag gets its default variant, otherwise its
+// rules are checked in order and the first one which matches the context picks the variant; if none does, the
+// default variant is used.
+func (f *Flags) Evaluate(name string, ctx Context) core.Result[Evaluation, core.Error] {
+	definitionResult := f.getDefinition(name)
+
+	if definitionResult.IsErr() {
+		return core.Err[Evaluation, core.Error](definitionResult.UnwrapErr())
+	}
+
+	flag := definitionResult.Unwrap()
+	variant, reason := flag.DefaultVariant, Default
+
+	if !flag.isEnabled() {
+		reason = Disabled
+	} else {
+		for _, rule := range flag.Rules {
+			if !rule.matches(ctx) {
+				continue
+			}
+
+			if len(rule.Rollout) == 0 {
+				variant, reason = rule.Variant, Targeted
+				break
+			}
+
+			rolloutVariant := rule.pickRolloutVariant(name, ctx.Key)
+
+			if rolloutVariant.IsSome() {
+				variant, reason = rolloutVariant.Unwrap(), RolledOut
+				break
+			}
+		}
+	}
+
+	return core.Ok[Evaluation, core.Error](Evaluation{Flag: name, Variant: variant, Value: flag.Variants[variant], Reason: reason})
+}
+
+// Variant returns the name of the flag's variant for the context.
+func (f *Flags) Variant(name string, ctx Context) core.Result[string, core.Error] {
+	evaluationResult := f.Evaluate(name, ctx)
+
+	if evaluationResult.IsErr() {
+		return core.Err[string, core.Error](evaluationResult.UnwrapErr())
+	}
+
+	return core.Ok[string, core.Error](evaluationResult.Unwrap().Variant)
+}
+
+// IsEnabled returns whether the flag's value for the context is true. Flags which are missing or invalid are
+// reported as disabled, so callers can safely fall back to the existing behavior.
+func (f *Flags) IsEnabled(name string, ctx Context) bool {
+	evaluationResult := f.Evaluate(name, ctx)
+
+	if evaluationResult.IsErr() {
+		f.logger.Warn("Failed to evaluate feature flag.", zap.String("flag", name), zap.String("err", evaluationResult.UnwrapErr().Message))
+
+		return false
+	}
+
+	enabled, _ := evaluationResult.Unwrap().Value.(bool)
+
+	return enabled
+}
+
+// Watch calls the listener with the names of the flags changed by every configuration package the client
+// replaces its current one with, or with '*' if the whole file has changed. The returned function stops watching.
+func (f *Flags) Watch(client *config.Client, listener func(flags []string)) func() {
+	return client.Subscribe(func(diff config.ConfigDiff) {
+		changedFlags := f.getChangedFlags(diff)
+
+		if len(changedFlags) > 0 {
+			listener(changedFlags)
+		}
+	})
+}
+
+func (f *Flags) getDefinition(name string) core.Result[definition, core.Error] {
+	flagsResult := f.provider.Get(f.filePath, f.key)
+
+	if flagsResult.IsErr() {
+		return core.Err[definition, core.Error](flagsResult.UnwrapErr())
+	}
+
+	flags, ok := flagsResult.Unwrap().(map[string]any)
+
+	if !ok {
+		return core.Err[definition, core.Error](*core.NewError(core.InvalidConfiguration, fmt.Sprintf("expected '%s' to be a map of flags", f.key)))
+	}
+
+	f.definitionMutex.Lock()
+	defer f.definitionMutex.Unlock()
+
+	if f.source == nil || reflect.ValueOf(f.source).Pointer() != reflect.ValueOf(flags).Pointer() {
+		f.source = flags
+		f.definitions = make(map[string]definition)
+	}
+
+	if flag, exists := f.definitions[name]; exists {
+		return core.Ok[definition, core.Error](flag)
+	}
+
+	value, exists := flags[name]
+
+	if !exists {
+		return core.Err[definition, core.Error](*core.NewError(core.NotFound, fmt.Sprintf("couldn't find flag '%s'", name)))
+	}
+
+	definitionResult := parseDefinition(name, value)
+
+	if definitionResult.IsOk() {
+		f.definitions[name] = definitionResult.Unwrap()
+	}
+
+	return definitionResult
+}
+
+// getChangedFlags returns the sorted names of the flags touched by the diff's changes.
+func (f *Flags) getChangedFlags(diff config.ConfigDiff) []string {
+	prefix := f.key + ":"
+	flagSet := make(map[string]bool)
+
+	for _, change := range diff.Changes {
+		if change.FilePath != f.filePath {
+			continue
+		}
+
+		// A change without key path means the whole file has been added, removed or could not be parsed.
+		if len(change.KeyPath) == 0 || change.KeyPath == f.key {
+			return []string{"*"}
+		}
+
+		if !strings.HasPrefix(change.KeyPath, prefix) {
+			continue
+		}
+
+		flagName, _, _ := strings.Cut(strings.TrimPrefix(change.KeyPath, prefix), ":")
+		flagSet[flagName] = true
+	}
+
+	changedFlags := make([]string, 0, len(flagSet))
+
+	for flagName := range flagSet {
+		changedFlags = append(changedFlags, flagName)
+	}
+
+	sort.Strings(changedFlags)
+
+	return changedFlags
+}
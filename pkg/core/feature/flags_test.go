@@ -0,0 +1,236 @@
+package feature
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/simpleg-eu/cuplan_core/pkg/core"
+	"github.com/simpleg-eu/cuplan_core/pkg/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const flagsFile = "features.yaml"
+const flagsKey = "Flags"
+
+const flagsContent = `Flags:
+  Static: true
+  Killed:
+    Enabled: false
+    DefaultVariant: off
+    Rules:
+      - Variant: on
+  Targeted:
+    Rules:
+      - Claims:
+          plan: [premium, enterprise]
+          permissions: beta
+        Variant: on
+  Checkout:
+    DefaultVariant: classic
+    Variants:
+      classic: v1
+      express: v2
+      oneClick: v3
+    Rules:
+      - Claims:
+          country: ES
+        Variant: oneClick
+      - Rollout:
+          express: 30
+  Invalid:
+    Rules:
+      - Variant: missing
+  EnabledWithoutDefault:
+    Enabled: true
+  RuleWithoutOutcome:
+    Rules:
+      - Claims:
+          plan: premium
+  RuleWithBothOutcomes:
+    Rules:
+      - Variant: on
+        Rollout:
+          on: 50
+`
+
+type FlagsTestSuite struct {
+	suite.Suite
+	Path     string
+	Provider *config.FileProvider
+	Flags    *Flags
+}
+
+func TestFlagsTestSuite(t *testing.T) {
+	suite.Run(t, new(FlagsTestSuite))
+}
+
+func (f *FlagsTestSuite) SetupTest() {
+	f.Path = uuid.New().String()
+	_ = os.MkdirAll(f.Path, os.ModePerm)
+	f.WriteFlags(flagsContent)
+	f.Provider = config.NewFileProvider(f.Path, core.NewCache(time.Hour), time.Hour)
+	logger, _ := zap.NewDevelopment()
+	f.Flags = NewFlags(logger, f.Provider, flagsFile, flagsKey)
+}
+
+func (f *FlagsTestSuite) TearDownTest() {
+	_ = os.RemoveAll(f.Path)
+}
+
+func (f *FlagsTestSuite) TestIsEnabled_StaticFlag_ReturnsValue() {
+	assert.True(f.T(), f.Flags.IsEnabled("Static", NewContext("user")))
+}
+
+func (f *FlagsTestSuite) TestEvaluate_DisabledFlag_ReturnsDefaultVariant() {
+	result := f.Flags.Evaluate("Killed", NewContext("user"))
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), Evaluation{Flag: "Killed", Variant: "off", Value: false, Reason: Disabled}, result.Unwrap())
+}
+
+func (f *FlagsTestSuite) TestIsEnabled_MatchingClaims_ReturnsTrue() {
+	ctx := Context{Key: "user", Claims: map[string]any{"plan": "premium", "permissions": []any{"read", "beta"}}}
+
+	assert.True(f.T(), f.Flags.IsEnabled("Targeted", ctx))
+}
+
+func (f *FlagsTestSuite) TestIsEnabled_StringSliceClaims_ReturnsTrue() {
+	ctx := Context{Key: "user", Claims: map[string]any{"plan": "premium", "permissions": []string{"read", "beta"}}}
+
+	assert.True(f.T(), f.Flags.IsEnabled("Targeted", ctx))
+}
+
+func (f *FlagsTestSuite) TestIsEnabled_PartiallyMatchingClaims_ReturnsFalse() {
+	ctx := Context{Key: "user", Claims: map[string]any{"plan": "free", "permissions": []any{"beta"}}}
+
+	assert.False(f.T(), f.Flags.IsEnabled("Targeted", ctx))
+}
+
+func (f *FlagsTestSuite) TestEvaluate_Multivariate_TargetedVariantTakesPrecedence() {
+	result := f.Flags.Evaluate("Checkout", Context{Key: "user", Claims: map[string]any{"country": "ES"}})
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), Evaluation{Flag: "Checkout", Variant: "oneClick", Value: "v3", Reason: Targeted}, result.Unwrap())
+}
+
+func (f *FlagsTestSuite) TestEvaluate_Rollout_IsDeterministicAndProportional() {
+	expressCount := 0
+
+	for index := 0; index < 2000; index++ {
+		key := fmt.Sprintf("user-%d", index)
+		first := f.Flags.Variant("Checkout", NewContext(key)).Unwrap()
+		second := f.Flags.Variant("Checkout", NewContext(key)).Unwrap()
+
+		assert.Equal(f.T(), first, second)
+
+		if first == "express" {
+			expressCount++
+		}
+	}
+
+	assert.InDelta(f.T(), 600, expressCount, 100)
+}
+
+func (f *FlagsTestSuite) TestEvaluate_RolloutWithoutKey_ReturnsDefaultVariant() {
+	result := f.Flags.Evaluate("Checkout", NewContext(""))
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), Default, result.Unwrap().Reason)
+	assert.Equal(f.T(), "classic", result.Unwrap().Variant)
+}
+
+func (f *FlagsTestSuite) TestEvaluate_UndefinedVariant_InvalidConfiguration() {
+	result := f.Flags.Evaluate("Invalid", NewContext("user"))
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+}
+
+func (f *FlagsTestSuite) TestEvaluate_EnabledWithoutDefaultVariant_InvalidConfiguration() {
+	result := f.Flags.Evaluate("EnabledWithoutDefault", NewContext("user"))
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+}
+
+func (f *FlagsTestSuite) TestEvaluate_RuleWithoutVariantNorRollout_InvalidConfiguration() {
+	result := f.Flags.Evaluate("RuleWithoutOutcome", Context{Key: "user", Claims: map[string]any{"plan": "premium"}})
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+}
+
+func (f *FlagsTestSuite) TestEvaluate_RuleWithVariantAndRollout_InvalidConfiguration() {
+	result := f.Flags.Evaluate("RuleWithBothOutcomes", NewContext("user"))
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.InvalidConfiguration, result.UnwrapErr().ErrorKind)
+}
+
+func (f *FlagsTestSuite) TestEvaluate_SameConfiguration_DecodesFlagOnce() {
+	_ = f.Flags.Evaluate("Checkout", NewContext("user"))
+	cached := f.Flags.definitions["Checkout"]
+	cached.DefaultVariant = "express"
+	f.Flags.definitions["Checkout"] = cached
+
+	result := f.Flags.Evaluate("Checkout", NewContext(""))
+
+	assert.Equal(f.T(), "express", result.Unwrap().Variant)
+}
+
+func (f *FlagsTestSuite) TestEvaluate_MissingFlag_NotFound() {
+	result := f.Flags.Evaluate("Missing", NewContext("user"))
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.NotFound, result.UnwrapErr().ErrorKind)
+	assert.False(f.T(), f.Flags.IsEnabled("Missing", NewContext("user")))
+}
+
+func (f *FlagsTestSuite) TestIsEnabled_ReloadedConfiguration_AppliesRightAway() {
+	assert.True(f.T(), f.Flags.IsEnabled("Static", NewContext("user")))
+	f.WriteFlags("Flags:\n  Static: false\n")
+
+	f.Provider.CleanCache()
+
+	assert.False(f.T(), f.Flags.IsEnabled("Static", NewContext("user")))
+}
+
+func (f *FlagsTestSuite) TestGetChangedFlags_ReturnsFlagsWithinFile() {
+	diff := config.ConfigDiff{Changes: []config.Change{
+		{Type: config.Changed, FilePath: flagsFile, KeyPath: "Flags:Checkout:Rules:1:Rollout:express"},
+		{Type: config.Added, FilePath: flagsFile, KeyPath: "Flags:New"},
+		{Type: config.Changed, FilePath: flagsFile, KeyPath: "Flags:Checkout:DefaultVariant"},
+		{Type: config.Changed, FilePath: "application.yaml", KeyPath: "Flags:Other"},
+	}}
+
+	assert.Equal(f.T(), []string{"Checkout", "New"}, f.Flags.getChangedFlags(diff))
+}
+
+func (f *FlagsTestSuite) TestNewContextFromToken_UsesClaims() {
+	token := jwt.New()
+	_ = token.Set(jwt.SubjectKey, "user")
+	_ = token.Set("plan", "premium")
+
+	result := NewContextFromToken(token, jwt.SubjectKey)
+
+	assert.True(f.T(), result.IsOk())
+	assert.Equal(f.T(), "user", result.Unwrap().Key)
+	assert.Equal(f.T(), "premium", result.Unwrap().Claims["plan"])
+}
+
+func (f *FlagsTestSuite) TestNewContextFromToken_MissingKeyClaim_InvalidToken() {
+	result := NewContextFromToken(jwt.New(), "tenant")
+
+	assert.True(f.T(), result.IsErr())
+	assert.Equal(f.T(), core.InvalidToken, result.UnwrapErr().ErrorKind)
+}
+
+func (f *FlagsTestSuite) WriteFlags(content string) {
+	_ = os.WriteFile(filepath.Join(f.Path, flagsFile), []byte(content), 0644)
+}
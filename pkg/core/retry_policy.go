@@ -0,0 +1,63 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how many times an operation is attempted and how long to wait between attempts,
+// following an exponential backoff with jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// InitialDelay is the delay before the second attempt.
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between attempts.
+	MaxDelay time.Duration
+	// Multiplier increases the delay after every attempt.
+	Multiplier float64
+	// Jitter is the fraction, between 0 and 1, of the delay which is randomized.
+	Jitter float64
+}
+
+// NewRetryPolicy creates a RetryPolicy which doubles the delay after every attempt and randomizes 20% of it.
+func NewRetryPolicy(maxAttempts int, initialDelay time.Duration, maxDelay time.Duration) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  maxAttempts,
+		InitialDelay: initialDelay,
+		MaxDelay:     maxDelay,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// NoRetryPolicy creates a RetryPolicy which attempts an operation only once.
+func NoRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// ShouldRetry returns whether another attempt is allowed after the specified attempt, starting at 1.
+func (r RetryPolicy) ShouldRetry(attempt int) bool {
+	return attempt < r.MaxAttempts
+}
+
+// Delay returns how long to wait after the specified attempt, starting at 1, before the next one.
+func (r RetryPolicy) Delay(attempt int) time.Duration {
+	multiplier := r.Multiplier
+
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(r.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+
+	if r.MaxDelay > 0 && delay > float64(r.MaxDelay) {
+		delay = float64(r.MaxDelay)
+	}
+
+	jitter := math.Min(math.Max(r.Jitter, 0), 1)
+	delay -= delay * jitter * rand.Float64()
+
+	return time.Duration(delay)
+}
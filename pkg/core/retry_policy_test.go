@@ -0,0 +1,41 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Delay_GrowsExponentially(t *testing.T) {
+	policy := NewRetryPolicy(5, time.Second, time.Minute)
+	policy.Jitter = 0
+
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 2*time.Second, policy.Delay(2))
+	assert.Equal(t, 4*time.Second, policy.Delay(3))
+}
+
+func TestRetryPolicy_Delay_CappedAtMaxDelay(t *testing.T) {
+	policy := NewRetryPolicy(10, time.Second, 3*time.Second)
+	policy.Jitter = 0
+
+	assert.Equal(t, 3*time.Second, policy.Delay(8))
+}
+
+func TestRetryPolicy_Delay_JitterStaysWithinBounds(t *testing.T) {
+	policy := NewRetryPolicy(5, time.Second, time.Minute)
+
+	for i := 0; i < 100; i++ {
+		delay := policy.Delay(1)
+
+		assert.True(t, delay > 800*time.Millisecond && delay <= time.Second, delay)
+	}
+}
+
+func TestRetryPolicy_ShouldRetry_UntilMaxAttempts(t *testing.T) {
+	policy := NewRetryPolicy(2, time.Second, time.Minute)
+
+	assert.True(t, policy.ShouldRetry(1))
+	assert.False(t, policy.ShouldRetry(2))
+	assert.False(t, NoRetryPolicy().ShouldRetry(1))
+}